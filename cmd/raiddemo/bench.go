@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"maps"
+	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/arbhalerao/go-software-raid/pkg/raid"
+)
+
+// runBench measures each workload on throwaway arrays of each level under
+// disks/bench/, which are deleted afterwards. It does not touch the array
+// managed by the other commands.
+func runBench(_ string, args []string) error {
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	levels := fs.String("levels", "0,1,5", "Comma-separated RAID levels to compare")
+	workloads := fs.String("workloads", "seq_write,seq_read,rand_write,rand_read", "Comma-separated workloads, run in order")
+	depth := fs.Int("qd", 1, "Queue depth: block operations in flight at once")
+	ops := fs.Int("ops", 0, "Operations per workload, one pass over the array if 0")
+	blockSize := fs.Int("block-size", 4096, "Block size in bytes")
+	blocksPerDisk := fs.Int("blocks", 1000, "Blocks per disk")
+	ioUring := fs.Bool("io-uring", false, "Submit disk I/O through io_uring (Linux only)")
+	direct := fs.Bool("direct", false, "Open disk images with O_DIRECT, bypassing the page cache (Linux only)")
+	mmap := fs.Bool("mmap", false, "Memory-map disk images instead of using pread/pwrite (Linux only)")
+	latency := fs.String("latency", "none", "Simulated latency of every member: none, ssd or hdd")
+	slow := fs.Int("slow", -1, "Give this member the -slow-latency model instead")
+	slowLatency := fs.String("slow-latency", "hdd", "Simulated latency of the -slow member")
+	readPolicy := fs.String("read-policy", "first", "Which mirror serves RAID 1 reads: "+strings.Join(slices.Sorted(maps.Keys(readPolicies)), ", "))
+	fs.Parse(args)
+
+	model, err := raid.ParseLatencyModel(*latency)
+	if err != nil {
+		return err
+	}
+	slowModel, err := raid.ParseLatencyModel(*slowLatency)
+	if err != nil {
+		return err
+	}
+	policy, ok := readPolicies[*readPolicy]
+	if !ok {
+		return fmt.Errorf("unknown read policy %q", *readPolicy)
+	}
+
+	var raidLevels []raid.RAIDLevel
+	for _, s := range strings.Split(*levels, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(s))
+		if _, ok := demoDisks[raid.RAIDLevel(n)]; err != nil || !ok {
+			return fmt.Errorf("unsupported RAID level %q", s)
+		}
+		raidLevels = append(raidLevels, raid.RAIDLevel(n))
+	}
+	var wls []raid.Workload
+	for _, s := range strings.Split(*workloads, ",") {
+		w, err := raid.ParseWorkload(strings.TrimSpace(s))
+		if err != nil {
+			return err
+		}
+		wls = append(wls, w)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	defer os.RemoveAll("disks/bench")
+
+	var results []benchRow
+	if !*jsonOutput {
+		fmt.Printf("%-6s %-11s %3s %7s %10s %9s %9s %9s %9s\n",
+			"level", "workload", "qd", "ops", "IOPS", "MB/s", "p50", "p99", "max")
+	}
+	for _, level := range raidLevels {
+		dir := fmt.Sprintf("disks/bench/raid%d", level)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create disk directory: %w", err)
+		}
+		diskPaths := make([]string, demoDisks[level])
+		for i := range diskPaths {
+			diskPaths[i] = fmt.Sprintf("%s/disk%d.img", dir, i)
+		}
+
+		array, err := raid.NewRAIDArray(raid.RAIDConfig{
+			Level:         level,
+			DiskPaths:     diskPaths,
+			BlockSize:     *blockSize,
+			BlocksPerDisk: *blocksPerDisk,
+			IOUring:       *ioUring,
+			DirectIO:      *direct,
+			Mmap:          *mmap,
+			SyncPolicy:    raid.SyncOnFlush, // measure the RAID layer, not fsync
+			Latency:       model,
+			ReadPolicy:    policy,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create RAID %d array: %w", level, err)
+		}
+		if *slow >= 0 {
+			if err := array.SetLatencyModel(*slow, slowModel); err != nil {
+				array.Close()
+				return err
+			}
+		}
+
+		for _, w := range wls {
+			res, err := array.Bench(ctx, raid.BenchOptions{Workload: w, QueueDepth: *depth, Ops: *ops})
+			if err != nil {
+				array.Close()
+				return err
+			}
+			results = append(results, benchRow{level, res})
+			if !*jsonOutput {
+				fmt.Printf("raid%-2d %-11s %3d %7d %10.0f %9.1f %9s %9s %9s\n",
+					level, w, res.QueueDepth, res.Ops, res.IOPS, res.MBps,
+					res.Latency.P50, res.Latency.P99, res.Latency.Max)
+			}
+		}
+		array.Close()
+	}
+
+	if *jsonOutput {
+		printJSON(results)
+	}
+	return nil
+}
+
+type benchRow struct {
+	Level raid.RAIDLevel `json:"level"`
+	*raid.BenchResult
+}
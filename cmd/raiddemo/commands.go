@@ -0,0 +1,735 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/arbhalerao/go-software-raid/pkg/raid"
+)
+
+func runCreate(metaPath string, args []string) error {
+	fs := flag.NewFlagSet("create", flag.ExitOnError)
+	level := fs.Int("level", 5, "RAID level (0, 1, or 5)")
+	disks := fs.Int("disks", 0, "Number of member disks (default 3 for RAID 0, 2 for RAID 1, 4 for RAID 5)")
+	blockSize := fs.Int("block-size", 4096, "Block size in bytes")
+	blocksPerDisk := fs.Int("blocks", 100, "Blocks per disk")
+	dir := fs.String("dir", "", "Directory for the member images (default disks/raid<level>)")
+	ioUring := fs.Bool("io-uring", false, "Submit disk I/O through io_uring (Linux only)")
+	direct := fs.Bool("direct", false, "Open disk images with O_DIRECT, bypassing the page cache (Linux only)")
+	mmap := fs.Bool("mmap", false, "Memory-map disk images instead of using pread/pwrite (Linux only)")
+	fs.Parse(args)
+
+	if _, err := os.Stat(metaPath); err == nil {
+		return fmt.Errorf("an array is already recorded at %s", metaPath)
+	}
+
+	raidLevel := raid.RAIDLevel(*level)
+	numDisks := *disks
+	if numDisks == 0 {
+		n, ok := demoDisks[raidLevel]
+		if !ok {
+			return fmt.Errorf("unsupported RAID level: %d", raidLevel)
+		}
+		numDisks = n
+	}
+	if *dir == "" {
+		*dir = filepath.Join(filepath.Dir(metaPath), fmt.Sprintf("raid%d", raidLevel))
+	}
+	if err := os.MkdirAll(*dir, 0755); err != nil {
+		return fmt.Errorf("failed to create disk directory: %w", err)
+	}
+
+	diskPaths := make([]string, numDisks)
+	for i := range diskPaths {
+		diskPaths[i] = filepath.Join(*dir, fmt.Sprintf("disk%d.img", i))
+		if _, err := os.Stat(diskPaths[i]); err == nil {
+			return fmt.Errorf("%s already exists", diskPaths[i])
+		}
+	}
+
+	array, err := raid.NewRAIDArray(raid.RAIDConfig{
+		Level:         raidLevel,
+		DiskPaths:     diskPaths,
+		BlockSize:     *blockSize,
+		BlocksPerDisk: *blocksPerDisk,
+		IOUring:       *ioUring,
+		DirectIO:      *direct,
+		Mmap:          *mmap,
+		EventLog:      filepath.Join(filepath.Dir(metaPath), "events.jsonl"),
+	})
+	if err != nil {
+		return err
+	}
+	if err := closeArray(metaPath, array, &arrayFile{Active: true}); err != nil {
+		return err
+	}
+	fmt.Printf("Created RAID %d array of %d disks, %d blocks of %d bytes\n",
+		raidLevel, numDisks, array.Capacity(), *blockSize)
+	return nil
+}
+
+func runAssemble(metaPath string, _ []string) error {
+	f, err := loadArrayFile(metaPath)
+	if err != nil {
+		return err
+	}
+	if f.Active {
+		return fmt.Errorf("array is already assembled")
+	}
+	f.Active = true
+	if err := f.save(metaPath); err != nil {
+		return err
+	}
+	// assembling checks the members are all there
+	return withArray(metaPath, func(array *raid.RAIDArray) error {
+		printStatus(array)
+		return nil
+	})
+}
+
+func runStop(metaPath string, _ []string) error {
+	array, f, err := openArray(metaPath)
+	if err != nil {
+		return err
+	}
+	if err := array.Flush(); err != nil {
+		array.Close()
+		return err
+	}
+	f.Active = false
+	if err := closeArray(metaPath, array, f); err != nil {
+		return err
+	}
+	fmt.Println("Array stopped")
+	return nil
+}
+
+func runStatus(metaPath string, args []string) error {
+	fs := flag.NewFlagSet("status", flag.ExitOnError)
+	mdstat := fs.Bool("mdstat", false, "Print in the layout of /proc/mdstat")
+	fs.Parse(args)
+
+	return withArray(metaPath, func(array *raid.RAIDArray) error {
+		if *mdstat {
+			return raid.WriteMDStat(os.Stdout, array)
+		}
+		if *jsonOutput {
+			printJSON(array.Health())
+			return nil
+		}
+		printStatus(array)
+		return nil
+	})
+}
+
+func runStats(metaPath string, _ []string) error {
+	return withArray(metaPath, func(array *raid.RAIDArray) error {
+		if *jsonOutput {
+			printJSON(struct {
+				Disks []raid.DiskStats `json:"disks"`
+				Array raid.ArrayStats  `json:"array"`
+			}{array.GetStats(), array.GetArrayStats()})
+			return nil
+		}
+		printDiskStats(array)
+		return nil
+	})
+}
+
+func runFail(metaPath string, args []string) error {
+	disk, err := diskArg(args)
+	if err != nil {
+		return err
+	}
+	return editMeta(metaPath, func(f *arrayFile, meta *raid.ArrayMeta) error {
+		if disk >= len(meta.Members) {
+			return fmt.Errorf("invalid disk index %d", disk)
+		}
+		if meta.Members[disk].Failed {
+			return fmt.Errorf("disk %d is already failed", disk)
+		}
+		meta.Members[disk].Failed = true
+		if meta.EventLog != "" {
+			err := raid.AppendEventLog(meta.EventLog, raid.Event{Type: raid.EventDiskFailed, Disk: disk, Stripe: -1, Detail: "marked failed by the operator"})
+			if err != nil {
+				return err
+			}
+		}
+		fmt.Printf("Disk %d marked failed\n", disk)
+		return nil
+	})
+}
+
+// runRemove deletes the image of a failed member so a replacement can be
+// added in its place.
+func runRemove(metaPath string, args []string) error {
+	disk, err := diskArg(args)
+	if err != nil {
+		return err
+	}
+	return editMeta(metaPath, func(f *arrayFile, meta *raid.ArrayMeta) error {
+		if disk >= len(meta.Members) {
+			return fmt.Errorf("invalid disk index %d", disk)
+		}
+		m := &meta.Members[disk]
+		if !m.Failed {
+			return fmt.Errorf("disk %d is active, fail it first", disk)
+		}
+		if err := removeImage(m.Path); err != nil {
+			return err
+		}
+		m.BadBlocks = nil
+		m.RebuildCheckpoint = nil
+		fmt.Printf("Disk %d removed\n", disk)
+		return nil
+	})
+}
+
+// runAdd puts a blank image in the slot of a removed member and rebuilds
+// onto it. The image goes to the given path, else the next spare, else
+// where the old one was. The new member is recorded only once the rebuild
+// has finished; if it fails or is interrupted the image is deleted and the
+// array stays as it was.
+func runAdd(metaPath string, args []string) error {
+	disk, err := diskArg(args)
+	if err != nil {
+		return err
+	}
+	f, meta, err := loadMeta(metaPath)
+	if err != nil {
+		return err
+	}
+	if meta.Level != raid.RAID5 {
+		return fmt.Errorf("disk rebuild only supported for RAID 5")
+	}
+	if disk >= len(meta.Members) {
+		return fmt.Errorf("invalid disk index %d", disk)
+	}
+	m := &meta.Members[disk]
+	if !m.Failed {
+		return fmt.Errorf("disk %d is active", disk)
+	}
+	switch {
+	case len(args) > 1:
+		m.Path = args[1]
+	case len(f.Spares) > 0:
+		m.Path, f.Spares = f.Spares[0], f.Spares[1:]
+	}
+	if _, err := os.Stat(m.Path); err == nil {
+		return fmt.Errorf("%s already exists, remove disk %d first", m.Path, disk)
+	}
+	m.BadBlocks = nil
+	m.RebuildCheckpoint = nil
+
+	doc, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	array, err := raid.ImportMeta(doc, nil)
+	if err == nil {
+		if err = rebuildMember(array, disk); err == nil {
+			return closeArray(metaPath, array, f)
+		}
+		array.Close()
+	}
+	if rmErr := removeImage(m.Path); rmErr != nil {
+		err = fmt.Errorf("%w (new image not removed: %v)", err, rmErr)
+	}
+	return err
+}
+
+// removeImage deletes a member image and the files kept beside it.
+func removeImage(path string) error {
+	for _, p := range []string{path, path + ".badblocks", path + ".rebuild"} {
+		if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+func runRebuild(metaPath string, args []string) error {
+	disk, err := diskArg(args)
+	if err != nil {
+		return err
+	}
+	return rebuild(metaPath, disk)
+}
+
+// rebuild reconstructs the member until done or interrupted. An interrupted
+// rebuild resumes where it stopped on the next rebuild.
+func rebuild(metaPath string, disk int) error {
+	return withArray(metaPath, func(array *raid.RAIDArray) error {
+		return rebuildMember(array, disk)
+	})
+}
+
+// rebuildMember rebuilds one member of an open array, printing progress,
+// until done or interrupted.
+func rebuildMember(array *raid.RAIDArray, disk int) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	events, cancel := array.Subscribe(16)
+	printed := make(chan struct{})
+	go func() {
+		defer close(printed)
+		for e := range events {
+			if e.Type == raid.EventRebuildProgress {
+				fmt.Printf("Rebuilding disk %d: %s\n", e.Disk, e.Detail)
+			}
+		}
+	}()
+	err := array.RebuildDisk(ctx, disk)
+	cancel()
+	<-printed
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Disk %d rebuilt\n", disk)
+	return nil
+}
+
+func runScrub(metaPath string, args []string) error {
+	fs := flag.NewFlagSet("scrub", flag.ExitOnError)
+	repair := fs.Bool("repair", false, "Rewrite parity or resync mirrors on mismatch")
+	rate := fs.Int("rate", 0, "Stripes per second, 0 for unlimited")
+	fs.Parse(args)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	return withArray(metaPath, func(array *raid.RAIDArray) error {
+		result, err := array.Scrub(ctx, raid.ScrubOptions{Repair: *repair, Rate: *rate})
+		if err != nil {
+			return err
+		}
+		if *jsonOutput {
+			printJSON(result)
+		} else {
+			fmt.Printf("Scrubbed %d stripes: %d mismatches, %d repaired, %d skipped\n",
+				result.Stripes, len(result.Mismatches), result.Repaired, result.Skipped)
+			printStripes(result.Mismatches)
+		}
+		if len(result.Mismatches) > result.Repaired {
+			return errUnclean
+		}
+		return nil
+	})
+}
+
+func runCheck(metaPath string, _ []string) error {
+	return withArray(metaPath, func(array *raid.RAIDArray) error {
+		report, err := array.Check()
+		if err != nil {
+			return err
+		}
+		if *jsonOutput {
+			printJSON(report)
+		} else {
+			fmt.Printf("Checked %d stripes: %d mismatches, %d skipped\n",
+				report.Stripes, len(report.Mismatches), report.Skipped)
+			printStripes(report.Mismatches)
+		}
+		if !report.Clean {
+			return errUnclean
+		}
+		return nil
+	})
+}
+
+func runRepair(metaPath string, args []string) error {
+	stripes := make([]int, len(args))
+	for i, arg := range args {
+		n, err := strconv.Atoi(arg)
+		if err != nil {
+			return fmt.Errorf("invalid stripe %q", arg)
+		}
+		stripes[i] = n
+	}
+
+	return withArray(metaPath, func(array *raid.RAIDArray) error {
+		if len(stripes) == 0 {
+			report, err := array.Check()
+			if err != nil {
+				return err
+			}
+			stripes = report.Mismatches
+		}
+
+		if err := array.Repair(stripes); err != nil {
+			return err
+		}
+		fmt.Printf("Repaired %d stripes\n", len(stripes))
+		return nil
+	})
+}
+
+func runVerifyExport(metaPath string, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: verify-export <image>")
+	}
+	img, err := os.Open(args[0])
+	if err != nil {
+		return err
+	}
+	defer img.Close()
+
+	return withArray(metaPath, func(array *raid.RAIDArray) error {
+		diff, err := array.VerifyImage(bufio.NewReader(img))
+		if err != nil {
+			return err
+		}
+		printJSON(diff)
+		if !diff.Match {
+			return errUnclean
+		}
+		return nil
+	})
+}
+
+// runLayout prints which disk holds each data and parity block, for the
+// array or, with -level, for any geometry without one.
+func runLayout(metaPath string, args []string) error {
+	fs := flag.NewFlagSet("layout", flag.ExitOnError)
+	level := fs.Int("level", -1, "RAID level to lay out instead of the array's")
+	disks := fs.Int("disks", 4, "Number of disks, with -level")
+	first := fs.Int("first", 0, "First stripe")
+	stripes := fs.Int("stripes", 8, "Number of stripes")
+	fs.Parse(args)
+
+	var layout [][]raid.MemberBlock
+	var err error
+	if *level >= 0 {
+		geo := raid.MemberGeometry{Level: raid.RAIDLevel(*level), NumDisks: *disks}
+		if layout, err = raid.StripeLayout(geo, *first, *stripes); err != nil {
+			return err
+		}
+	} else {
+		err = withArray(metaPath, func(array *raid.RAIDArray) error {
+			layout, err = array.Layout(*first, *stripes)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if *jsonOutput {
+		printJSON(layout)
+		return nil
+	}
+	return raid.WriteLayout(os.Stdout, layout)
+}
+
+// runInspect shows where a logical block lives and hexdumps every member's
+// copy of its stripe.
+func runInspect(metaPath string, args []string) error {
+	fs := flag.NewFlagSet("inspect", flag.ExitOnError)
+	block := fs.Int("block", -1, "Logical block to inspect")
+	fs.Parse(args)
+	if *block < 0 {
+		return fmt.Errorf("usage: inspect -block N")
+	}
+
+	return withArray(metaPath, func(array *raid.RAIDArray) error {
+		in, err := array.Inspect(*block)
+		if err != nil {
+			return err
+		}
+		if *jsonOutput {
+			printJSON(in)
+			return nil
+		}
+
+		where := fmt.Sprintf("disk %d block %d", in.DataDisks[0], in.Stripe)
+		if len(in.DataDisks) > 1 {
+			where = fmt.Sprintf("block %d of disks %v", in.Stripe, in.DataDisks)
+		}
+		fmt.Printf("Logical block %d: stripe %d, %s", in.LogicalBlock, in.Stripe, where)
+		if in.ParityDisk >= 0 {
+			fmt.Printf(", parity on disk %d", in.ParityDisk)
+		}
+		fmt.Println()
+		if !in.Consistent {
+			fmt.Println("Stripe is INCONSISTENT")
+		}
+		for _, m := range in.Members {
+			holds := fmt.Sprintf("D%d", m.Holds.LogicalBlock)
+			if m.Holds.Parity {
+				holds = "parity"
+			}
+			fmt.Printf("\nDisk %d block %d (%s), image block %d:\n", m.Disk, in.Stripe, holds, m.BackendBlock)
+			if m.Error != "" {
+				fmt.Printf("  %s\n", m.Error)
+				continue
+			}
+			printHexDump(m.Data)
+		}
+		return nil
+	})
+}
+
+// printHexDump prints data like hexdump -C, collapsing runs of identical
+// lines into a *.
+func printHexDump(data []byte) {
+	var prev []byte
+	skipping := false
+	for off := 0; off < len(data); off += 16 {
+		line := data[off:min(off+16, len(data))]
+		if bytes.Equal(line, prev) {
+			if !skipping {
+				fmt.Println("*")
+				skipping = true
+			}
+			continue
+		}
+		prev, skipping = line, false
+
+		ascii := make([]byte, len(line))
+		for i, c := range line {
+			ascii[i] = '.'
+			if c >= 32 && c < 127 {
+				ascii[i] = c
+			}
+		}
+		hexed := fmt.Sprintf("% x", line)
+		if len(line) > 8 {
+			hexed = fmt.Sprintf("% x  % x", line[:8], line[8:])
+		}
+		fmt.Printf("%08x  %-49s |%s|\n", off, hexed, ascii)
+	}
+	fmt.Printf("%08x\n", len(data))
+}
+
+func diskArg(args []string) (int, error) {
+	if len(args) == 0 {
+		return 0, errors.New("missing disk index")
+	}
+	disk, err := strconv.Atoi(args[0])
+	if err != nil || disk < 0 {
+		return 0, fmt.Errorf("invalid disk index %q", args[0])
+	}
+	return disk, nil
+}
+
+// loadMeta reads the recorded array definition of an assembled array
+// without opening the members.
+func loadMeta(metaPath string) (*arrayFile, *raid.ArrayMeta, error) {
+	f, err := loadArrayFile(metaPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !f.Active {
+		return nil, nil, fmt.Errorf("array is stopped, run assemble first")
+	}
+
+	var meta raid.ArrayMeta
+	if err := json.Unmarshal(f.Array, &meta); err != nil {
+		return nil, nil, fmt.Errorf("corrupt array metadata: %w", err)
+	}
+	return f, &meta, nil
+}
+
+// editMeta applies fn to the recorded array definition of an assembled
+// array without opening the members.
+func editMeta(metaPath string, fn func(*arrayFile, *raid.ArrayMeta) error) error {
+	f, meta, err := loadMeta(metaPath)
+	if err != nil {
+		return err
+	}
+	if err := fn(f, meta); err != nil {
+		return err
+	}
+	if f.Array, err = json.MarshalIndent(meta, "", "  "); err != nil {
+		return err
+	}
+	return f.save(metaPath)
+}
+
+func printStatus(array *raid.RAIDArray) {
+	state := array.State()
+	fmt.Printf("RAID %d, %d blocks: %s\n", array.Level(), array.Capacity(), state.State)
+	for _, reason := range array.Health().Reasons {
+		fmt.Printf("  %s\n", reason)
+	}
+	fmt.Println()
+	printDiskStats(array)
+}
+
+func printStripes(stripes []int) {
+	if len(stripes) > 0 {
+		fmt.Printf("Mismatched stripes: %s\n", strings.Trim(fmt.Sprint(stripes), "[]"))
+	}
+}
+
+// runSMART prints the members' simulated SMART attributes and which of
+// them are likely to fail.
+func runSMART(metaPath string, _ []string) error {
+	return withArray(metaPath, func(array *raid.RAIDArray) error {
+		if *jsonOutput {
+			printJSON(struct {
+				Disks       []raid.SMARTAttributes   `json:"disks"`
+				Predictions []raid.FailurePrediction `json:"predictions"`
+			}{array.SMART(), array.PredictFailures()})
+			return nil
+		}
+		printSMART(array)
+		return nil
+	})
+}
+
+// runEvents prints the array's event log. It reads the log without
+// assembling the array, so it also works on a stopped or failed one.
+func runEvents(metaPath string, _ []string) error {
+	f, err := loadArrayFile(metaPath)
+	if err != nil {
+		return err
+	}
+	var meta raid.ArrayMeta
+	if err := json.Unmarshal(f.Array, &meta); err != nil {
+		return fmt.Errorf("corrupt metadata file %s: %w", metaPath, err)
+	}
+	if meta.EventLog == "" {
+		return fmt.Errorf("the array has no event log")
+	}
+	events, err := raid.ReadEventLog(meta.EventLog)
+	if err != nil {
+		return err
+	}
+	if *jsonOutput {
+		printJSON(events)
+		return nil
+	}
+	for _, e := range events {
+		fmt.Printf("%s %s", e.Time.Format(time.RFC3339), e.Type)
+		if e.Disk >= 0 {
+			fmt.Printf(" disk %d", e.Disk)
+		}
+		if e.Stripe >= 0 {
+			fmt.Printf(" stripe %d", e.Stripe)
+		}
+		if e.Detail != "" {
+			fmt.Printf(": %s", e.Detail)
+		}
+		if e.Err != nil {
+			fmt.Printf(" (%v)", e.Err)
+		}
+		fmt.Println()
+	}
+	return nil
+}
+
+// runVolume lists, creates, resizes and deletes the logical volumes carved
+// out of the array.
+func runVolume(metaPath string, args []string) error {
+	if len(args) == 0 {
+		args = []string{"list"}
+	}
+	sizeArg := func() (int, error) {
+		if len(args) != 3 {
+			return 0, fmt.Errorf("usage: volume %s <name> <blocks>", args[0])
+		}
+		blocks, err := strconv.Atoi(args[2])
+		if err != nil {
+			return 0, fmt.Errorf("invalid block count %q", args[2])
+		}
+		return blocks, nil
+	}
+
+	return withArray(metaPath, func(array *raid.RAIDArray) error {
+		switch args[0] {
+		case "list":
+			vols := array.Volumes()
+			if *jsonOutput {
+				printJSON(vols)
+				return nil
+			}
+			for _, v := range vols {
+				fmt.Printf("%-16s %8d blocks in %d extents\n", v.Name, v.Blocks, len(v.Extents))
+			}
+			fmt.Printf("%d of %d blocks free\n", array.FreeBlocks(), array.Capacity())
+		case "create":
+			blocks, err := sizeArg()
+			if err != nil {
+				return err
+			}
+			if _, err := array.CreateVolume(args[1], blocks); err != nil {
+				return err
+			}
+			fmt.Printf("Created volume %s of %d blocks\n", args[1], blocks)
+		case "resize":
+			blocks, err := sizeArg()
+			if err != nil {
+				return err
+			}
+			if err := array.ResizeVolume(args[1], blocks); err != nil {
+				return err
+			}
+			fmt.Printf("Resized volume %s to %d blocks\n", args[1], blocks)
+		case "delete":
+			if len(args) != 2 {
+				return errors.New("usage: volume delete <name>")
+			}
+			if err := array.DeleteVolume(args[1]); err != nil {
+				return err
+			}
+			fmt.Printf("Deleted volume %s\n", args[1])
+		default:
+			return fmt.Errorf("unknown volume command %q", args[0])
+		}
+		return nil
+	})
+}
+
+func printSMART(array *raid.RAIDArray) {
+	for i, a := range array.SMART() {
+		fmt.Printf("Disk %d: reallocated %d, pending %d, spare %d, errors %d/%d, timeouts %d, %.0f°C, %.1f hours\n",
+			i, a.ReallocatedSectors, a.PendingSectors, a.SpareSectors, a.ReadErrors, a.WriteErrors,
+			a.CommandTimeouts, a.Temperature, a.PowerOnHours)
+	}
+	for _, p := range array.PredictFailures() {
+		fmt.Printf("Disk %d likely to fail: %s\n", p.Disk, strings.Join(p.Risks, ", "))
+	}
+}
+
+func printDiskStats(array *raid.RAIDArray) {
+	for i, stat := range array.GetStats() {
+		status := "healthy"
+		if stat.Failed {
+			status = "FAILED"
+		}
+		fmt.Printf("Disk %d (%s): %s — reads: %d, writes: %d",
+			i, stat.Path, status, stat.ReadCount, stat.WriteCount)
+		if stat.PhysicalBytes >= 0 {
+			fmt.Printf(", allocated: %d of %d KiB", stat.PhysicalBytes/1024, stat.LogicalBytes/1024)
+		}
+		if stat.RemappedBlocks > 0 {
+			fmt.Printf(", remapped: %d", stat.RemappedBlocks)
+		}
+		if n := stat.ReadErrors + stat.WriteErrors; n > 0 {
+			fmt.Printf(", errors: %d/%d", stat.ReadErrors, stat.WriteErrors)
+		}
+		fmt.Println()
+		for _, op := range []struct {
+			name string
+			h    raid.LatencyHistogram
+		}{{"read", stat.ReadLatency}, {"write", stat.WriteLatency}} {
+			if op.h.Count > 0 {
+				s := op.h.Summary()
+				fmt.Printf("  %s latency: mean %v, p50 %v, p99 %v, max %v\n", op.name, op.h.Mean(), s.P50, s.P99, s.Max)
+			}
+		}
+	}
+}
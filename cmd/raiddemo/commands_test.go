@@ -0,0 +1,286 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/arbhalerao/go-software-raid/pkg/raid"
+)
+
+// createArray creates a three-member RAID 5 array in a temporary directory
+// and returns its metadata path and directory.
+func createArray(t *testing.T) (string, string) {
+	t.Helper()
+	dir := t.TempDir()
+	metaPath := filepath.Join(dir, "array.json")
+	err := runCreate(metaPath, []string{"-disks", "3", "-block-size", "512", "-blocks", "16", "-dir", dir})
+	if err != nil {
+		t.Fatalf("Failed to create array: %v", err)
+	}
+	return metaPath, dir
+}
+
+// recordedMeta returns the array definition saved at metaPath.
+func recordedMeta(t *testing.T, metaPath string) *raid.ArrayMeta {
+	t.Helper()
+	f, err := loadArrayFile(metaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var meta raid.ArrayMeta
+	if err := json.Unmarshal(f.Array, &meta); err != nil {
+		t.Fatal(err)
+	}
+	return &meta
+}
+
+func TestStopAssemble(t *testing.T) {
+	metaPath, _ := createArray(t)
+
+	if err := runCreate(metaPath, nil); err == nil || !strings.Contains(err.Error(), "already recorded") {
+		t.Errorf("Expected create to refuse an existing array, got %v", err)
+	}
+	if err := runStop(metaPath, nil); err != nil {
+		t.Fatalf("Failed to stop array: %v", err)
+	}
+	for _, run := range []func(string, []string) error{runStatus, runFail, runCheck} {
+		if err := run(metaPath, []string{"0"}); err == nil || !strings.Contains(err.Error(), "array is stopped") {
+			t.Errorf("Expected a stopped array to be refused, got %v", err)
+		}
+	}
+
+	if err := runAssemble(metaPath, nil); err != nil {
+		t.Fatalf("Failed to assemble array: %v", err)
+	}
+	if err := runAssemble(metaPath, nil); err == nil {
+		t.Error("Expected assembling an assembled array to fail")
+	}
+	if err := runStatus(metaPath, nil); err != nil {
+		t.Errorf("Expected status to work after assembling, got %v", err)
+	}
+}
+
+func TestReplaceDisk(t *testing.T) {
+	metaPath, dir := createArray(t)
+	data := bytes.Repeat([]byte("replace"), 512/7+1)[:512]
+	if err := withArray(metaPath, func(array *raid.RAIDArray) error {
+		return array.WriteBlock(3, data)
+	}); err != nil {
+		t.Fatalf("Failed to write block 3: %v", err)
+	}
+
+	if err := runRemove(metaPath, []string{"1"}); err == nil || !strings.Contains(err.Error(), "fail it first") {
+		t.Errorf("Expected remove to refuse an active disk, got %v", err)
+	}
+	if err := runAdd(metaPath, []string{"1"}); err == nil {
+		t.Error("Expected add to refuse an active disk")
+	}
+
+	replacement := filepath.Join(dir, "replacement.img")
+	for _, step := range []struct {
+		run  func(string, []string) error
+		args []string
+	}{
+		{runFail, []string{"1"}},
+		{runRemove, []string{"1"}},
+		{runAdd, []string{"1", replacement}},
+	} {
+		if err := step.run(metaPath, step.args); err != nil {
+			t.Fatalf("Failed to replace disk 1: %v", err)
+		}
+	}
+
+	if m := recordedMeta(t, metaPath).Members[1]; m.Path != replacement || m.Failed {
+		t.Errorf("Expected disk 1 to be the rebuilt replacement, got %+v", m)
+	}
+	if err := withArray(metaPath, func(array *raid.RAIDArray) error {
+		got, err := array.ReadBlock(3)
+		if err == nil && !bytes.Equal(got, data) {
+			t.Error("Block 3 changed across the replacement")
+		}
+		return err
+	}); err != nil {
+		t.Fatalf("Failed to read block 3: %v", err)
+	}
+	if err := runCheck(metaPath, nil); err != nil {
+		t.Errorf("Expected a clean check after the rebuild, got %v", err)
+	}
+}
+
+// captureStdout runs fn and returns what it printed.
+func captureStdout(t *testing.T, fn func() error) ([]byte, error) {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := make(chan []byte)
+	go func() {
+		b, _ := io.ReadAll(r)
+		out <- b
+	}()
+
+	stdout := os.Stdout
+	os.Stdout = w
+	err = fn()
+	os.Stdout = stdout
+	w.Close()
+	return <-out, err
+}
+
+func TestJSONOutput(t *testing.T) {
+	metaPath, _ := createArray(t)
+	*jsonOutput = true
+	defer func() { *jsonOutput = false }()
+
+	out, err := captureStdout(t, func() error { return runStatus(metaPath, nil) })
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	var health map[string]json.RawMessage
+	if err := json.Unmarshal(out, &health); err != nil {
+		t.Fatalf("status printed %q, not JSON: %v", out, err)
+	}
+	if string(health["status"]) != `"pass"` || string(health["state"]) != `"optimal"` {
+		t.Errorf("Expected a passing, optimal array, got %s", out)
+	}
+
+	out, err = captureStdout(t, func() error { return runStats(metaPath, nil) })
+	if err != nil {
+		t.Fatalf("stats: %v", err)
+	}
+	var stats struct {
+		Disks []raid.DiskStats `json:"disks"`
+		Array raid.ArrayStats  `json:"array"`
+	}
+	if err := json.Unmarshal(out, &stats); err != nil || len(stats.Disks) != 3 {
+		t.Errorf("Expected stats for 3 disks, got %q (%v)", out, err)
+	}
+
+	out, err = captureStdout(t, func() error { return runCheck(metaPath, nil) })
+	if err != nil {
+		t.Fatalf("check: %v", err)
+	}
+	var report raid.CheckReport
+	if err := json.Unmarshal(out, &report); err != nil || !report.Clean || report.Stripes != 16 {
+		t.Errorf("Expected a clean check of 16 stripes, got %q (%v)", out, err)
+	}
+
+	out, err = captureStdout(t, func() error { return runScrub(metaPath, nil) })
+	if err != nil {
+		t.Fatalf("scrub: %v", err)
+	}
+	var result raid.ScrubResult
+	if err := json.Unmarshal(out, &result); err != nil || result.Stripes != 16 || len(result.Mismatches) != 0 {
+		t.Errorf("Expected a scrub of 16 clean stripes, got %q (%v)", out, err)
+	}
+}
+
+// upArray creates an array from a config in a temporary directory and
+// returns its metadata path and directory.
+func upArray(t *testing.T, level string) (string, string) {
+	t.Helper()
+	dir := t.TempDir()
+	metaPath := filepath.Join(dir, "array.json")
+	config := writeConfig(t, `level = `+level+`
+members = ["`+filepath.Join(dir, "d0.img")+`", "`+filepath.Join(dir, "d1.img")+`", "`+filepath.Join(dir, "d2.img")+`"]
+spares = ["`+filepath.Join(dir, "spare.img")+`"]
+block_size = 512
+blocks_per_disk = 16`)
+	if err := runUp(metaPath, []string{"-f", config}); err != nil {
+		t.Fatalf("Failed to create array: %v", err)
+	}
+	return metaPath, dir
+}
+
+func TestAdd(t *testing.T) {
+	metaPath, dir := upArray(t, "5")
+	spare := filepath.Join(dir, "spare.img")
+
+	for _, run := range []func(string, []string) error{runFail, runRemove, runAdd} {
+		if err := run(metaPath, []string{"2"}); err != nil {
+			t.Fatalf("Failed to replace disk 2: %v", err)
+		}
+	}
+	f, meta, err := loadMeta(metaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m := meta.Members[2]; m.Path != spare || m.Failed {
+		t.Errorf("Expected disk 2 to be the rebuilt spare, got %+v", m)
+	}
+	if len(f.Spares) != 0 {
+		t.Errorf("Expected the spare to be used, got spares %q", f.Spares)
+	}
+}
+
+func TestAddFailedRebuild(t *testing.T) {
+	metaPath, dir := upArray(t, "5")
+	spare := filepath.Join(dir, "spare.img")
+
+	// with two members gone there is nothing to rebuild from
+	for _, disk := range []string{"1", "2"} {
+		if err := runFail(metaPath, []string{disk}); err != nil {
+			t.Fatalf("Failed to fail disk %s: %v", disk, err)
+		}
+	}
+	if err := runRemove(metaPath, []string{"2"}); err != nil {
+		t.Fatalf("Failed to remove disk 2: %v", err)
+	}
+	before, err := os.ReadFile(metaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := runAdd(metaPath, []string{"2"}); err == nil {
+		t.Fatal("Expected add to fail without enough members to rebuild from")
+	}
+	after, err := os.ReadFile(metaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(before) != string(after) {
+		t.Error("Expected a failed add to leave the metadata alone")
+	}
+	if _, err := os.Stat(spare); !os.IsNotExist(err) {
+		t.Errorf("Expected the new image to be deleted, stat returned %v", err)
+	}
+	f, _, err := loadMeta(metaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(f.Spares, []string{spare}) {
+		t.Errorf("Expected the spare to stay available, got %q", f.Spares)
+	}
+}
+
+func TestAddUnsupportedLevel(t *testing.T) {
+	metaPath, _ := upArray(t, "1")
+	for _, run := range []func(string, []string) error{runFail, runRemove} {
+		if err := run(metaPath, []string{"2"}); err != nil {
+			t.Fatalf("Failed to remove disk 2: %v", err)
+		}
+	}
+	before, err := os.ReadFile(metaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = runAdd(metaPath, []string{"2"})
+	if err == nil || !strings.Contains(err.Error(), "only supported for RAID 5") {
+		t.Fatalf("Expected add to be refused for RAID 1, got %v", err)
+	}
+	after, err := os.ReadFile(metaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(before) != string(after) {
+		t.Error("Expected a refused add to leave the metadata alone")
+	}
+}
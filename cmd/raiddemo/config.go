@@ -0,0 +1,364 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/arbhalerao/go-software-raid/pkg/raid"
+)
+
+// arrayConfig is an array definition read by up. Config files use a subset
+// of TOML: one key = value pair per line, # comments, and values that are
+// quoted strings, integers, floats, booleans or arrays of strings, which
+// may span lines. Tables are not supported.
+//
+//	level = 5
+//	members = ["disks/raid5/disk0.img", "disks/raid5/disk1.img", "disks/raid5/disk2.img"]
+//	spares = ["disks/raid5/spare0.img"]
+//	block_size = 4096
+//	blocks_per_disk = 1000
+//	read_cache_blocks = 256
+//	sync_policy = "periodic"
+//	sync_interval = "500ms"
+type arrayConfig struct {
+	raid.RAIDConfig
+	Spares []string // images taken by add when no path is given
+}
+
+var syncPolicies = map[string]raid.SyncPolicy{
+	"always":   raid.SyncAlways,
+	"periodic": raid.SyncPeriodic,
+	"on_flush": raid.SyncOnFlush,
+}
+
+var readPolicies = map[string]raid.ReadPolicy{
+	"first":             raid.ReadFirst,
+	"round_robin":       raid.ReadRoundRobin,
+	"least_outstanding": raid.ReadLeastOutstanding,
+	"lowest_latency":    raid.ReadLowestLatency,
+	"preferred":         raid.ReadPreferred,
+}
+
+var preallocModes = map[string]raid.PreallocMode{
+	"sparse": raid.PreallocSparse,
+	"full":   raid.PreallocFull,
+	"none":   raid.PreallocNone,
+}
+
+func loadConfig(path string) (*arrayConfig, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	cfg := &arrayConfig{RAIDConfig: raid.RAIDConfig{
+		Level:         raid.RAID5,
+		BlockSize:     4096,
+		BlocksPerDisk: 100,
+	}}
+
+	in := bufio.NewScanner(file)
+	lineNum := 0
+	for in.Scan() {
+		lineNum++
+		line := stripComment(in.Text())
+		if line == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: expected key = value", path, lineNum)
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+
+		// an array continues until its closing bracket
+		start := lineNum
+		for strings.HasPrefix(value, "[") && !strings.HasSuffix(value, "]") && in.Scan() {
+			lineNum++
+			value += " " + stripComment(in.Text())
+		}
+
+		if err := cfg.set(key, value); err != nil {
+			return nil, fmt.Errorf("%s:%d: %s: %w", path, start, key, err)
+		}
+	}
+	if err := in.Err(); err != nil {
+		return nil, err
+	}
+	if len(cfg.DiskPaths) == 0 {
+		return nil, fmt.Errorf("%s: no members defined", path)
+	}
+	return cfg, nil
+}
+
+func (c *arrayConfig) set(key, value string) error {
+	var err error
+	switch key {
+	case "level":
+		var n int
+		n, err = parseInt(value)
+		c.Level = raid.RAIDLevel(n)
+	case "members":
+		c.DiskPaths, err = parseStrings(value)
+	case "spares":
+		c.Spares, err = parseStrings(value)
+	case "block_size":
+		c.BlockSize, err = parseInt(value)
+	case "blocks_per_disk":
+		c.BlocksPerDisk, err = parseInt(value)
+	case "remap_reserve":
+		c.RemapReserve, err = parseInt(value)
+	case "read_cache_blocks":
+		c.ReadCacheBlocks, err = parseInt(value)
+	case "stripe_cache_size":
+		c.StripeCacheSize, err = parseInt(value)
+	case "preferred_disk":
+		c.PreferredDisk, err = parseInt(value)
+	case "verify_reads":
+		c.VerifyReads, err = strconv.ParseBool(value)
+	case "io_uring":
+		c.IOUring, err = strconv.ParseBool(value)
+	case "direct_io":
+		c.DirectIO, err = strconv.ParseBool(value)
+	case "mmap":
+		c.Mmap, err = strconv.ParseBool(value)
+	case "rebuild_rate":
+		var n int
+		n, err = parseInt(value)
+		c.RebuildRate = int64(n)
+	case "probe_percent":
+		c.ProbePercent, err = strconv.ParseFloat(value, 64)
+	case "failure_per_op":
+		c.Failures.PerOp, err = strconv.ParseFloat(value, 64)
+	case "mttf":
+		c.Failures.MTTF, err = parseDuration(value)
+	case "latency":
+		var name string
+		if name, err = parseString(value); err == nil {
+			c.Latency, err = raid.ParseLatencyModel(name)
+		}
+	case "event_log":
+		c.EventLog, err = parseString(value)
+	case "sync_policy":
+		c.SyncPolicy, err = parseName(value, syncPolicies)
+	case "read_policy":
+		c.ReadPolicy, err = parseName(value, readPolicies)
+	case "prealloc":
+		c.Prealloc, err = parseName(value, preallocModes)
+	case "sync_interval":
+		c.SyncInterval, err = parseDuration(value)
+	case "slow_op_threshold":
+		c.SlowOpThreshold, err = parseDuration(value)
+	case "io_timeout":
+		c.IOTimeout, err = parseDuration(value)
+	case "io_timeout_limit":
+		c.IOTimeoutLimit, err = parseInt(value)
+	case "io_retries":
+		c.Retry.Attempts, err = parseInt(value)
+	case "io_retry_backoff":
+		c.Retry.Backoff, err = parseDuration(value)
+	case "io_retry_max_backoff":
+		c.Retry.MaxBackoff, err = parseDuration(value)
+	case "slow_disk_threshold":
+		c.SlowDisk.Threshold, err = parseDuration(value)
+	case "slow_disk_peer_factor":
+		c.SlowDisk.PeerFactor, err = strconv.ParseFloat(value, 64)
+	case "slow_disk_action":
+		var name string
+		if name, err = parseString(value); err == nil {
+			err = c.SlowDisk.Action.UnmarshalText([]byte(name))
+		}
+	default:
+		return fmt.Errorf("unknown key")
+	}
+	return err
+}
+
+// stripComment removes a # comment, unless the # is inside a string.
+func stripComment(line string) string {
+	quoted := false
+	for i, c := range line {
+		switch {
+		case c == '"' && (i == 0 || line[i-1] != '\\'):
+			quoted = !quoted
+		case c == '#' && !quoted:
+			return strings.TrimSpace(line[:i])
+		}
+	}
+	return strings.TrimSpace(line)
+}
+
+func parseInt(value string) (int, error) {
+	return strconv.Atoi(strings.ReplaceAll(value, "_", ""))
+}
+
+func parseString(value string) (string, error) {
+	if !strings.HasPrefix(value, `"`) {
+		return "", fmt.Errorf("expected a quoted string, got %s", value)
+	}
+	return strconv.Unquote(value)
+}
+
+func parseStrings(value string) ([]string, error) {
+	if !strings.HasPrefix(value, "[") || !strings.HasSuffix(value, "]") {
+		return nil, fmt.Errorf("expected an array, got %s", value)
+	}
+	var out []string
+	rest := strings.TrimSpace(value[1 : len(value)-1])
+	for rest != "" {
+		if !strings.HasPrefix(rest, `"`) {
+			return nil, fmt.Errorf("expected a quoted string at %s", rest)
+		}
+		end := 1
+		for end < len(rest) && (rest[end] != '"' || rest[end-1] == '\\') {
+			end++
+		}
+		if end == len(rest) {
+			return nil, fmt.Errorf("unterminated string %s", rest)
+		}
+		s, err := strconv.Unquote(rest[:end+1])
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+
+		rest = strings.TrimSpace(rest[end+1:])
+		rest = strings.TrimSpace(strings.TrimPrefix(rest, ","))
+	}
+	return out, nil
+}
+
+func parseName[T any](value string, names map[string]T) (T, error) {
+	var zero T
+	s, err := parseString(value)
+	if err != nil {
+		return zero, err
+	}
+	v, ok := names[s]
+	if !ok {
+		return zero, fmt.Errorf("unknown value %q", s)
+	}
+	return v, nil
+}
+
+func parseDuration(value string) (time.Duration, error) {
+	s, err := parseString(value)
+	if err != nil {
+		return 0, err
+	}
+	return time.ParseDuration(s)
+}
+
+// storedKeys are the config keys recorded in an array's metadata, each with
+// a check that the config still agrees with the array. The others, spares
+// and the simulation keys, are not part of the array.
+var storedKeys = []struct {
+	key  string
+	same func(c *arrayConfig, m *raid.ArrayMeta) bool
+}{
+	{"level", func(c *arrayConfig, m *raid.ArrayMeta) bool { return c.Level == m.Level }},
+	{"members", func(c *arrayConfig, m *raid.ArrayMeta) bool {
+		return slices.EqualFunc(c.DiskPaths, m.Members, func(p string, mm raid.MemberMeta) bool { return p == mm.Path })
+	}},
+	{"block_size", func(c *arrayConfig, m *raid.ArrayMeta) bool { return c.BlockSize == m.BlockSize }},
+	{"blocks_per_disk", func(c *arrayConfig, m *raid.ArrayMeta) bool { return c.BlocksPerDisk == m.BlocksPerDisk }},
+	{"remap_reserve", func(c *arrayConfig, m *raid.ArrayMeta) bool { return c.RemapReserve == m.RemapReserve }},
+	{"read_cache_blocks", func(c *arrayConfig, m *raid.ArrayMeta) bool { return c.ReadCacheBlocks == m.ReadCacheBlocks }},
+	{"stripe_cache_size", func(c *arrayConfig, m *raid.ArrayMeta) bool { return c.StripeCacheSize == m.StripeCacheSize }},
+	{"preferred_disk", func(c *arrayConfig, m *raid.ArrayMeta) bool { return c.PreferredDisk == m.PreferredDisk }},
+	{"verify_reads", func(c *arrayConfig, m *raid.ArrayMeta) bool { return c.VerifyReads == m.VerifyReads }},
+	{"io_uring", func(c *arrayConfig, m *raid.ArrayMeta) bool { return c.IOUring == m.IOUring }},
+	{"direct_io", func(c *arrayConfig, m *raid.ArrayMeta) bool { return c.DirectIO == m.DirectIO }},
+	{"mmap", func(c *arrayConfig, m *raid.ArrayMeta) bool { return c.Mmap == m.Mmap }},
+	{"rebuild_rate", func(c *arrayConfig, m *raid.ArrayMeta) bool { return c.RebuildRate == m.RebuildRate }},
+	{"probe_percent", func(c *arrayConfig, m *raid.ArrayMeta) bool { return c.ProbePercent == m.ProbePercent }},
+	{"event_log", func(c *arrayConfig, m *raid.ArrayMeta) bool { return c.EventLog == m.EventLog }},
+	{"sync_policy", func(c *arrayConfig, m *raid.ArrayMeta) bool { return c.SyncPolicy == m.SyncPolicy }},
+	{"read_policy", func(c *arrayConfig, m *raid.ArrayMeta) bool { return c.ReadPolicy == m.ReadPolicy }},
+	{"prealloc", func(c *arrayConfig, m *raid.ArrayMeta) bool { return c.Prealloc == m.Prealloc }},
+	{"sync_interval", func(c *arrayConfig, m *raid.ArrayMeta) bool { return c.SyncInterval == m.SyncInterval }},
+	{"slow_op_threshold", func(c *arrayConfig, m *raid.ArrayMeta) bool { return c.SlowOpThreshold == m.SlowOpThreshold }},
+	{"io_timeout", func(c *arrayConfig, m *raid.ArrayMeta) bool { return c.IOTimeout == m.IOTimeout }},
+	{"io_timeout_limit", func(c *arrayConfig, m *raid.ArrayMeta) bool { return c.IOTimeoutLimit == m.IOTimeoutLimit }},
+	{"io_retries", func(c *arrayConfig, m *raid.ArrayMeta) bool { return c.Retry.Attempts == m.Retry.Attempts }},
+	{"io_retry_backoff", func(c *arrayConfig, m *raid.ArrayMeta) bool { return c.Retry.Backoff == m.Retry.Backoff }},
+	{"io_retry_max_backoff", func(c *arrayConfig, m *raid.ArrayMeta) bool { return c.Retry.MaxBackoff == m.Retry.MaxBackoff }},
+	{"slow_disk_threshold", func(c *arrayConfig, m *raid.ArrayMeta) bool { return c.SlowDisk.Threshold == m.SlowDisk.Threshold }},
+	{"slow_disk_peer_factor", func(c *arrayConfig, m *raid.ArrayMeta) bool { return c.SlowDisk.PeerFactor == m.SlowDisk.PeerFactor }},
+	{"slow_disk_action", func(c *arrayConfig, m *raid.ArrayMeta) bool { return c.SlowDisk.Action == m.SlowDisk.Action }},
+}
+
+// conflicts returns the keys whose values differ from the array described
+// by meta.
+func (c *arrayConfig) conflicts(meta *raid.ArrayMeta) []string {
+	var keys []string
+	for _, k := range storedKeys {
+		if !k.same(c, meta) {
+			keys = append(keys, k.key)
+		}
+	}
+	return keys
+}
+
+// runUp creates the array described by the config file, or assembles it if
+// it was created before. Only the spares of an existing array can change;
+// any other difference from the recorded array is an error rather than
+// being ignored. The simulation keys take effect when the array is created.
+func runUp(metaPath string, args []string) error {
+	fs := flag.NewFlagSet("up", flag.ExitOnError)
+	configPath := fs.String("f", "raid.toml", "Array config file")
+	fs.Parse(args)
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+
+	if f, err := loadArrayFile(metaPath); err == nil {
+		var meta raid.ArrayMeta
+		if err := json.Unmarshal(f.Array, &meta); err != nil {
+			return fmt.Errorf("corrupt metadata file %s: %w", metaPath, err)
+		}
+		if keys := cfg.conflicts(&meta); len(keys) > 0 {
+			return fmt.Errorf("%s does not match the array recorded at %s: %s cannot change once the array is created",
+				*configPath, metaPath, strings.Join(keys, ", "))
+		}
+		if !f.Active || !slices.Equal(f.Spares, cfg.Spares) {
+			f.Active = true
+			f.Spares = cfg.Spares
+			if err := f.save(metaPath); err != nil {
+				return err
+			}
+		}
+		return runStatus(metaPath, nil)
+	}
+
+	for _, path := range slices.Concat(cfg.DiskPaths, cfg.Spares) {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return fmt.Errorf("failed to create disk directory: %w", err)
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(metaPath), 0755); err != nil {
+		return err
+	}
+
+	array, err := raid.NewRAIDArray(cfg.RAIDConfig)
+	if err != nil {
+		return err
+	}
+	if err := closeArray(metaPath, array, &arrayFile{Active: true, Spares: cfg.Spares}); err != nil {
+		return err
+	}
+	fmt.Printf("Created RAID %d array of %d disks, %d blocks of %d bytes\n",
+		cfg.Level, len(cfg.DiskPaths), array.Capacity(), cfg.BlockSize)
+	return nil
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arbhalerao/go-software-raid/pkg/raid"
+)
+
+func writeConfig(t *testing.T, text string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "raid.toml")
+	if err := os.WriteFile(path, []byte(text), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	return path
+}
+
+func TestLoadConfig(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		text  string
+		check func(t *testing.T, cfg *arrayConfig)
+		err   string // expected error substring, empty for success
+	}{
+		{
+			name: "defaults",
+			text: `members = ["a.img", "b.img", "c.img"]`,
+			check: func(t *testing.T, cfg *arrayConfig) {
+				if cfg.Level != raid.RAID5 || cfg.BlockSize != 4096 || cfg.BlocksPerDisk != 100 {
+					t.Errorf("Expected RAID 5 defaults, got level %d, %d blocks of %d bytes", cfg.Level, cfg.BlocksPerDisk, cfg.BlockSize)
+				}
+			},
+		},
+		{
+			name: "values",
+			text: `
+level = 1
+members = ["a.img", "b.img"]
+block_size = 512
+blocks_per_disk = 1_000
+verify_reads = false
+probe_percent = 2.5
+sync_policy = "periodic"
+sync_interval = "500ms"
+read_policy = "lowest_latency"
+slow_disk_action = "write_mostly"
+`,
+			check: func(t *testing.T, cfg *arrayConfig) {
+				if cfg.Level != raid.RAID1 || cfg.BlockSize != 512 || cfg.BlocksPerDisk != 1000 {
+					t.Errorf("Got level %d, %d blocks of %d bytes", cfg.Level, cfg.BlocksPerDisk, cfg.BlockSize)
+				}
+				if cfg.ProbePercent != 2.5 || cfg.SyncPolicy != raid.SyncPeriodic || cfg.SyncInterval != 500*time.Millisecond {
+					t.Errorf("Got probe %v, sync policy %v every %v", cfg.ProbePercent, cfg.SyncPolicy, cfg.SyncInterval)
+				}
+				if cfg.ReadPolicy != raid.ReadLowestLatency {
+					t.Errorf("Got read policy %v", cfg.ReadPolicy)
+				}
+				if cfg.SlowDisk.Action != raid.SlowDiskWriteMostly {
+					t.Errorf("Got slow disk action %v", cfg.SlowDisk.Action)
+				}
+			},
+		},
+		{
+			name: "comments",
+			text: `
+# a whole-line comment
+members = [
+  "a.img",  # first
+  "b.img",
+  # between elements
+  "c#1.img", # the # in the string stays
+]
+event_log = "events#1.log" # so does this one
+`,
+			check: func(t *testing.T, cfg *arrayConfig) {
+				if !slices.Equal(cfg.DiskPaths, []string{"a.img", "b.img", "c#1.img"}) {
+					t.Errorf("Got members %q", cfg.DiskPaths)
+				}
+				if cfg.EventLog != "events#1.log" {
+					t.Errorf("Got event log %q", cfg.EventLog)
+				}
+			},
+		},
+		{
+			name: "quoting",
+			text: `members = ["plain.img", "with space.img", "quote\".img", "comma,.img"]
+spares = []`,
+			check: func(t *testing.T, cfg *arrayConfig) {
+				want := []string{"plain.img", "with space.img", `quote".img`, "comma,.img"}
+				if !slices.Equal(cfg.DiskPaths, want) {
+					t.Errorf("Got members %q, want %q", cfg.DiskPaths, want)
+				}
+				if len(cfg.Spares) != 0 {
+					t.Errorf("Got spares %q", cfg.Spares)
+				}
+			},
+		},
+		{name: "section", text: "[array]\nmembers = [\"a.img\"]", err: "1: expected key = value"},
+		{name: "unknown key", text: "members = [\"a.img\"]\nstripe_width = 4", err: "2: stripe_width: unknown key"},
+		{name: "missing equals", text: "members [\"a.img\"]", err: "expected key = value"},
+		{name: "bad integer", text: "members = [\"a.img\"]\nlevel = \"5\"", err: "level: strconv.Atoi"},
+		{name: "bad boolean", text: "members = [\"a.img\"]\nmmap = yes", err: "mmap: strconv.ParseBool"},
+		{name: "unquoted string", text: "members = [\"a.img\"]\nsync_policy = periodic", err: "expected a quoted string"},
+		{name: "unknown name", text: "members = [\"a.img\"]\nsync_policy = \"sometimes\"", err: `unknown value "sometimes"`},
+		{name: "bad duration", text: "members = [\"a.img\"]\nsync_interval = \"soon\"", err: "sync_interval: time: invalid duration"},
+		{name: "unterminated array", text: "members = [\"a.img\",\n\"b.img\"", err: "1: members: expected an array"},
+		{name: "unterminated string", text: "members = [\"a.img]", err: "unterminated string"},
+		{name: "unquoted element", text: "members = [a.img]", err: "expected a quoted string at a.img"},
+		{name: "no members", text: "level = 1", err: "no members defined"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg, err := loadConfig(writeConfig(t, tc.text))
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Fatalf("Expected an error containing %q, got %v", tc.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Failed to load config: %v", err)
+			}
+			tc.check(t, cfg)
+		})
+	}
+}
+
+func TestUp(t *testing.T) {
+	dir := t.TempDir()
+	metaPath := filepath.Join(dir, "array.json")
+	spare := filepath.Join(dir, "spare.img")
+	config := writeConfig(t, `level = 5
+members = ["`+filepath.Join(dir, "d0.img")+`", "`+filepath.Join(dir, "d1.img")+`", "`+filepath.Join(dir, "d2.img")+`"]
+spares = ["`+spare+`"]
+block_size = 512
+blocks_per_disk = 16`)
+
+	if err := runUp(metaPath, []string{"-f", config}); err != nil {
+		t.Fatalf("Failed to create array: %v", err)
+	}
+	f, err := loadArrayFile(metaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !f.Active || !slices.Equal(f.Spares, []string{spare}) {
+		t.Errorf("Expected an assembled array with spares %q, got active %v and spares %q", spare, f.Active, f.Spares)
+	}
+
+	// up on a stopped array assembles it
+	if err := runStop(metaPath, nil); err != nil {
+		t.Fatalf("Failed to stop array: %v", err)
+	}
+	if err := runUp(metaPath, []string{"-f", config}); err != nil {
+		t.Fatalf("Failed to assemble array: %v", err)
+	}
+	if f, err = loadArrayFile(metaPath); err != nil {
+		t.Fatal(err)
+	}
+	if !f.Active {
+		t.Error("Expected up to assemble the stopped array")
+	}
+	var meta raid.ArrayMeta
+	if err := json.Unmarshal(f.Array, &meta); err != nil {
+		t.Fatal(err)
+	}
+	if meta.BlockSize != 512 || meta.BlocksPerDisk != 16 || len(meta.Members) != 3 {
+		t.Errorf("Expected the configured geometry, got %d blocks of %d bytes on %d members", meta.BlocksPerDisk, meta.BlockSize, len(meta.Members))
+	}
+}
+
+func TestUpExistingArray(t *testing.T) {
+	dir := t.TempDir()
+	metaPath := filepath.Join(dir, "array.json")
+	config := func(blocks, spares string) string {
+		return writeConfig(t, `level = 5
+members = ["`+filepath.Join(dir, "d0.img")+`", "`+filepath.Join(dir, "d1.img")+`", "`+filepath.Join(dir, "d2.img")+`"]
+spares = [`+spares+`]
+block_size = 512
+blocks_per_disk = `+blocks)
+	}
+
+	if err := runUp(metaPath, []string{"-f", config("16", "")}); err != nil {
+		t.Fatalf("Failed to create array: %v", err)
+	}
+
+	spare := filepath.Join(dir, "spare.img")
+	if err := runUp(metaPath, []string{"-f", config("16", `"`+spare+`"`)}); err != nil {
+		t.Fatalf("Expected a change of spares to be applied, got %v", err)
+	}
+	f, err := loadArrayFile(metaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(f.Spares, []string{spare}) {
+		t.Errorf("Got spares %q after up", f.Spares)
+	}
+
+	err = runUp(metaPath, []string{"-f", config("32", "")})
+	if err == nil || !strings.Contains(err.Error(), "blocks_per_disk cannot change") {
+		t.Fatalf("Expected a changed geometry to be refused, got %v", err)
+	}
+	f, err = loadArrayFile(metaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var meta raid.ArrayMeta
+	if err := json.Unmarshal(f.Array, &meta); err != nil {
+		t.Fatal(err)
+	}
+	if meta.BlocksPerDisk != 16 || !slices.Equal(f.Spares, []string{spare}) {
+		t.Errorf("Expected the refused config to leave the array alone, got %d blocks per disk and spares %q", meta.BlocksPerDisk, f.Spares)
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	"github.com/arbhalerao/go-software-raid/pkg/raid"
+)
+
+// runDaemon keeps the array open and serves it over HTTP, and optionally
+// gRPC, NBD and FUSE, until interrupted.
+// Metadata is saved after every array event, so failures recorded while it
+// runs survive a crash.
+func runDaemon(metaPath string, args []string) error {
+	fs := flag.NewFlagSet("daemon", flag.ExitOnError)
+	listen := fs.String("listen", "localhost:8080", "HTTP listen address")
+	grpcAddr := fs.String("grpc", "", "Also serve the gRPC control API on this address, such as localhost:9090")
+	nbdAddr := fs.String("nbd", "", "Also export the array over NBD on this address, such as localhost:10809")
+	fuseDir := fs.String("fuse", "", "Also mount the array as a file in this directory")
+	fs.Parse(args)
+
+	array, f, err := openArray(metaPath)
+	if err != nil {
+		return err
+	}
+
+	var grpcSrv *http.Server
+	if *grpcAddr != "" {
+		l, err := net.Listen("tcp", *grpcAddr)
+		if err != nil {
+			array.Close()
+			return err
+		}
+		var protocols http.Protocols
+		protocols.SetUnencryptedHTTP2(true)
+		grpcSrv = &http.Server{Handler: array.GRPCHandler(), Protocols: &protocols}
+		go grpcSrv.Serve(l)
+		fmt.Printf("Serving the gRPC control API on %s\n", *grpcAddr)
+	}
+
+	var nbd net.Listener
+	if *nbdAddr != "" {
+		if nbd, err = net.Listen("tcp", *nbdAddr); err != nil {
+			if grpcSrv != nil {
+				grpcSrv.Close()
+			}
+			array.Close()
+			return err
+		}
+		go raid.ServeNBD(nbd, array)
+		fmt.Printf("Exporting over NBD on %s\n", *nbdAddr)
+	}
+
+	var mount *raid.FUSEMount
+	if *fuseDir != "" {
+		if mount, err = raid.MountFUSE(array, *fuseDir); err != nil {
+			if grpcSrv != nil {
+				grpcSrv.Close()
+			}
+			if nbd != nil {
+				nbd.Close()
+			}
+			array.Close()
+			return err
+		}
+		fmt.Printf("Mounted on %s\n", filepath.Join(*fuseDir, "array.img"))
+	}
+
+	events, cancel := array.Subscribe(16)
+	saved := make(chan struct{})
+	go func() {
+		defer close(saved)
+		for range events {
+			meta, err := array.ExportMeta()
+			if err == nil {
+				f.Array = meta
+				err = f.save(metaPath)
+			}
+			if err != nil {
+				fmt.Printf("failed to save metadata: %v\n", err)
+			}
+		}
+	}()
+
+	srv := &http.Server{Addr: *listen, Handler: array.Handler()}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		srv.Shutdown(context.Background())
+	}()
+
+	fmt.Printf("Serving RAID %d array on http://%s\n", array.Level(), *listen)
+	err = srv.ListenAndServe()
+	if errors.Is(err, http.ErrServerClosed) {
+		err = nil
+	}
+
+	if grpcSrv != nil {
+		grpcSrv.Close() // ends the streams, which would otherwise run until the array closes
+	}
+	if nbd != nil {
+		nbd.Close()
+	}
+	if mount != nil {
+		if umountErr := mount.Close(); err == nil {
+			err = umountErr
+		}
+	}
+	cancel()
+	<-saved
+	if closeErr := closeArray(metaPath, array, f); err == nil {
+		err = closeErr
+	}
+	return err
+}
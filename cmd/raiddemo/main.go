@@ -0,0 +1,175 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/arbhalerao/go-software-raid/pkg/raid"
+)
+
+// errUnclean ends a command that ran but found problems, such as a check
+// with mismatches. The command has printed its report already.
+var errUnclean = errors.New("unclean")
+
+var jsonOutput = flag.Bool("json", false, "Print status, stats, check and scrub results as JSON")
+
+type command struct {
+	name  string
+	usage string
+	run   func(metaPath string, args []string) error
+}
+
+var commands = []command{
+	{"up", "[-f raid.toml]", runUp},
+	{"create", "[-level 5] [-disks N] [-block-size 4096] [-blocks 100] [-dir dir] [-io-uring] [-direct] [-mmap]", runCreate},
+	{"assemble", "", runAssemble},
+	{"stop", "", runStop},
+	{"status", "[-mdstat]", runStatus},
+	{"stats", "", runStats},
+	{"smart", "", runSMART},
+	{"events", "", runEvents},
+	{"volume", "[list] | create <name> <blocks> | resize <name> <blocks> | delete <name>", runVolume},
+	{"fail", "<disk>", runFail},
+	{"remove", "<disk>", runRemove},
+	{"add", "<disk> [path]", runAdd},
+	{"rebuild", "<disk>", runRebuild},
+	{"scrub", "[-repair] [-rate stripes/s]", runScrub},
+	{"check", "", runCheck},
+	{"repair", "[stripe...]", runRepair},
+	{"verify-export", "<image>", runVerifyExport},
+	{"inspect", "-block N", runInspect},
+	{"layout", "[-level 5 -disks 4] [-first 0] [-stripes 8]", runLayout},
+	{"md-examine", "[-export image] <device...>", runMDExamine},
+	{"shell", "", runShell},
+	{"daemon", "[-listen localhost:8080] [-grpc addr] [-nbd addr] [-fuse dir]", runDaemon},
+	{"bench", "[-levels 0,1,5] [-workloads seq_write,...] [-qd 1] [-ops N] [-block-size 4096] [-blocks 1000] [-latency none|ssd|hdd] [-slow N] [-read-policy first]", runBench},
+	{"demo", "[-level 5] [-block-size 4096] [-blocks 100] [-io-uring] [-direct] [-mmap] [-remote addr,...]", runDemo},
+}
+
+func main() {
+	metaPath := flag.String("array", "disks/array.json", "Array metadata file")
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	for _, cmd := range commands {
+		if cmd.name != flag.Arg(0) {
+			continue
+		}
+		err := cmd.run(*metaPath, flag.Args()[1:])
+		if errors.Is(err, errUnclean) {
+			os.Exit(1)
+		}
+		if err != nil && *jsonOutput {
+			printJSON(map[string]string{"command": cmd.name, "error": err.Error()})
+			os.Exit(1)
+		}
+		if err != nil {
+			fmt.Printf("%s: %v\n", cmd.name, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	fmt.Printf("Unknown command: %s\n", flag.Arg(0))
+	usage()
+	os.Exit(2)
+}
+
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), "Usage: raiddemo [-array file] [-json] <command> [args]\n\nCommands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintln(flag.CommandLine.Output(), strings.TrimRight("  "+cmd.name+" "+cmd.usage, " "))
+	}
+}
+
+// arrayFile is the metadata file: the array definition from
+// raid.ExportMeta, plus whether the array is assembled and the spare images
+// left for replacements.
+type arrayFile struct {
+	Active bool            `json:"active"`
+	Array  json.RawMessage `json:"array"`
+	Spares []string        `json:"spares,omitempty"`
+}
+
+func loadArrayFile(path string) (*arrayFile, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("no array at %s, run create first: %w", path, err)
+	}
+	var f arrayFile
+	if err := json.Unmarshal(raw, &f); err != nil {
+		return nil, fmt.Errorf("corrupt metadata file %s: %w", path, err)
+	}
+	return &f, nil
+}
+
+func (f *arrayFile) save(path string) error {
+	raw, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, raw, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// openArray assembles the array recorded at path. It fails if the array was
+// stopped.
+func openArray(path string) (*raid.RAIDArray, *arrayFile, error) {
+	f, err := loadArrayFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !f.Active {
+		return nil, nil, fmt.Errorf("array is stopped, run assemble first")
+	}
+	array, err := raid.ImportMeta(f.Array, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	return array, f, nil
+}
+
+// closeArray records the array's current state at path and closes it.
+func closeArray(path string, array *raid.RAIDArray, f *arrayFile) error {
+	meta, err := array.ExportMeta()
+	if err == nil {
+		f.Array = meta
+		err = f.save(path)
+	}
+	if closeErr := array.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// withArray opens the array, runs fn and saves the state fn left behind,
+// even when fn fails.
+func withArray(path string, fn func(*raid.RAIDArray) error) error {
+	array, f, err := openArray(path)
+	if err != nil {
+		return err
+	}
+	err = fn(array)
+	if saveErr := closeArray(path, array, f); err == nil {
+		err = saveErr
+	}
+	return err
+}
+
+func printJSON(v any) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
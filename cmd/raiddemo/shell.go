@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/arbhalerao/go-software-raid/pkg/raid"
+)
+
+const shellHelp = `Commands:
+  write <block> <text>   write text, quoted or not, to a logical block
+  read <block>           print a logical block
+  fail <disk>            fail a member
+  rebuild <disk>         rebuild a failed member
+  scrub [repair]         verify every stripe, optionally repairing
+  status                 array state and health
+  stats                  per-disk statistics
+  smart                  simulated SMART attributes and failure predictions
+  badblock <disk> <n>    make a member block unreadable
+  help                   this list
+  quit                   leave, keeping the array as it is`
+
+// runShell reads commands from stdin and runs them against the array, so
+// the effect of each write, failure and rebuild can be watched as it
+// happens.
+func runShell(metaPath string, _ []string) error {
+	return withArray(metaPath, func(array *raid.RAIDArray) error {
+		fmt.Printf("RAID %d array of %d disks, %d blocks. Type help for commands.\n",
+			array.Level(), len(array.GetStats()), array.Capacity())
+
+		in := bufio.NewScanner(os.Stdin)
+		for {
+			fmt.Print("raid> ")
+			if !in.Scan() {
+				fmt.Println()
+				return in.Err()
+			}
+			line := strings.TrimSpace(in.Text())
+			if line == "quit" || line == "exit" {
+				return nil
+			}
+			if err := shellCommand(array, line); err != nil {
+				fmt.Printf("error: %v\n", err)
+			}
+		}
+	})
+}
+
+func shellCommand(array *raid.RAIDArray, line string) error {
+	name, rest, _ := strings.Cut(line, " ")
+	rest = strings.TrimSpace(rest)
+
+	switch name {
+	case "":
+		return nil
+	case "help":
+		fmt.Println(shellHelp)
+		return nil
+	case "status":
+		printStatus(array)
+		return nil
+	case "stats":
+		printDiskStats(array)
+		return nil
+	case "smart":
+		printSMART(array)
+		return nil
+	case "scrub":
+		result, err := array.Scrub(context.Background(), raid.ScrubOptions{Repair: rest == "repair"})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Scrubbed %d stripes: %d mismatches, %d repaired, %d skipped\n",
+			result.Stripes, len(result.Mismatches), result.Repaired, result.Skipped)
+		printStripes(result.Mismatches)
+		return nil
+	case "write", "read", "fail", "rebuild", "badblock":
+	default:
+		return fmt.Errorf("unknown command %q, type help for a list", name)
+	}
+
+	arg, text, _ := strings.Cut(rest, " ")
+	n, err := strconv.Atoi(arg)
+	if err != nil {
+		return fmt.Errorf("%s needs a block or disk number", name)
+	}
+
+	switch name {
+	case "write":
+		text = strings.TrimSpace(text)
+		if strings.HasPrefix(text, `"`) {
+			if text, err = strconv.Unquote(text); err != nil {
+				return fmt.Errorf("bad quoted text: %w", err)
+			}
+		}
+		data := make([]byte, array.StripeSize()/array.BlocksPerStripe())
+		if len(text) > len(data) {
+			return fmt.Errorf("text is %d bytes, a block holds %d", len(text), len(data))
+		}
+		copy(data, text)
+		if err := array.WriteBlock(n, data); err != nil {
+			return err
+		}
+		fmt.Printf("Block %d written\n", n)
+	case "read":
+		data, err := array.ReadBlock(n)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Block %d: %q\n", n, strings.TrimRight(string(data), "\x00"))
+	case "fail":
+		if err := array.FailDisk(n); err != nil {
+			return err
+		}
+		fmt.Printf("Disk %d failed, array is %s\n", n, array.State().State)
+	case "rebuild":
+		if err := array.RebuildDisk(context.Background(), n); err != nil {
+			return err
+		}
+		fmt.Printf("Disk %d rebuilt, array is %s\n", n, array.State().State)
+	case "badblock":
+		block, err := strconv.Atoi(strings.TrimSpace(text))
+		if err != nil {
+			return fmt.Errorf("badblock needs a disk and a block number")
+		}
+		if err := array.SimulateMediaError(n, block); err != nil {
+			return err
+		}
+		fmt.Printf("Block %d of disk %d is unreadable\n", block, n)
+	}
+	return nil
+}
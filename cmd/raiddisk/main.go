@@ -0,0 +1,51 @@
+// Command raiddisk serves a disk image over TCP so an array on another
+// machine can use it as a member through raid.DialBackend.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+
+	"github.com/arbhalerao/go-software-raid/pkg/raid"
+)
+
+func main() {
+	listen := flag.String("listen", ":7420", "Address to listen on")
+	image := flag.String("image", "disk.img", "Image file or block device to serve")
+	blockSize := flag.Int("block-size", 4096, "Block size in bytes")
+	blocks := flag.Int("blocks", 100, "Blocks in the image, including any remap reserve the array uses")
+	flag.Parse()
+
+	backend, err := raid.NewFileBackend(*image, *blockSize, *blocks)
+	if err != nil {
+		fmt.Printf("Failed to open image: %v\n", err)
+		os.Exit(1)
+	}
+	defer backend.Close()
+
+	ln, err := net.Listen("tcp", *listen)
+	if err != nil {
+		fmt.Printf("Failed to listen: %v\n", err)
+		os.Exit(1)
+	}
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt)
+	go func() {
+		<-stop
+		ln.Close()
+	}()
+
+	fmt.Printf("Serving %s on %s\n", *image, ln.Addr())
+	if err := raid.ServeBackend(ln, backend); err != nil {
+		fmt.Printf("Server stopped: %v\n", err)
+		os.Exit(1)
+	}
+	if err := backend.Sync(); err != nil {
+		fmt.Printf("Sync failed: %v\n", err)
+		os.Exit(1)
+	}
+}
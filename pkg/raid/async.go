@@ -0,0 +1,58 @@
+package raid
+
+// Completion tracks a block operation started with ReadBlockAsync or
+// WriteBlockAsync.
+type Completion struct {
+	done chan struct{}
+	data []byte
+	err  error
+}
+
+// Done is closed once the operation has finished.
+func (c *Completion) Done() <-chan struct{} {
+	return c.done
+}
+
+// Wait blocks until the operation finishes and returns its result. Data is
+// nil for writes.
+func (c *Completion) Wait() ([]byte, error) {
+	<-c.done
+	return c.data, c.err
+}
+
+// ReadBlockAsync starts reading a logical block and returns immediately.
+func (r *RAIDArray) ReadBlockAsync(logicalBlockID int) *Completion {
+	return r.startAsync(func() ([]byte, error) {
+		return r.ReadBlock(logicalBlockID)
+	})
+}
+
+// WriteBlockAsync starts writing a logical block and returns immediately.
+// data must not be modified until the operation completes.
+func (r *RAIDArray) WriteBlockAsync(logicalBlockID int, data []byte) *Completion {
+	return r.startAsync(func() ([]byte, error) {
+		return nil, r.WriteBlock(logicalBlockID, data)
+	})
+}
+
+func (r *RAIDArray) startAsync(op func() ([]byte, error)) *Completion {
+	c := &Completion{done: make(chan struct{})}
+
+	select {
+	case <-r.done:
+		c.err = ErrClosed
+		close(c.done)
+		return c
+	default:
+	}
+
+	r.async.Add(1)
+	r.inflight.RLock() // released by the operation, Flush waits for it
+	go func() {
+		defer r.async.Done()
+		defer r.inflight.RUnlock()
+		c.data, c.err = op()
+		close(c.done)
+	}()
+	return c
+}
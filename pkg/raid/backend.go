@@ -0,0 +1,372 @@
+package raid
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime/debug"
+	"unsafe"
+)
+
+// Backend is the storage under a Disk. Blocks are physical: the data blocks
+// come first, followed by the disk's remap reserve. The Disk handles
+// failure state, remapping, quirks and counters, and serialises writes; a
+// Backend may see concurrent reads.
+type Backend interface {
+	ReadBlock(block int, buf []byte) error
+	WriteBlock(block int, data []byte) error
+	Capacity() int // physical blocks, data plus remap reserve
+	BlockSize() int
+	Sync() error
+	Close() error
+	Health() error // nil while the storage is reachable
+}
+
+var _ Backend = (*fileBackend)(nil)
+
+// fileBackend keeps the image in a regular file or on a block device. It is
+// the default backend.
+type fileBackend struct {
+	file      *os.File
+	path      string
+	blockSize int
+	blocks    int
+	prealloc  PreallocMode
+	device    bool // a block device, never resized
+	noHoles   bool // the filesystem cannot punch holes, zero blocks are written out
+
+	mirror    *os.File // migration target receiving every write
+	mirrorErr error
+
+	ring     *ioRing // io_uring submission path, nil for plain pread/pwrite
+	ownsRing bool
+	direct   bool   // opened with O_DIRECT, transfers go through aligned buffers
+	mapped   []byte // the whole image when memory-mapped, nil otherwise
+}
+
+// openFileBackend opens or creates the image at path, sized for blocks
+// blocks according to opts.Prealloc. A block device is used as it is and
+// must already be large enough.
+func openFileBackend(path string, blockSize, blocks int, opts DiskOptions) (*fileBackend, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open disk %s: %w", path, err)
+	}
+
+	requiredSize := int64(blockSize) * int64(blocks)
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	device := info.Mode()&os.ModeDevice != 0
+	if device {
+		if err := checkDevice(file, info, requiredSize); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("cannot use %s: %w", path, err)
+		}
+	} else {
+		switch opts.Prealloc {
+		case PreallocSparse:
+			if info.Size() < requiredSize {
+				err = file.Truncate(requiredSize)
+			}
+		case PreallocFull:
+			err = allocate(file, requiredSize)
+		case PreallocNone:
+		default:
+			err = fmt.Errorf("unknown preallocation mode %d", opts.Prealloc)
+		}
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to resize disk: %w", err)
+		}
+	}
+
+	if opts.DirectIO { // sized through the buffered handle, zeroFill is unaligned
+		direct, err := os.OpenFile(path, os.O_RDWR|directFlag, 0)
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to open disk %s for direct I/O: %w", path, err)
+		}
+		file = direct
+	}
+
+	var mapped []byte
+	if opts.Mmap {
+		if mapped, err = mapImage(file, int(requiredSize)); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to map disk %s: %w", path, err)
+		}
+	}
+
+	ring, ownsRing := opts.ring, false
+	if opts.IOUring && ring == nil {
+		if ring, err = newIORing(); err != nil {
+			if mapped != nil {
+				unmapImage(mapped)
+			}
+			file.Close()
+			return nil, err
+		}
+		ownsRing = true
+	}
+
+	return &fileBackend{
+		file:      file,
+		path:      path,
+		blockSize: blockSize,
+		blocks:    blocks,
+		prealloc:  opts.Prealloc,
+		device:    device,
+		ring:      ring,
+		ownsRing:  ownsRing,
+		direct:    opts.DirectIO,
+		mapped:    mapped,
+	}, nil
+}
+
+// NewFileBackend opens or creates a sparse image at path holding blocks
+// blocks, for serving with ServeBackend.
+func NewFileBackend(path string, blockSize, blocks int) (Backend, error) {
+	if blockSize <= 0 || blocks <= 0 {
+		return nil, fmt.Errorf("block size and block count must be positive, got %d and %d", blockSize, blocks)
+	}
+	b, err := openFileBackend(path, blockSize, blocks, DiskOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// checkDevice makes sure the block device open as file holds at least
+// size bytes.
+func checkDevice(file *os.File, info os.FileInfo, size int64) error {
+	if info.Mode()&os.ModeCharDevice != 0 {
+		return fmt.Errorf("not a block device")
+	}
+	have, err := deviceSize(file)
+	if err != nil {
+		return fmt.Errorf("failed to read device size: %w", err)
+	}
+	if have < size {
+		return fmt.Errorf("device holds %d bytes, need %d", have, size)
+	}
+	return nil
+}
+
+func (b *fileBackend) ReadBlock(block int, buf []byte) error {
+	n, err := b.readFull(buf, b.offset(block))
+	if err != nil {
+		return err
+	}
+	if n != len(buf) {
+		return fmt.Errorf("%w: read on %s expected %d bytes, got %d", ErrShortIO, b.path, len(buf), n)
+	}
+	return nil
+}
+
+// WriteBlock writes a block. On a sparse image a block of zeros is punched
+// out instead, so it takes no space.
+func (b *fileBackend) WriteBlock(block int, data []byte) error {
+	off := b.offset(block)
+
+	if b.sparse() && isZero(data) {
+		err := b.punch(off, int64(len(data)))
+		if !errors.Is(err, errors.ErrUnsupported) {
+			return err
+		}
+		b.noHoles = true
+	}
+
+	var n int
+	var err error
+	if b.mapped != nil {
+		n, err = copyMapped(b.mapped[off:off+int64(len(data))], data)
+	} else {
+		n, err = b.pwrite(b.file, data, off)
+	}
+	if err == nil && b.mirror != nil && b.mirrorErr == nil {
+		_, b.mirrorErr = b.pwrite(b.mirror, data, off)
+	}
+	if err != nil {
+		return err
+	}
+	if n != len(data) {
+		return fmt.Errorf("%w: write on %s expected %d bytes, wrote %d", ErrShortIO, b.path, len(data), n)
+	}
+	return nil
+}
+
+// sparse reports whether blocks of zeros are left unallocated.
+func (b *fileBackend) sparse() bool {
+	return b.prealloc != PreallocFull && !b.device && b.mapped == nil && !b.noHoles
+}
+
+func (b *fileBackend) punch(off, size int64) error {
+	if err := punchHole(b.file, off, size); err != nil {
+		return err
+	}
+	if b.mirror != nil && b.mirrorErr == nil {
+		if err := punchHole(b.mirror, off, size); errors.Is(err, errors.ErrUnsupported) {
+			_, b.mirrorErr = b.pwrite(b.mirror, make([]byte, size), off)
+		} else {
+			b.mirrorErr = err
+		}
+	}
+	return nil
+}
+
+// allocated returns the bytes the image takes on its filesystem, or -1 if
+// that is not known.
+func (b *fileBackend) allocated() int64 {
+	if b.device {
+		return -1
+	}
+	n, _, _, err := storageUsage(b.path)
+	if err != nil {
+		return -1
+	}
+	return n
+}
+
+func (b *fileBackend) Capacity() int {
+	return b.blocks
+}
+
+func (b *fileBackend) BlockSize() int {
+	return b.blockSize
+}
+
+// Sync makes written blocks durable: msync for a mapped image, then fsync.
+func (b *fileBackend) Sync() error {
+	if b.mapped != nil {
+		if err := syncImage(b.mapped); err != nil {
+			return err
+		}
+	}
+	return b.file.Sync()
+}
+
+func (b *fileBackend) Close() error {
+	if b.ownsRing {
+		b.ring.Close()
+	}
+	if b.mapped != nil {
+		unmapImage(b.mapped)
+		b.mapped = nil
+	}
+	if b.file != nil {
+		return b.file.Close()
+	}
+	return nil
+}
+
+func (b *fileBackend) Health() error {
+	_, err := b.file.Stat()
+	return err
+}
+
+func (b *fileBackend) offset(block int) int64 {
+	return int64(block) * int64(b.blockSize)
+}
+
+// readFull reads len(buf) bytes at off. Images that grow on demand read
+// zeros for blocks that were never written.
+func (b *fileBackend) readFull(buf []byte, off int64) (int, error) {
+	if b.mapped != nil {
+		return copyMapped(buf, b.mapped[off:])
+	}
+	n, err := b.pread(b.file, buf, off)
+	if err == io.EOF && b.prealloc == PreallocNone {
+		clear(buf[n:])
+		return len(buf), nil
+	}
+	return n, err
+}
+
+func (b *fileBackend) pread(f *os.File, buf []byte, off int64) (int, error) {
+	if b.direct && !isAligned(buf) {
+		bounce := alignedBuffer(len(buf))
+		n, err := b.pread(f, bounce, off)
+		copy(buf, bounce[:n])
+		return n, err
+	}
+	if b.ring != nil {
+		return b.ring.ReadAt(f, buf, off)
+	}
+	return f.ReadAt(buf, off)
+}
+
+func (b *fileBackend) pwrite(f *os.File, buf []byte, off int64) (int, error) {
+	if b.direct && !isAligned(buf) {
+		bounce := alignedBuffer(len(buf))
+		copy(bounce, buf)
+		return b.pwrite(f, bounce, off)
+	}
+	if b.ring != nil {
+		return b.ring.WriteAt(f, buf, off)
+	}
+	return f.WriteAt(buf, off)
+}
+
+// copyMapped copies to or from the image mapping. A fault, such as the
+// filesystem failing to allocate a sparse page, becomes an error rather
+// than crashing the process.
+func copyMapped(dst, src []byte) (n int, err error) {
+	old := debug.SetPanicOnFault(true)
+	defer func() {
+		debug.SetPanicOnFault(old)
+		if recover() != nil {
+			n, err = 0, errMappedFault
+		}
+	}()
+	return copy(dst, src), nil
+}
+
+// alignedBuffer returns a zeroed n-byte slice starting on a directAlignment
+// boundary.
+func alignedBuffer(n int) []byte {
+	raw := make([]byte, n+directAlignment)
+	shift := 0
+	if rem := int(uintptr(unsafe.Pointer(&raw[0])) % directAlignment); rem != 0 {
+		shift = directAlignment - rem
+	}
+	return raw[shift : shift+n : shift+n]
+}
+
+func isAligned(buf []byte) bool {
+	return len(buf)%directAlignment == 0 &&
+		(len(buf) == 0 || uintptr(unsafe.Pointer(&buf[0]))%directAlignment == 0)
+}
+
+func isZero(data []byte) bool {
+	for _, c := range data {
+		if c != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// zeroFill extends the file to size by writing zeros past its current end.
+func zeroFill(file *os.File, size int64) error {
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	zeros := make([]byte, 64*1024)
+	for off := info.Size(); off < size; off += int64(len(zeros)) {
+		chunk := zeros
+		if size-off < int64(len(chunk)) {
+			chunk = chunk[:size-off]
+		}
+		if _, err := file.WriteAt(chunk, off); err != nil {
+			return err
+		}
+	}
+	return nil
+}
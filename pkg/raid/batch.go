@@ -0,0 +1,182 @@
+package raid
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// ReadBlocks reads many logical blocks at once. Blocks are grouped by the
+// member that serves them and each group is read concurrently.
+func (r *RAIDArray) ReadBlocks(blockIDs []int) ([][]byte, error) {
+	for _, id := range blockIDs {
+		if id < 0 || id >= r.capacity {
+			return nil, outOfRange("logical block", id, r.capacity)
+		}
+	}
+
+	out := make([][]byte, len(blockIDs))
+	err := r.forEachDiskGroup(blockIDs, func(i int) error {
+		data, err := r.ReadBlock(blockIDs[i])
+		if err != nil {
+			return err
+		}
+		out[i] = data
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ReadRange reads count contiguous logical blocks starting at start into
+// one buffer. On RAID 0 consecutive blocks live on different members, so
+// every disk streams its share in parallel.
+func (r *RAIDArray) ReadRange(start, count int) ([]byte, error) {
+	if count < 0 || start < 0 || start+count > r.capacity {
+		return nil, fmt.Errorf("%w: range [%d, %d) not in [0, %d)", ErrBlockOutOfRange, start, start+count, r.capacity)
+	}
+
+	blockIDs := make([]int, count)
+	for i := range blockIDs {
+		blockIDs[i] = start + i
+	}
+
+	out := make([]byte, count*r.blockSize)
+	err := r.forEachDiskGroup(blockIDs, func(i int) error {
+		data, err := r.ReadBlock(blockIDs[i])
+		if err != nil {
+			return err
+		}
+		copy(out[i*r.blockSize:], data)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// WriteBlocks writes data[i] to blockIDs[i], issuing the per-member groups
+// concurrently. Writes to the same block are applied in slice order.
+func (r *RAIDArray) WriteBlocks(blockIDs []int, data [][]byte) error {
+	if len(blockIDs) != len(data) {
+		return fmt.Errorf("got %d block IDs but %d data blocks", len(blockIDs), len(data))
+	}
+	for i, id := range blockIDs {
+		if id < 0 || id >= r.capacity {
+			return outOfRange("logical block", id, r.capacity)
+		}
+		if len(data[i]) != r.blockSize {
+			return fmt.Errorf("data size must match block size %d", r.blockSize)
+		}
+	}
+
+	if r.level == RAID5 {
+		full, rest := r.raid5.fullStripes(blockIDs)
+		for stripe, idx := range full {
+			blocks := make([][]byte, len(idx))
+			for j, i := range idx {
+				blocks[j] = data[i]
+			}
+			if err := r.checkWritable(); err != nil {
+				return err
+			}
+			err := r.raid5.writeStripe(context.Background(), stripe, blocks)
+			if r.cache != nil {
+				for _, i := range idx {
+					r.cache.invalidate(blockIDs[i])
+				}
+			}
+			if err != nil {
+				r.noteWriteError(err)
+				return fmt.Errorf("stripe %d: %w", stripe, err)
+			}
+		}
+
+		restIDs := make([]int, len(rest))
+		restData := make([][]byte, len(rest))
+		for j, i := range rest {
+			restIDs[j] = blockIDs[i]
+			restData[j] = data[i]
+		}
+		blockIDs, data = restIDs, restData
+	}
+
+	return r.forEachDiskGroup(blockIDs, func(i int) error {
+		return r.WriteBlock(blockIDs[i], data[i])
+	})
+}
+
+// discardChunk bounds how many blocks Discard hands to WriteBlocks at once.
+const discardChunk = 1024
+
+// Discard zeroes count logical blocks starting at start. Whole RAID 5
+// stripes are written without reading anything. Members remember which
+// blocks hold zeros, so later parity updates and reconstructions skip
+// reading them, and sparse images give the space back.
+func (r *RAIDArray) Discard(start, count int) error {
+	if count < 0 || start < 0 || start+count > r.capacity {
+		return fmt.Errorf("%w: discard of %d blocks at %d exceeds capacity %d", ErrBlockOutOfRange, count, start, r.capacity)
+	}
+
+	zeros := make([]byte, r.blockSize)
+	for done := 0; done < count; done += discardChunk {
+		n := min(discardChunk, count-done)
+		blockIDs := make([]int, n)
+		data := make([][]byte, n)
+		for i := range blockIDs {
+			blockIDs[i] = start + done + i
+			data[i] = zeros
+		}
+		if err := r.WriteBlocks(blockIDs, data); err != nil {
+			return fmt.Errorf("discard failed: %w", err)
+		}
+	}
+	return nil
+}
+
+// forEachDiskGroup calls fn with the index of every block ID, running one
+// goroutine per member disk so each disk sees a sequential stream.
+func (r *RAIDArray) forEachDiskGroup(blockIDs []int, fn func(i int) error) error {
+	groups := make([][]int, r.numDisks)
+	for i, id := range blockIDs {
+		d := r.homeDisk(id)
+		groups[d] = append(groups[d], i)
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, r.numDisks)
+	for d, group := range groups {
+		if len(group) == 0 {
+			continue
+		}
+		wg.Add(1)
+		go func(d int, group []int) {
+			defer wg.Done()
+			for _, i := range group {
+				if err := fn(i); err != nil {
+					errs[d] = fmt.Errorf("block %d: %w", blockIDs[i], err)
+					return
+				}
+			}
+		}(d, group)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *RAIDArray) homeDisk(logicalBlockID int) int {
+	if r.level == RAID5 {
+		_, dataDisk, _ := r.raid5.locate(logicalBlockID)
+		return dataDisk
+	}
+	return logicalBlockID % r.numDisks
+}
@@ -0,0 +1,164 @@
+package raid
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type Workload int
+
+const (
+	SeqRead Workload = iota
+	SeqWrite
+	RandRead
+	RandWrite
+)
+
+var workloadNames = [...]string{
+	SeqRead:   "seq_read",
+	SeqWrite:  "seq_write",
+	RandRead:  "rand_read",
+	RandWrite: "rand_write",
+}
+
+func (w Workload) String() string {
+	if w >= 0 && int(w) < len(workloadNames) {
+		return workloadNames[w]
+	}
+	return "unknown"
+}
+
+func (w Workload) MarshalText() ([]byte, error) {
+	return []byte(w.String()), nil
+}
+
+// ParseWorkload returns the workload with the given name.
+func ParseWorkload(name string) (Workload, error) {
+	if i := slices.Index(workloadNames[:], name); i >= 0 {
+		return Workload(i), nil
+	}
+	return 0, fmt.Errorf("unknown workload %q", name)
+}
+
+type BenchOptions struct {
+	Workload   Workload
+	QueueDepth int   // operations in flight at once, 1 if 0
+	Ops        int   // block operations to issue, one pass over Blocks if 0
+	Blocks     int   // logical blocks the workload ranges over, the whole array if 0
+	Seed       int64 // random workloads use the same offsets for the same seed
+}
+
+type BenchResult struct {
+	Workload   Workload       `json:"workload"`
+	QueueDepth int            `json:"queue_depth"`
+	Ops        int            `json:"ops"`
+	Bytes      int64          `json:"bytes"`
+	Elapsed    time.Duration  `json:"elapsed_ns"`
+	IOPS       float64        `json:"iops"`
+	MBps       float64        `json:"mb_per_sec"`
+	Latency    LatencySummary `json:"latency_ns"`
+}
+
+type LatencySummary struct {
+	Min time.Duration `json:"min"`
+	P50 time.Duration `json:"p50"`
+	P90 time.Duration `json:"p90"`
+	P99 time.Duration `json:"p99"`
+	Max time.Duration `json:"max"`
+}
+
+// Bench runs a workload of single-block reads or writes against the array
+// and measures its throughput and per-operation latency. Writes overwrite
+// the blocks they touch with a fill pattern, so only benchmark arrays whose
+// contents do not matter.
+func (r *RAIDArray) Bench(ctx context.Context, opts BenchOptions) (*BenchResult, error) {
+	blocks := opts.Blocks
+	if blocks <= 0 || blocks > r.capacity {
+		blocks = r.capacity
+	}
+	ops := opts.Ops
+	if ops <= 0 {
+		ops = blocks
+	}
+	depth := max(opts.QueueDepth, 1)
+	if opts.Workload < SeqRead || opts.Workload > RandWrite {
+		return nil, fmt.Errorf("unknown workload %d", opts.Workload)
+	}
+
+	offsets := make([]int, ops)
+	rng := rand.New(rand.NewPCG(uint64(opts.Seed), 0))
+	for i := range offsets {
+		if opts.Workload == RandRead || opts.Workload == RandWrite {
+			offsets[i] = rng.IntN(blocks)
+		} else {
+			offsets[i] = i % blocks
+		}
+	}
+
+	latencies := make([]time.Duration, ops)
+	var next atomic.Int64
+	var wg sync.WaitGroup
+	errs := make(chan error, depth)
+
+	start := time.Now()
+	for range depth {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			buf := make([]byte, r.blockSize)
+			for i := range buf {
+				buf[i] = byte(i)
+			}
+			for {
+				i := int(next.Add(1) - 1)
+				if i >= ops {
+					return
+				}
+				if err := ctx.Err(); err != nil {
+					errs <- err
+					return
+				}
+				var err error
+				opStart := time.Now()
+				switch opts.Workload {
+				case SeqRead, RandRead:
+					err = r.ReadBlockInto(offsets[i], buf)
+				case SeqWrite, RandWrite:
+					err = r.WriteBlock(offsets[i], buf)
+				}
+				latencies[i] = time.Since(opStart)
+				if err != nil {
+					errs <- fmt.Errorf("%s of block %d: %w", opts.Workload, offsets[i], err)
+					next.Store(int64(ops)) // stop the other workers
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+	select {
+	case err := <-errs:
+		return nil, err
+	default:
+	}
+
+	res := &BenchResult{
+		Workload:   opts.Workload,
+		QueueDepth: depth,
+		Ops:        ops,
+		Bytes:      int64(ops) * int64(r.blockSize),
+		Elapsed:    elapsed,
+		IOPS:       float64(ops) / elapsed.Seconds(),
+		MBps:       float64(ops) * float64(r.blockSize) / 1e6 / elapsed.Seconds(),
+	}
+	slices.Sort(latencies)
+	at := func(p float64) time.Duration { return latencies[min(int(p*float64(ops)), ops-1)] }
+	res.Latency = LatencySummary{Min: latencies[0], P50: at(0.50), P90: at(0.90), P99: at(0.99), Max: latencies[ops-1]}
+	return res, nil
+}
@@ -0,0 +1,149 @@
+package raid
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+var (
+	_ io.ReaderAt = (*RAIDArray)(nil)
+	_ io.WriterAt = (*RAIDArray)(nil)
+)
+
+const partialLockCount = 64
+
+// partialLocks serialise read-modify-writes of the same block so two
+// unaligned writes sharing a block do not lose each other's bytes.
+type partialLocks [partialLockCount]sync.Mutex
+
+func (l *partialLocks) lock(blockID int) (unlock func()) {
+	m := &l[blockID%partialLockCount]
+	m.Lock()
+	return m.Unlock
+}
+
+// Size is the logical capacity in bytes.
+func (r *RAIDArray) Size() int64 {
+	return int64(r.capacity) * int64(r.blockSize)
+}
+
+// ReadAt reads len(p) bytes at byte offset off of the logical address
+// space. Reads reaching past the end return io.EOF with the bytes available.
+func (r *RAIDArray) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, fmt.Errorf("negative offset %d", off)
+	}
+	if off >= r.Size() {
+		return 0, io.EOF
+	}
+
+	want := p
+	if rest := r.Size() - off; int64(len(p)) > rest {
+		p = p[:rest]
+	}
+
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	bs := int64(r.blockSize)
+	first, last := off/bs, (off+int64(len(p))-1)/bs
+
+	data, err := r.ReadRange(int(first), int(last-first+1))
+	if err != nil {
+		return 0, err
+	}
+	n := copy(p, data[off-first*bs:])
+
+	if n < len(want) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// WriteAt writes p at byte offset off of the logical address space. Whole
+// blocks are written as a batch, so aligned RAID 5 writes covering full
+// stripes skip the parity pre-reads; partial blocks at either end are
+// read, patched and written back alongside the batch. Writes past the end
+// fail without writing anything.
+func (r *RAIDArray) WriteAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, fmt.Errorf("negative offset %d", off)
+	}
+	if off+int64(len(p)) > r.Size() {
+		return 0, fmt.Errorf("%w: write of %d bytes at %d exceeds array size %d", ErrBlockOutOfRange, len(p), off, r.Size())
+	}
+
+	bs := int64(r.blockSize)
+	head := 0 // bytes in the leading partial block
+	if at := off % bs; at != 0 {
+		head = min(int(bs-at), len(p))
+	}
+	full := (len(p) - head) / r.blockSize
+	tail := len(p) - head - full*r.blockSize
+
+	var wg sync.WaitGroup
+	var headErr, fullErr, tailErr error
+	if head > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			headErr = r.patchBlock(int(off/bs), int(off%bs), p[:head])
+		}()
+	}
+	if tail > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tailErr = r.patchBlock(int((off+int64(len(p))-1)/bs), 0, p[len(p)-tail:])
+		}()
+	}
+	if full > 0 {
+		first := int((off + int64(head)) / bs)
+		blockIDs := make([]int, full)
+		data := make([][]byte, full)
+		for i := range blockIDs {
+			blockIDs[i] = first + i
+			data[i] = p[head+i*r.blockSize : head+(i+1)*r.blockSize]
+		}
+		fullErr = r.WriteBlocks(blockIDs, data)
+	}
+	wg.Wait()
+
+	// report the bytes written before the first failure
+	switch {
+	case headErr != nil:
+		return 0, headErr
+	case fullErr != nil:
+		return head, fullErr
+	case tailErr != nil:
+		return head + full*r.blockSize, tailErr
+	}
+	return len(p), nil
+}
+
+// Read returns length bytes at byte offset off of the logical address
+// space. Unlike ReadAt, a range reaching past the end is an error.
+func (r *RAIDArray) Read(off int64, length int) ([]byte, error) {
+	if off < 0 || length < 0 || off+int64(length) > r.Size() {
+		return nil, fmt.Errorf("%w: read of %d bytes at %d exceeds array size %d", ErrBlockOutOfRange, length, off, r.Size())
+	}
+	p := make([]byte, length)
+	if _, err := r.ReadAt(p, off); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Write writes data at byte offset off of the logical address space. It is
+// WriteAt for callers that do not need the byte count.
+func (r *RAIDArray) Write(off int64, data []byte) error {
+	_, err := r.WriteAt(data, off)
+	return err
+}
+
+// patchBlock overwrites part of a block, starting at byte offset at.
+func (r *RAIDArray) patchBlock(blockID, at int, p []byte) error {
+	return r.patchPieces(blockID, []piece{{at, p}})
+}
@@ -0,0 +1,121 @@
+package raid
+
+import (
+	"container/list"
+	"sync"
+)
+
+// blockCache is an LRU cache of logical blocks. Writes invalidate entries;
+// the generation counter stops a read that raced with a write from caching
+// the data it read before the write landed.
+type blockCache struct {
+	mu       sync.Mutex
+	capacity int
+	ll       *list.List
+	items    map[int]*list.Element
+	gen      uint64
+
+	hits   uint64
+	misses uint64
+}
+
+type cacheEntry struct {
+	blockID int
+	data    []byte
+}
+
+func newBlockCache(capacity int) *blockCache {
+	return &blockCache{
+		capacity: capacity,
+		ll:       list.New(),
+		items:    make(map[int]*list.Element),
+	}
+}
+
+// get returns a copy of the cached block, or the current generation to be
+// passed to put after a miss.
+func (c *blockCache) get(blockID int) ([]byte, uint64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[blockID]
+	if !ok {
+		c.misses++
+		return nil, c.gen, false
+	}
+
+	c.hits++
+	c.ll.MoveToFront(el)
+	data := make([]byte, len(el.Value.(*cacheEntry).data))
+	copy(data, el.Value.(*cacheEntry).data)
+	return data, c.gen, true
+}
+
+func (c *blockCache) put(blockID int, data []byte, gen uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if gen != c.gen {
+		return
+	}
+
+	c.insert(blockID, data)
+}
+
+// set stores data unconditionally, for callers that hold the only
+// authoritative copy (e.g. under a stripe lock).
+func (c *blockCache) set(blockID int, data []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.insert(blockID, data)
+}
+
+func (c *blockCache) insert(blockID int, data []byte) {
+	stored := make([]byte, len(data))
+	copy(stored, data)
+
+	if el, ok := c.items[blockID]; ok {
+		el.Value.(*cacheEntry).data = stored
+		c.ll.MoveToFront(el)
+		return
+	}
+
+	c.items[blockID] = c.ll.PushFront(&cacheEntry{blockID: blockID, data: stored})
+	if c.ll.Len() > c.capacity {
+		oldest := c.ll.Back()
+		c.ll.Remove(oldest)
+		delete(c.items, oldest.Value.(*cacheEntry).blockID)
+	}
+}
+
+func (c *blockCache) invalidate(blockID int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.gen++
+	if el, ok := c.items[blockID]; ok {
+		c.ll.Remove(el)
+		delete(c.items, blockID)
+	}
+}
+
+func (c *blockCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.gen++
+	c.ll.Init()
+	c.items = make(map[int]*list.Element)
+}
+
+func (c *blockCache) stats() (hits, misses uint64, blocks int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.hits, c.misses, c.ll.Len()
+}
+
+func (c *blockCache) resetStats() {
+	c.mu.Lock()
+	c.hits, c.misses = 0, 0
+	c.mu.Unlock()
+}
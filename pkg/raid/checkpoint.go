@@ -0,0 +1,93 @@
+package raid
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// rebuildCheckpointInterval is how many stripes a rebuild completes between
+// saving its progress.
+const rebuildCheckpointInterval = 64
+
+type rebuildCheckpoint struct {
+	NextBlock int `json:"next_block"`
+}
+
+func rebuildCheckpointPath(path string) string {
+	return path + ".rebuild"
+}
+
+// loadRebuildCheckpoint returns the first block an interrupted rebuild of
+// the image still has to write, or -1 if no rebuild is pending.
+func loadRebuildCheckpoint(path string) (int, error) {
+	raw, err := os.ReadFile(rebuildCheckpointPath(path))
+	if os.IsNotExist(err) {
+		return -1, nil
+	}
+	if err != nil {
+		return -1, fmt.Errorf("failed to read rebuild checkpoint for %s: %w", path, err)
+	}
+
+	var cp rebuildCheckpoint
+	if err := json.Unmarshal(raw, &cp); err != nil {
+		return -1, fmt.Errorf("corrupt rebuild checkpoint for %s: %w", path, err)
+	}
+	return cp.NextBlock, nil
+}
+
+func writeRebuildCheckpoint(path string, next int) error {
+	raw, err := json.Marshal(rebuildCheckpoint{NextBlock: next})
+	if err != nil {
+		return err
+	}
+
+	tmp := rebuildCheckpointPath(path) + ".tmp"
+	if err := os.WriteFile(tmp, raw, 0644); err != nil {
+		return fmt.Errorf("failed to save rebuild checkpoint for %s: %w", path, err)
+	}
+	return os.Rename(tmp, rebuildCheckpointPath(path))
+}
+
+// RebuildCheckpoint returns the block an interrupted rebuild resumes from,
+// or -1 if no rebuild is pending.
+func (d *Disk) RebuildCheckpoint() int {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.rebuildFrom
+}
+
+// setRebuildCheckpoint records that every block before next has been
+// rebuilt. A negative next clears the checkpoint.
+func (d *Disk) setRebuildCheckpoint(next int) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.saveRebuildCheckpoint(next)
+}
+
+// lowerRebuildCheckpoint moves a pending checkpoint back to block when a
+// degraded write changed the stripe after the rebuild had passed it.
+func (d *Disk) lowerRebuildCheckpoint(block int) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.rebuildFrom < 0 || block >= d.rebuildFrom {
+		return nil
+	}
+	return d.saveRebuildCheckpoint(block)
+}
+
+// saveRebuildCheckpoint persists next. Caller must hold d.mu for writing.
+func (d *Disk) saveRebuildCheckpoint(next int) error {
+	if next < 0 {
+		d.rebuildFrom = -1
+		if err := os.Remove(rebuildCheckpointPath(d.path)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	if err := writeRebuildCheckpoint(d.path, next); err != nil {
+		return err
+	}
+	d.rebuildFrom = next
+	return nil
+}
@@ -0,0 +1,90 @@
+package raid
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Clock is the array's source of time for background work (scrub pacing,
+// space probes, metrics push). Tests can substitute a manual clock to drive
+// these deterministically.
+type Clock interface {
+	Now() time.Time
+	NewTicker(d time.Duration) Ticker
+}
+
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) NewTicker(d time.Duration) Ticker {
+	return realTicker{time.NewTicker(d)}
+}
+
+type realTicker struct {
+	t *time.Ticker
+}
+
+func (t realTicker) C() <-chan time.Time {
+	return t.t.C
+}
+
+func (t realTicker) Stop() {
+	t.t.Stop()
+}
+
+// sleep waits for d to pass on clk. It is built on a ticker so that any
+// Clock, including a manual one in tests, decides when it returns.
+func sleep(clk Clock, d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	tk := clk.NewTicker(d)
+	defer tk.Stop()
+	<-tk.C()
+}
+
+// lockedRand makes a rand.Rand safe for concurrent use.
+type lockedRand struct {
+	mu sync.Mutex
+	r  *rand.Rand
+}
+
+func newLockedRand(src rand.Source) *lockedRand {
+	if src == nil {
+		src = rand.NewSource(time.Now().UnixNano())
+	}
+	return &lockedRand{r: rand.New(src)}
+}
+
+func (l *lockedRand) Perm(n int) []int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.r.Perm(n)
+}
+
+func (l *lockedRand) Intn(n int) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.r.Intn(n)
+}
+
+func (l *lockedRand) Float64() float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.r.Float64()
+}
+
+func (l *lockedRand) ExpFloat64() float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.r.ExpFloat64()
+}
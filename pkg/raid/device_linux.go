@@ -0,0 +1,19 @@
+package raid
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+const blkGetSize64 = 0x80081272 // BLKGETSIZE64
+
+// deviceSize returns the size in bytes of the block device open as f.
+func deviceSize(f *os.File) (int64, error) {
+	var size uint64
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), blkGetSize64, uintptr(unsafe.Pointer(&size)))
+	if errno != 0 {
+		return 0, errno
+	}
+	return int64(size), nil
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package raid
+
+import (
+	"io"
+	"os"
+)
+
+// deviceSize returns the size in bytes of the block device open as f.
+func deviceSize(f *os.File) (int64, error) {
+	return f.Seek(0, io.SeekEnd)
+}
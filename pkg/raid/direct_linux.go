@@ -0,0 +1,5 @@
+package raid
+
+import "syscall"
+
+const directFlag = syscall.O_DIRECT
@@ -0,0 +1,5 @@
+//go:build !linux
+
+package raid
+
+const directFlag = 0 // no O_DIRECT, DirectIO is rejected
@@ -0,0 +1,778 @@
+package raid
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+var (
+	errMediaError  = errors.New("media error")
+	errMappedFault = errors.New("fault accessing memory-mapped image")
+)
+
+type Disk struct {
+	store Backend
+	path  string
+
+	blockSize int
+	numBlocks int
+
+	failed    bool
+	failedAt  time.Time
+	clock     Clock
+	poweredOn time.Time
+	thermal   thermalState
+
+	remapReserve int
+	badBlocks    map[int]*remapEntry // original block -> relocation slot
+	mediaErrors  map[int]bool        // simulated unreadable sectors
+
+	noSpaceBlock int // block whose write hit ENOSPC, -1 if none
+	rebuildFrom  int // first block an interrupted rebuild still has to write, -1 if none
+
+	syncPolicy SyncPolicy
+	dirty      bool // written since the last fsync
+
+	timeout      time.Duration
+	timeoutLimit int
+	timeouts     atomic.Uint64 // transfers that missed the timeout
+	retry        RetryPolicy
+
+	quirks  *quirkState   // emulated drive misbehavior, nil for an honest drive
+	faults  *faultState   // injected I/O faults, nil when there are none
+	failure *failureState // simulated spontaneous failure, nil when disabled
+	crash   *crashState   // pending simulated power cut, shared by every member
+	latency *latencyState // simulated drive latency, nil when disabled
+
+	zero []uint64 // bitmap of blocks last written with zeros, such as discarded blocks
+
+	onFail func() // set by the owning array, called when the disk becomes failed
+
+	metrics     diskMetrics
+	readLatency atomic.Int64 // moving average in nanoseconds
+	opLatency   atomic.Int64 // as readLatency, over reads and writes
+	slow        atomic.Bool  // flagged by the array's slow disk policy
+	writeMostly atomic.Bool  // read only when no other mirror can serve the read
+	lastErr     atomic.Pointer[diskError]
+
+	mu sync.RWMutex
+}
+
+func (d *Disk) resetStats() {
+	d.metrics.reset()
+}
+
+type diskError struct {
+	at  time.Time
+	msg string
+}
+
+type DiskStats struct {
+	Path           string `json:"path"`
+	WriteCount     uint64 `json:"write_count"`
+	ReadCount      uint64 `json:"read_count"`
+	Failed         bool   `json:"failed"`
+	RemappedBlocks int    `json:"remapped_blocks"`
+	LogicalBytes   int64  `json:"logical_bytes"`  // image size, data plus remap reserve
+	PhysicalBytes  int64  `json:"physical_bytes"` // space the image takes on its filesystem, -1 if not known
+	ReadRetries    uint64 `json:"read_retries"`   // reads tried again after a transient error
+	WriteRetries   uint64 `json:"write_retries"`
+	ReadErrors     uint64 `json:"read_errors"` // failed transfers, whether or not they were recovered
+	WriteErrors    uint64 `json:"write_errors"`
+	BytesRead      uint64 `json:"bytes_read"`
+	BytesWritten   uint64 `json:"bytes_written"`
+	InFlight       int    `json:"in_flight"` // transfers in progress when the stats were taken
+
+	ReadLatency  LatencyHistogram `json:"read_latency"` // successful transfers only
+	WriteLatency LatencyHistogram `json:"write_latency"`
+}
+
+type DiskOptions struct {
+	RemapReserve int           // blocks reserved at the end of the image for relocations
+	Prealloc     PreallocMode  // how the image is sized on creation
+	IOUring      bool          // submit I/O through io_uring (Linux only)
+	DirectIO     bool          // bypass the page cache with O_DIRECT (Linux only)
+	Mmap         bool          // serve I/O from a shared memory mapping of the image (Linux only)
+	SyncPolicy   SyncPolicy    // SyncAlways fsyncs in WriteBlock, otherwise Sync must be called
+	Timeout      time.Duration // give up on a transfer after this long, 0 waits forever
+	TimeoutLimit int           // fail the disk at this many timed out transfers, 0 never does
+	Retry        RetryPolicy   // how transfers failing with transient errors are retried
+
+	ring  *ioRing // ring shared with the other members, set by NewRAIDArray
+	clock Clock   // array clock used to time failures, real time by default
+}
+
+type PreallocMode int
+
+const (
+	PreallocSparse PreallocMode = iota // extend with Truncate, blocks allocated on first write
+	PreallocFull                       // reserve every block up front with fallocate
+	PreallocNone                       // leave the file alone, it grows as blocks are written
+)
+
+// directAlignment is the buffer, offset and length alignment used for
+// O_DIRECT transfers. It covers both 512-byte and 4K sector devices.
+const directAlignment = 4096
+
+type remapEntry struct {
+	Slot    int  `json:"slot"`
+	Pending bool `json:"pending"` // remapped after a read error, contents lost until rewritten
+}
+
+func NewDisk(path string, blockSize, numBlocks int) (*Disk, error) {
+	return NewDiskWithOptions(path, blockSize, numBlocks, DiskOptions{})
+}
+
+func NewDiskWithOptions(path string, blockSize, numBlocks int, opts DiskOptions) (*Disk, error) {
+	if blockSize <= 0 {
+		return nil, fmt.Errorf("block size must be positive, got %d", blockSize)
+	}
+	if numBlocks <= 0 {
+		return nil, fmt.Errorf("number of blocks must be positive, got %d", numBlocks)
+	}
+	if opts.RemapReserve < 0 {
+		return nil, fmt.Errorf("remap reserve must not be negative, got %d", opts.RemapReserve)
+	}
+	if opts.DirectIO && directFlag == 0 {
+		return nil, fmt.Errorf("direct I/O is not supported on this platform")
+	}
+	if opts.Mmap && (opts.DirectIO || opts.IOUring) {
+		return nil, fmt.Errorf("memory-mapped images cannot be combined with direct I/O or io_uring")
+	}
+	if opts.Mmap && opts.Prealloc == PreallocNone {
+		return nil, fmt.Errorf("memory-mapped images need a preallocation mode that sizes the image")
+	}
+	if opts.DirectIO && blockSize%directAlignment != 0 {
+		return nil, fmt.Errorf("direct I/O requires a block size that is a multiple of %d, got %d", directAlignment, blockSize)
+	}
+
+	store, err := openFileBackend(path, blockSize, numBlocks+opts.RemapReserve, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	disk, err := newDisk(path, store, numBlocks, opts)
+	if err != nil {
+		store.Close()
+		return nil, err
+	}
+	return disk, nil
+}
+
+// NewDiskWithBackend creates a disk whose image lives in store instead of a
+// local file. path names the disk in errors and statistics, and is where the
+// bad block list and rebuild checkpoint are kept. The file-specific options
+// IOUring, DirectIO and Mmap do not apply. The disk owns store and closes it
+// with the disk.
+func NewDiskWithBackend(path string, store Backend, numBlocks int, opts DiskOptions) (*Disk, error) {
+	if numBlocks <= 0 {
+		return nil, fmt.Errorf("number of blocks must be positive, got %d", numBlocks)
+	}
+	if opts.RemapReserve < 0 {
+		return nil, fmt.Errorf("remap reserve must not be negative, got %d", opts.RemapReserve)
+	}
+	if opts.IOUring || opts.DirectIO || opts.Mmap {
+		return nil, fmt.Errorf("io_uring, direct I/O and memory mapping need a file-backed disk")
+	}
+	if store.BlockSize() <= 0 {
+		return nil, fmt.Errorf("block size must be positive, got %d", store.BlockSize())
+	}
+	if need := numBlocks + opts.RemapReserve; store.Capacity() < need {
+		return nil, fmt.Errorf("backend for %s holds %d blocks, need %d", path, store.Capacity(), need)
+	}
+	return newDisk(path, store, numBlocks, opts)
+}
+
+func newDisk(path string, store Backend, numBlocks int, opts DiskOptions) (*Disk, error) {
+	if opts.Timeout < 0 || opts.TimeoutLimit < 0 {
+		return nil, fmt.Errorf("I/O timeout settings must not be negative")
+	}
+	if err := opts.Retry.validate(); err != nil {
+		return nil, err
+	}
+	badBlocks, err := loadBadBlockList(path)
+	if err != nil {
+		return nil, err
+	}
+	rebuildFrom, err := loadRebuildCheckpoint(path)
+	if err != nil {
+		return nil, err
+	}
+
+	clock := opts.clock
+	if clock == nil {
+		clock = realClock{}
+	}
+
+	return &Disk{
+		store:        store,
+		path:         path,
+		blockSize:    store.BlockSize(),
+		numBlocks:    numBlocks,
+		failed:       false,
+		remapReserve: opts.RemapReserve,
+		badBlocks:    badBlocks,
+		mediaErrors:  make(map[int]bool),
+		noSpaceBlock: -1,
+		rebuildFrom:  rebuildFrom,
+		zero:         make([]uint64, (numBlocks+63)/64),
+		clock:        clock,
+		poweredOn:    clock.Now(),
+		thermal:      thermalState{ambient: defaultAmbient},
+		syncPolicy:   opts.SyncPolicy,
+		timeout:      opts.Timeout,
+		timeoutLimit: opts.TimeoutLimit,
+		retry:        opts.Retry,
+	}, nil
+}
+
+func (d *Disk) ReadBlock(blockID int) ([]byte, error) {
+	data := make([]byte, d.blockSize)
+	if err := d.ReadBlockInto(blockID, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// ReadBlockInto reads a block into buf, which must be exactly one block
+// long, without allocating.
+func (d *Disk) ReadBlockInto(blockID int, buf []byte) error {
+	d.metrics.inFlight.Add(1)
+	defer d.metrics.inFlight.Add(-1)
+	start := time.Now()
+
+	var lost bool
+	defer d.failIfLost(&lost)
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if d.failed {
+		return fmt.Errorf("%w: %s", ErrDiskFailed, d.path)
+	}
+
+	if blockID < 0 || blockID >= d.numBlocks {
+		return outOfRange("block ID", blockID, d.numBlocks)
+	}
+
+	if e, ok := d.badBlocks[blockID]; ok && e.Pending {
+		return fmt.Errorf("read error on %s block %d: pending reallocation", d.path, blockID)
+	}
+
+	if len(buf) != d.blockSize {
+		return fmt.Errorf("buffer size %d does not match block size %d", len(buf), d.blockSize)
+	}
+
+	if d.quirks != nil {
+		if data, ok := d.quirks.cached[blockID]; ok {
+			copy(buf, data)
+			return nil
+		}
+	}
+
+	err := d.readAt(buf, blockID)
+	lost = errors.Is(err, ErrDiskFailed)
+	if errors.Is(err, errMediaError) {
+		d.mu.RUnlock()
+		d.mu.Lock()
+		remapErr := d.remapBlock(blockID, true)
+		d.mu.Unlock()
+		d.mu.RLock()
+		if remapErr != nil {
+			err = fmt.Errorf("%w (%v)", err, remapErr)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("read error on %s block %d: %w", d.path, blockID, err)
+	}
+
+	sample := time.Since(start)
+	recordLatency(&d.readLatency, sample)
+	recordLatency(&d.opLatency, sample)
+	d.metrics.read(len(buf), sample)
+
+	return nil
+}
+
+// ReadBlockContext is ReadBlockInto that fails with ctx's error instead of
+// starting the transfer once ctx is done. A transfer in progress is not
+// interrupted.
+func (d *Disk) ReadBlockContext(ctx context.Context, blockID int, buf []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return d.ReadBlockInto(blockID, buf)
+}
+
+func (d *Disk) WriteBlock(blockID int, data []byte) error {
+	_, err := d.writeBlock(blockID, data)
+	return err
+}
+
+// writeBlock is WriteBlock, also reporting how long the fsync took.
+func (d *Disk) writeBlock(blockID int, data []byte) (syncTime time.Duration, err error) {
+	var lost bool
+	defer d.failIfLost(&lost)
+	start := time.Now()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.failed {
+		return 0, fmt.Errorf("%w: %s", ErrDiskFailed, d.path)
+	}
+
+	if blockID < 0 || blockID >= d.numBlocks {
+		return 0, outOfRange("block ID", blockID, d.numBlocks)
+	}
+
+	if len(data) != d.blockSize {
+		return 0, fmt.Errorf("data size %d does not match block size %d", len(data), d.blockSize)
+	}
+
+	if d.quirks != nil {
+		err = d.quirkWrite(blockID, data)
+	} else {
+		syncTime, err = d.storeBlock(blockID, data)
+		lost = errors.Is(err, ErrDiskFailed)
+	}
+	if err != nil {
+		return 0, err
+	}
+	d.setZero(blockID, isZero(data))
+	sample := time.Since(start)
+	recordLatency(&d.opLatency, sample)
+	d.metrics.wrote(len(data), sample)
+
+	return syncTime, nil
+}
+
+// storeBlock writes a block through to the image, remapping it if the
+// location has gone bad. Callers hold d.mu and have validated the block.
+func (d *Disk) storeBlock(blockID int, data []byte) (syncTime time.Duration, err error) {
+	err = d.writeAt(data, blockID)
+	if errors.Is(err, errMediaError) {
+		if remapErr := d.remapBlock(blockID, false); remapErr != nil {
+			return 0, fmt.Errorf("write error on %s block %d: %w (%v)", d.path, blockID, err, remapErr)
+		}
+		err = d.writeAt(data, blockID)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("write error on %s block %d: %w", d.path, blockID, d.classifyWriteError(blockID, err))
+	}
+
+	if d.syncPolicy == SyncAlways {
+		start := time.Now()
+		if err := d.store.Sync(); err != nil {
+			d.noteError(&d.metrics.writeErrors, fmt.Errorf("sync: %w", err))
+			return 0, fmt.Errorf("sync error on %s: %w", d.path, d.classifyWriteError(blockID, err))
+		}
+		syncTime = time.Since(start)
+	} else {
+		d.dirty = true
+	}
+
+	if e, ok := d.badBlocks[blockID]; ok && e.Pending {
+		e.Pending = false
+		if err := d.saveBadBlockList(); err != nil {
+			return 0, err
+		}
+	}
+
+	return syncTime, nil
+}
+
+// Discard releases a block, which reads as zeros afterwards. A sparse image
+// punches the block out so it no longer takes space.
+func (d *Disk) Discard(blockID int) error {
+	return d.WriteBlock(blockID, make([]byte, d.blockSize))
+}
+
+// knownZero reports whether the block was last written with zeros, so a
+// reader that only needs to XOR it can skip it. Blocks whose contents have
+// not been written since the disk was opened are not known to be zero.
+func (d *Disk) knownZero(blockID int) bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.zero[blockID/64]&(1<<(blockID%64)) != 0
+}
+
+// setZero records whether a block holds zeros. Caller must hold d.mu for
+// writing.
+func (d *Disk) setZero(blockID int, zero bool) {
+	if zero {
+		d.zero[blockID/64] |= 1 << (blockID % 64)
+	} else {
+		d.zero[blockID/64] &^= 1 << (blockID % 64)
+	}
+}
+
+// Sync flushes writes made since the last sync to stable storage.
+func (d *Disk) Sync() error {
+	var lost bool
+	defer d.failIfLost(&lost)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if !d.dirty || d.failed {
+		return nil
+	}
+	if d.quirks != nil {
+		if d.quirks.LieAboutFlush {
+			d.dirty = false
+			return nil
+		}
+		if err := d.drainWriteCache(); err != nil {
+			return fmt.Errorf("sync error on %s: %w", d.path, err)
+		}
+	}
+	if err := d.store.Sync(); err != nil {
+		d.noteError(&d.metrics.writeErrors, fmt.Errorf("sync: %w", err))
+		lost = errors.Is(err, ErrDiskFailed)
+		return fmt.Errorf("sync error on %s: %w", d.path, err)
+	}
+	d.dirty = false
+	return nil
+}
+
+func (d *Disk) SetFailed(failed bool) { // simulates hardware failure
+	d.mu.Lock()
+	newlyFailed := failed && !d.failed
+	if newlyFailed {
+		d.failedAt = d.clock.Now()
+	}
+	d.failed = failed
+	onFail := d.onFail
+	d.mu.Unlock()
+
+	if newlyFailed && onFail != nil {
+		onFail()
+	}
+}
+
+// failIfLost marks the disk failed if *lost is set. Backends report storage
+// that is gone for good, such as a server that stays unreachable, with an
+// error wrapping ErrDiskFailed. Deferred ahead of taking d.mu, so it runs
+// after the lock is released.
+func (d *Disk) failIfLost(lost *bool) {
+	if *lost {
+		d.SetFailed(true)
+	}
+}
+
+func (d *Disk) failedSince() (bool, time.Time) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.failed, d.failedAt
+}
+
+func (d *Disk) SimulateMediaError(blockID int) { // makes the block's current location unreadable and unwritable
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.mediaErrors[blockID] = true
+}
+
+// Health asks the backend whether its storage is reachable. A disk whose
+// backend reports a problem is marked failed.
+func (d *Disk) Health() error {
+	if d.IsFailed() {
+		return fmt.Errorf("%w: %s", ErrDiskFailed, d.path)
+	}
+	if err := d.store.Health(); err != nil {
+		d.SetFailed(true)
+		return fmt.Errorf("%s is unreachable: %w", d.path, err)
+	}
+	return nil
+}
+
+func (d *Disk) IsFailed() bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.failed
+}
+
+func (d *Disk) GetStats() DiskStats {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	physical := int64(-1)
+	if fb, ok := d.store.(*fileBackend); ok {
+		physical = fb.allocated()
+	}
+
+	st := DiskStats{
+		Path:           d.path,
+		Failed:         d.failed,
+		RemappedBlocks: len(d.badBlocks),
+		LogicalBytes:   int64(d.blockSize) * int64(d.numBlocks+d.remapReserve),
+		PhysicalBytes:  physical,
+	}
+	d.metrics.fill(&st)
+	return st
+}
+
+func (d *Disk) Capacity() int {
+	return d.numBlocks
+}
+
+func (d *Disk) BlockSize() int {
+	return d.blockSize
+}
+
+func (d *Disk) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.quirks != nil && !d.failed {
+		d.drainWriteCache() // an orderly shutdown empties the drive cache
+	}
+	return d.store.Close()
+}
+
+// migrateTo copies the image to newPath and then switches the disk over to
+// it. Writes issued during the copy go to both files, so the new image is
+// complete at the moment of the switch. The old image is left in place.
+func (d *Disk) migrateTo(newPath string) error {
+	fb, ok := d.store.(*fileBackend)
+	if !ok {
+		return fmt.Errorf("%s is not file-backed", d.path)
+	}
+	if fb.device {
+		return fmt.Errorf("%s is a block device and cannot be migrated", d.path)
+	}
+
+	flags := os.O_RDWR | os.O_CREATE | os.O_EXCL
+	if fb.direct {
+		flags |= directFlag
+	}
+	newFile, err := os.OpenFile(newPath, flags, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", newPath, err)
+	}
+
+	abort := func(err error) error {
+		d.mu.Lock()
+		fb.mirror = nil
+		fb.mirrorErr = nil
+		d.mu.Unlock()
+		newFile.Close()
+		os.Remove(newPath)
+		return err
+	}
+
+	if err := newFile.Truncate(int64(fb.blocks * fb.blockSize)); err != nil {
+		return abort(fmt.Errorf("failed to resize %s: %w", newPath, err))
+	}
+
+	d.mu.Lock()
+	fb.mirror = newFile
+	d.mu.Unlock()
+
+	buf := make([]byte, fb.blockSize)
+	for block := 0; block < fb.blocks; block++ {
+		d.mu.Lock()
+		offset := fb.offset(block)
+		_, err := fb.readFull(buf, offset)
+		if err == nil && !isZero(buf) { // the new image starts out sparse
+			_, err = fb.pwrite(newFile, buf, offset)
+		}
+		d.mu.Unlock()
+		if err != nil {
+			return abort(fmt.Errorf("copy failed at block %d: %w", block, err))
+		}
+	}
+
+	if err := newFile.Sync(); err != nil {
+		return abort(fmt.Errorf("sync error on %s: %w", newPath, err))
+	}
+
+	d.mu.Lock()
+	if err := fb.mirrorErr; err != nil {
+		d.mu.Unlock()
+		return abort(fmt.Errorf("mirrored write to %s failed: %w", newPath, err))
+	}
+
+	if fb.mapped != nil {
+		mapped, err := mapImage(newFile, len(fb.mapped))
+		if err != nil {
+			d.mu.Unlock()
+			return abort(fmt.Errorf("failed to map %s: %w", newPath, err))
+		}
+		unmapImage(fb.mapped)
+		fb.mapped = mapped
+	}
+
+	oldFile := fb.file
+	fb.file = newFile
+	fb.path = newPath
+	fb.mirror = nil
+	d.path = newPath
+
+	var saveErr error
+	if len(d.badBlocks) > 0 {
+		saveErr = d.saveBadBlockList()
+	}
+	d.mu.Unlock()
+
+	if saveErr != nil {
+		return saveErr
+	}
+	return oldFile.Close()
+}
+
+// classifyWriteError tags out-of-space errors with ErrNoSpace and remembers
+// the block so probeSpace can tell when it becomes writable again. Caller
+// must hold d.mu for writing.
+func (d *Disk) classifyWriteError(blockID int, err error) error {
+	if !errors.Is(err, syscall.ENOSPC) {
+		return err
+	}
+	d.noSpaceBlock = blockID
+	return fmt.Errorf("%w: %w", ErrNoSpace, err)
+}
+
+// probeSpace retries the write that last ran out of space by writing the
+// block's current contents back in place.
+func (d *Disk) probeSpace() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.noSpaceBlock < 0 || d.failed {
+		return nil
+	}
+
+	buf := make([]byte, d.blockSize)
+	block := d.physical(d.noSpaceBlock)
+	if err := d.store.ReadBlock(block, buf); err != nil {
+		return err
+	}
+	if err := d.store.WriteBlock(block, buf); err != nil {
+		return err
+	}
+	if err := d.store.Sync(); err != nil {
+		return err
+	}
+
+	d.noSpaceBlock = -1
+	return nil
+}
+
+// recordLatency folds a transfer time into a moving average.
+func recordLatency(avg *atomic.Int64, sample time.Duration) {
+	old := avg.Load()
+	if old == 0 {
+		avg.Store(int64(sample))
+		return
+	}
+	avg.Store(old + (int64(sample)-old)/8)
+}
+
+// physical maps a block to its location in the backend, following any
+// relocation into the remap reserve.
+func (d *Disk) physical(blockID int) int {
+	if e, ok := d.badBlocks[blockID]; ok {
+		return d.numBlocks + e.Slot
+	}
+	return blockID
+}
+
+func (d *Disk) readAt(data []byte, blockID int) error {
+	return d.withRetries(d.mu.RLocker(), &d.metrics.readRetries, func() error {
+		err := errMediaError
+		if _, remapped := d.badBlocks[blockID]; remapped || !d.mediaErrors[blockID] {
+			if d.faults != nil || d.failure != nil || d.latency != nil {
+				err = d.faultyRead(d.physical(blockID), data, d.injected(false, blockID))
+			} else {
+				err = d.storeRead(d.physical(blockID), data)
+			}
+		}
+		if err != nil {
+			d.noteError(&d.metrics.readErrors, fmt.Errorf("read of block %d: %w", blockID, err))
+		}
+		return err
+	})
+}
+
+func (d *Disk) writeAt(data []byte, blockID int) error {
+	return d.withRetries(&d.mu, &d.metrics.writeRetries, func() error {
+		err := errMediaError
+		if _, remapped := d.badBlocks[blockID]; remapped || !d.mediaErrors[blockID] {
+			if d.faults != nil || d.failure != nil || d.crash != nil || d.latency != nil {
+				err = d.faultyWrite(d.physical(blockID), data, d.injected(true, blockID))
+			} else {
+				err = d.storeWrite(d.physical(blockID), data)
+			}
+		}
+		if err != nil {
+			d.noteError(&d.metrics.writeErrors, fmt.Errorf("write of block %d: %w", blockID, err))
+		}
+		return err
+	})
+}
+
+// noteError counts an I/O error reported by the backend and keeps it as
+// the disk's last error.
+func (d *Disk) noteError(errs *counter, err error) {
+	errs.add(1)
+	d.lastErr.Store(&diskError{at: d.clock.Now(), msg: err.Error()})
+}
+
+// remapBlock moves blockID into the next free relocation slot. A block
+// remapped because of a read error is pending: its old contents are gone
+// and reads fail until it is rewritten. Caller must hold d.mu for writing.
+func (d *Disk) remapBlock(blockID int, pending bool) error {
+	if _, ok := d.badBlocks[blockID]; ok {
+		return nil
+	}
+	if len(d.badBlocks) >= d.remapReserve {
+		return fmt.Errorf("no spare blocks left on %s", d.path)
+	}
+
+	d.badBlocks[blockID] = &remapEntry{Slot: len(d.badBlocks), Pending: pending}
+	return d.saveBadBlockList()
+}
+
+func badBlockListPath(path string) string {
+	return path + ".badblocks"
+}
+
+func loadBadBlockList(path string) (map[int]*remapEntry, error) {
+	badBlocks := make(map[int]*remapEntry)
+
+	raw, err := os.ReadFile(badBlockListPath(path))
+	if os.IsNotExist(err) {
+		return badBlocks, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bad block list for %s: %w", path, err)
+	}
+
+	if err := json.Unmarshal(raw, &badBlocks); err != nil {
+		return nil, fmt.Errorf("corrupt bad block list for %s: %w", path, err)
+	}
+	return badBlocks, nil
+}
+
+func (d *Disk) saveBadBlockList() error {
+	return writeBadBlockList(d.path, d.badBlocks)
+}
+
+func writeBadBlockList(path string, badBlocks map[int]*remapEntry) error {
+	raw, err := json.Marshal(badBlocks)
+	if err != nil {
+		return err
+	}
+
+	tmp := badBlockListPath(path) + ".tmp"
+	if err := os.WriteFile(tmp, raw, 0644); err != nil {
+		return fmt.Errorf("failed to save bad block list for %s: %w", path, err)
+	}
+	return os.Rename(tmp, badBlockListPath(path))
+}
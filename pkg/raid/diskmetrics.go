@@ -0,0 +1,79 @@
+package raid
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// counter is a count kept since the disk was opened that ResetStats can
+// zero for reporting without losing the lifetime value, which SMART and
+// the thermal model read.
+type counter struct {
+	total atomic.Uint64
+	base  atomic.Uint64 // total at the last reset
+}
+
+func (c *counter) add(n uint64) { c.total.Add(n) }
+
+// lifetime returns the count since the disk was opened.
+func (c *counter) lifetime() uint64 { return c.total.Load() }
+
+// sinceReset returns the count since the last reset.
+func (c *counter) sinceReset() uint64 {
+	base := c.base.Load() // before total, which only grows, so this never goes negative
+	return c.total.Load() - base
+}
+
+func (c *counter) reset() { c.base.Store(c.total.Load()) }
+
+// diskMetrics counts a member's transfers. Everything is atomic, so the
+// I/O paths never take the disk lock to count.
+type diskMetrics struct {
+	reads, writes             counter // completed transfers
+	bytesRead, bytesWritten   counter
+	readErrors, writeErrors   counter // failed transfers, counted whether or not they were recovered
+	readRetries, writeRetries counter // transfers tried again under the retry policy
+	inFlight                  atomic.Int64
+	readHist, writeHist       latencyHistogram // successful transfers only
+}
+
+func (m *diskMetrics) read(n int, sample time.Duration) {
+	m.reads.add(1)
+	m.bytesRead.add(uint64(n))
+	m.readHist.record(sample)
+}
+
+func (m *diskMetrics) wrote(n int, sample time.Duration) {
+	m.writes.add(1)
+	m.bytesWritten.add(uint64(n))
+	m.writeHist.record(sample)
+}
+
+// transfers returns the reads and writes since the disk was opened.
+func (m *diskMetrics) transfers() uint64 {
+	return m.reads.lifetime() + m.writes.lifetime()
+}
+
+// fill sets the counters of st, as of the last reset.
+func (m *diskMetrics) fill(st *DiskStats) {
+	st.ReadCount = m.reads.sinceReset()
+	st.WriteCount = m.writes.sinceReset()
+	st.BytesRead = m.bytesRead.sinceReset()
+	st.BytesWritten = m.bytesWritten.sinceReset()
+	st.ReadErrors = m.readErrors.sinceReset()
+	st.WriteErrors = m.writeErrors.sinceReset()
+	st.ReadRetries = m.readRetries.sinceReset()
+	st.WriteRetries = m.writeRetries.sinceReset()
+	st.InFlight = int(m.inFlight.Load())
+	st.ReadLatency = m.readHist.snapshot()
+	st.WriteLatency = m.writeHist.snapshot()
+}
+
+func (m *diskMetrics) reset() {
+	for _, c := range []*counter{&m.reads, &m.writes, &m.bytesRead, &m.bytesWritten,
+		&m.readErrors, &m.writeErrors, &m.readRetries, &m.writeRetries} {
+		c.reset()
+	}
+	m.readHist.reset()
+	m.writeHist.reset()
+}
@@ -0,0 +1,49 @@
+package raid
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Failures callers can test for with errors.Is. Errors returned by the
+// array wrap one of these where it applies, often inside a RAIDError.
+var (
+	ErrBlockOutOfRange  = errors.New("block out of range")
+	ErrDiskFailed       = errors.New("disk is failed")
+	ErrArrayDegraded    = errors.New("array is degraded")
+	ErrArrayFailed      = errors.New("array has failed")
+	ErrMultipleFailures = errors.New("multiple disk failures")
+	ErrShortIO          = errors.New("short I/O")
+	ErrTimeout          = errors.New("I/O timed out")
+	ErrClosed           = errors.New("array is closed")
+)
+
+// RAIDError records which member and operation a failure belongs to.
+// Retrieve it with errors.As.
+type RAIDError struct {
+	Op    string // "read", "write", "reconstruct", ...
+	Disk  int    // member index, -1 when no single member is to blame
+	Block int    // block on the member, or logical block when Disk is -1; -1 when not block-specific
+	Err   error
+}
+
+func (e *RAIDError) Error() string {
+	var where string
+	switch {
+	case e.Disk >= 0 && e.Block >= 0:
+		where = fmt.Sprintf(" disk %d block %d", e.Disk, e.Block)
+	case e.Disk >= 0:
+		where = fmt.Sprintf(" disk %d", e.Disk)
+	case e.Block >= 0:
+		where = fmt.Sprintf(" block %d", e.Block)
+	}
+	return e.Op + where + ": " + e.Err.Error()
+}
+
+func (e *RAIDError) Unwrap() error {
+	return e.Err
+}
+
+func outOfRange(what string, id, limit int) error {
+	return fmt.Errorf("%w: %s %d not in [0, %d)", ErrBlockOutOfRange, what, id, limit)
+}
@@ -0,0 +1,145 @@
+package raid
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+// eventLog appends every event the array emits to a file, one JSON object
+// per line, so its history survives the process.
+type eventLog struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// loggedEvent is an Event as stored in the log, with its error as text.
+type loggedEvent struct {
+	Event
+	Error string `json:"error,omitempty"`
+}
+
+func (t *EventType) UnmarshalText(text []byte) error {
+	i := slices.Index(eventNames[:], string(text))
+	if i < 0 {
+		return fmt.Errorf("unknown event type %q", text)
+	}
+	*t = EventType(i)
+	return nil
+}
+
+// openEventLog starts appending events to path, recording the array's
+// creation if its images were just made. An empty path disables the log.
+func (r *RAIDArray) openEventLog(path string) error {
+	r.config.EventLog = path
+	if path == "" {
+		return nil
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open event log: %w", err)
+	}
+	r.eventLog.mu.Lock()
+	r.eventLog.file = f
+	r.eventLog.mu.Unlock()
+
+	if r.created {
+		r.emit(Event{Type: EventArrayCreated, Disk: -1, Stripe: -1,
+			Detail: fmt.Sprintf("RAID %d, %d disks of %d blocks of %d bytes", r.level, r.numDisks, r.disks[0].Capacity(), r.blockSize)})
+	}
+	return nil
+}
+
+func (l *eventLog) append(e Event) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return
+	}
+	if err := writeEvent(l.file, e); err != nil {
+		// the array keeps working without its history
+		l.file.Close()
+		l.file = nil
+	}
+}
+
+func (l *eventLog) close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+func writeEvent(f *os.File, e Event) error {
+	le := loggedEvent{Event: e}
+	if e.Err != nil {
+		le.Error = e.Err.Error()
+	}
+	line, err := json.Marshal(le)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+// AppendEventLog adds an event to the log at path, for changes made while
+// the array is not open, such as editing its metadata. A zero Time is set
+// to now.
+func AppendEventLog(path string, e Event) error {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open event log: %w", err)
+	}
+	err = writeEvent(f, e)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// ReadEventLog returns the events recorded at path, oldest first.
+func ReadEventLog(path string) ([]Event, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open event log: %w", err)
+	}
+	defer f.Close()
+
+	events := []Event{}
+	sc := bufio.NewScanner(f)
+	for line := 1; sc.Scan(); line++ {
+		var le loggedEvent
+		if err := json.Unmarshal(sc.Bytes(), &le); err != nil {
+			return nil, fmt.Errorf("%s line %d: %w", path, line, err)
+		}
+		if le.Error != "" {
+			le.Event.Err = errors.New(le.Error)
+		}
+		events = append(events, le.Event)
+	}
+	return events, sc.Err()
+}
+
+// EventLog returns the events recorded in the array's event log, oldest
+// first.
+func (r *RAIDArray) EventLog() ([]Event, error) {
+	if r.config.EventLog == "" {
+		return nil, fmt.Errorf("no event log configured")
+	}
+	return ReadEventLog(r.config.EventLog)
+}
@@ -0,0 +1,161 @@
+package raid
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+type EventType int
+
+const (
+	EventDiskFailed       EventType = iota // a member was marked failed
+	EventArrayDegraded                     // the first member of a redundant array failed
+	EventArrayFailed                       // one member more failed than the level tolerates
+	EventRebuildStarted                    // Stripe is where the rebuild starts
+	EventRebuildCompleted                  // the rebuilt member is healthy again
+	EventRebuildFailed                     // Err says why, Stripe is where a new rebuild resumes
+	EventScrubMismatch                     // Stripe failed verification during a scrub or check
+	EventWritesPaused                      // backing storage ran out of space
+	EventWritesResumed                     // space is available again
+	EventFillLevel                         // Detail is the new alert level
+	EventDiskSlow                          // a member's latency broke the slow disk policy, Detail gives it
+	EventDiskRecovered                     // a slow member's latency is back within the policy
+	EventArrayCreated                      // the member images were created, Detail describes the array
+	EventScrubCompleted                    // a scrub or check finished, Detail sums it up
+	EventConfigChanged                     // a setting was changed at runtime, Detail says which
+	EventRebuildProgress                   // periodically during a rebuild, Stripe is the cursor and Detail the rate and ETA
+	EventVolumeChanged                     // a volume was created, resized or deleted, Detail says which
+)
+
+var eventNames = [...]string{
+	EventDiskFailed:       "disk_failed",
+	EventArrayDegraded:    "array_degraded",
+	EventArrayFailed:      "array_failed",
+	EventRebuildStarted:   "rebuild_started",
+	EventRebuildCompleted: "rebuild_completed",
+	EventRebuildFailed:    "rebuild_failed",
+	EventScrubMismatch:    "scrub_mismatch",
+	EventWritesPaused:     "writes_paused",
+	EventWritesResumed:    "writes_resumed",
+	EventFillLevel:        "fill_level",
+	EventDiskSlow:         "disk_slow",
+	EventDiskRecovered:    "disk_recovered",
+	EventArrayCreated:     "array_created",
+	EventScrubCompleted:   "scrub_completed",
+	EventConfigChanged:    "config_changed",
+	EventRebuildProgress:  "rebuild_progress",
+	EventVolumeChanged:    "volume_changed",
+}
+
+func (t EventType) String() string {
+	if t >= 0 && int(t) < len(eventNames) {
+		return eventNames[t]
+	}
+	return fmt.Sprintf("event(%d)", int(t))
+}
+
+func (t EventType) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+// Event describes a change in array state.
+type Event struct {
+	Type   EventType `json:"type"`
+	Time   time.Time `json:"time"`
+	Disk   int       `json:"disk"`   // member concerned, -1 for array-wide events
+	Stripe int       `json:"stripe"` // -1 when not about a stripe
+	Detail string    `json:"detail,omitempty"`
+	Err    error     `json:"-"`
+}
+
+const defaultEventBuffer = 64
+
+type eventBus struct {
+	mu     sync.Mutex
+	subs   map[chan Event]struct{}
+	closed bool
+}
+
+// Subscribe returns a channel receiving every event from now on, and a
+// function that ends the subscription. Events are never waited on: if the
+// channel's buffer is full the event is dropped for that subscriber. The
+// channel is closed on cancel or when the array is closed. A buffer of 0
+// picks a default size.
+func (r *RAIDArray) Subscribe(buffer int) (events <-chan Event, cancel func()) {
+	if buffer <= 0 {
+		buffer = defaultEventBuffer
+	}
+	ch := make(chan Event, buffer)
+
+	r.events.mu.Lock()
+	defer r.events.mu.Unlock()
+	if r.events.closed {
+		close(ch)
+		return ch, func() {}
+	}
+	if r.events.subs == nil {
+		r.events.subs = make(map[chan Event]struct{})
+	}
+	r.events.subs[ch] = struct{}{}
+
+	return ch, func() {
+		r.events.mu.Lock()
+		defer r.events.mu.Unlock()
+		if _, ok := r.events.subs[ch]; ok {
+			delete(r.events.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+func (r *RAIDArray) emit(e Event) {
+	e.Time = r.clock.Now()
+	r.eventLog.append(e)
+
+	r.events.mu.Lock()
+	defer r.events.mu.Unlock()
+	for ch := range r.events.subs {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+func (b *eventBus) close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for ch := range b.subs {
+		close(ch)
+	}
+	b.subs = nil
+}
+
+// diskFailed runs whenever a member goes from healthy to failed.
+func (r *RAIDArray) diskFailed(diskIndex int) {
+	if r.raid0 != nil {
+		r.raid0.memberLost(diskIndex)
+	}
+	r.emit(Event{Type: EventDiskFailed, Disk: diskIndex, Stripe: -1})
+
+	// a member being rebuilt cannot cover a second failure either
+	rebuilding := -1
+	if r.raid5 != nil {
+		rebuilding = int(r.raid5.rebuild.disk.Load())
+	}
+	failed := 0
+	for i, disk := range r.disks {
+		if disk.IsFailed() || i == rebuilding {
+			failed++
+		}
+	}
+
+	switch {
+	case failed == r.tolerated()+1:
+		r.emit(Event{Type: EventArrayFailed, Disk: -1, Stripe: -1})
+	case failed == 1:
+		r.emit(Event{Type: EventArrayDegraded, Disk: -1, Stripe: -1})
+	}
+}
@@ -0,0 +1,281 @@
+package raid
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Fault makes some of a member's transfers misbehave, for exercising error
+// handling that failing the whole disk with SetFailed cannot reach. A
+// transfer affected by several faults gets all their effects.
+type Fault struct {
+	Reads       bool          // applies to reads
+	Writes      bool          // applies to writes
+	Blocks      BlockRange    // blocks affected; a zero Count means every block
+	Probability float64       // chance a matching transfer is affected, 0 means always
+	Err         error         // fail the transfer with this error without doing it
+	Latency     time.Duration // delay the transfer
+	Short       bool          // transfer only the first half of the block and fail with ErrShortIO
+	FlipBits    int           // silently flip this many random bits of the data read or written
+}
+
+type faultState struct {
+	faults []Fault
+	rand   *lockedRand
+}
+
+// FailureModel makes a member fail by itself, so long soak runs reach
+// degraded and double-failure states without SetFailed calls. The disk's
+// lifetime is drawn from an exponential distribution with mean MTTF on the
+// array's clock, and noticed at its next transfer; the replacement's
+// lifetime starts then. The zero value never fails.
+type FailureModel struct {
+	PerOp float64       // chance that any one transfer fails the whole disk
+	MTTF  time.Duration // mean time to failure, 0 disables
+}
+
+type failureState struct {
+	FailureModel
+	mu       sync.Mutex
+	deadline time.Time // when the current lifetime ends, zero without MTTF
+	rand     *lockedRand
+}
+
+func (m FailureModel) validate() error {
+	if m.PerOp < 0 || m.PerOp > 1 || m.MTTF < 0 {
+		return fmt.Errorf("invalid failure model %+v", m)
+	}
+	return nil
+}
+
+func (d *Disk) setFailureModel(m FailureModel, rnd *lockedRand) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.failure = nil
+	if m != (FailureModel{}) {
+		d.failure = &failureState{FailureModel: m, rand: rnd}
+		d.failure.newLifetime(d.clock.Now())
+	}
+}
+
+// SetFailureModel makes a member fail spontaneously as described by m; the
+// zero FailureModel stops it.
+func (r *RAIDArray) SetFailureModel(diskIndex int, m FailureModel) error {
+	if diskIndex < 0 || diskIndex >= r.numDisks {
+		return fmt.Errorf("invalid disk index %d", diskIndex)
+	}
+	if err := m.validate(); err != nil {
+		return err
+	}
+	r.disks[diskIndex].setFailureModel(m, r.rand)
+	return nil
+}
+
+func (f *failureState) newLifetime(now time.Time) {
+	if f.MTTF > 0 {
+		f.deadline = now.Add(time.Duration(f.rand.ExpFloat64() * float64(f.MTTF)))
+	}
+}
+
+// roll decides whether the disk dies at this transfer.
+func (f *failureState) roll(now time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.MTTF > 0 && !now.Before(f.deadline) {
+		f.newLifetime(now)
+		return fmt.Errorf("%w: simulated end of life", ErrDiskFailed)
+	}
+	if f.PerOp > 0 && f.rand.Float64() < f.PerOp {
+		return fmt.Errorf("%w: simulated failure", ErrDiskFailed)
+	}
+	return nil
+}
+
+// crashState counts down the member writes left before a simulated power
+// cut. It is shared by every member of the array.
+type crashState struct {
+	left atomic.Int64
+}
+
+// CrashAfter simulates a power cut after the given number of further
+// member block writes, across all members. The write at the cut is torn,
+// storing only the first half of the block, and every later member write
+// is silently lost; all of them still report success, as nobody is left to
+// see the errors. Close the array and open it again from its images to
+// find the state a crash mid-stripe leaves behind, for example data written
+// but parity not. Writes still held in emulated drive caches are not
+// affected. A negative count cancels a pending crash.
+func (r *RAIDArray) CrashAfter(writes int) {
+	var c *crashState
+	if writes >= 0 {
+		c = &crashState{}
+		c.left.Store(int64(writes))
+	}
+	for _, disk := range r.disks {
+		disk.mu.Lock()
+		disk.crash = c
+		disk.mu.Unlock()
+	}
+}
+
+// faultEffect is what the faults matching one transfer add up to.
+type faultEffect struct {
+	err   error
+	short bool
+	torn  bool // power is cut during the write: store half and report success
+	lost  bool // power is already gone: store nothing and report success
+	flips int
+}
+
+// InjectFaults replaces the disk's faults. Calling it with none restores
+// normal I/O.
+func (d *Disk) InjectFaults(faults ...Fault) error {
+	return d.injectFaults(faults, newLockedRand(nil))
+}
+
+func (d *Disk) injectFaults(faults []Fault, rnd *lockedRand) error {
+	for i, f := range faults {
+		switch {
+		case !f.Reads && !f.Writes:
+			return fmt.Errorf("fault %d applies to neither reads nor writes", i)
+		case f.Blocks.Start < 0 || f.Blocks.Count < 0:
+			return fmt.Errorf("fault %d has an invalid block range", i)
+		case f.Probability < 0 || f.Probability > 1:
+			return fmt.Errorf("fault %d probability %v is not between 0 and 1", i, f.Probability)
+		case f.Latency < 0 || f.FlipBits < 0:
+			return fmt.Errorf("fault %d settings must not be negative", i)
+		}
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.faults = nil
+	if len(faults) > 0 {
+		d.faults = &faultState{faults: append([]Fault(nil), faults...), rand: rnd}
+	}
+	return nil
+}
+
+// InjectFaults makes a member's I/O misbehave as described by faults; no
+// faults restores normal I/O.
+func (r *RAIDArray) InjectFaults(diskIndex int, faults ...Fault) error {
+	if diskIndex < 0 || diskIndex >= r.numDisks {
+		return fmt.Errorf("invalid disk index %d", diskIndex)
+	}
+	return r.disks[diskIndex].injectFaults(faults, r.rand)
+}
+
+// SimulateMediaError makes a block of a member unreadable until it is
+// rewritten, like a sector gone bad.
+func (r *RAIDArray) SimulateMediaError(diskIndex, blockID int) error {
+	if diskIndex < 0 || diskIndex >= r.numDisks {
+		return fmt.Errorf("invalid disk index %d", diskIndex)
+	}
+	if blockID < 0 || blockID >= r.disks[diskIndex].Capacity() {
+		return outOfRange("block ID", blockID, r.disks[diskIndex].Capacity())
+	}
+	r.disks[diskIndex].SimulateMediaError(blockID)
+	return nil
+}
+
+// injected rolls the simulated failure and the faults matching a transfer
+// of blockID, and sleeps for the modelled and injected latency. Callers
+// hold d.mu.
+func (d *Disk) injected(write bool, blockID int) faultEffect {
+	var eff faultEffect
+	if write && d.crash != nil {
+		n := d.crash.left.Add(-1)
+		eff.torn, eff.lost = n == -1, n < -1
+		if eff.torn || eff.lost {
+			return eff
+		}
+	}
+	if d.failure != nil {
+		eff.err = d.failure.roll(d.clock.Now())
+	}
+	var delay time.Duration
+	if d.latency != nil {
+		delay = d.latency.delay(blockID, d.numBlocks)
+	}
+	var faults []Fault
+	if d.faults != nil {
+		faults = d.faults.faults
+	}
+	for _, f := range faults {
+		if write && !f.Writes || !write && !f.Reads {
+			continue
+		}
+		if f.Blocks.Count > 0 && (blockID < f.Blocks.Start || blockID >= f.Blocks.Start+f.Blocks.Count) {
+			continue
+		}
+		if f.Probability > 0 && d.faults.rand.Float64() >= f.Probability {
+			continue
+		}
+		delay += f.Latency
+		if eff.err == nil {
+			eff.err = f.Err
+		}
+		eff.short = eff.short || f.Short
+		eff.flips += f.FlipBits
+	}
+	if d.timeout > 0 && delay > d.timeout {
+		sleep(d.clock, d.timeout)
+		eff.err = d.timedOut()
+	} else {
+		sleep(d.clock, delay)
+	}
+	return eff
+}
+
+// flipBits flips n randomly chosen bits of data.
+func (d *Disk) flipBits(data []byte, n int) {
+	for range n {
+		bit := d.faults.rand.Intn(len(data) * 8)
+		data[bit/8] ^= 1 << (bit % 8)
+	}
+}
+
+// faultyRead reads a block under the effect of injected faults.
+func (d *Disk) faultyRead(block int, buf []byte, eff faultEffect) error {
+	if eff.err != nil {
+		return eff.err
+	}
+	if err := d.storeRead(block, buf); err != nil {
+		return err
+	}
+	d.flipBits(buf, eff.flips)
+	if eff.short {
+		clear(buf[len(buf)/2:])
+		return fmt.Errorf("%w: injected short read of block %d", ErrShortIO, block)
+	}
+	return nil
+}
+
+// faultyWrite writes a block under the effect of injected faults. A short
+// or torn write leaves the second half of the block as it was.
+func (d *Disk) faultyWrite(block int, data []byte, eff faultEffect) error {
+	if eff.lost {
+		return nil
+	}
+	if eff.err != nil {
+		return eff.err
+	}
+	data = bytes.Clone(data)
+	d.flipBits(data, eff.flips)
+	if !eff.short && !eff.torn {
+		return d.storeWrite(block, data)
+	}
+
+	old := make([]byte, len(data))
+	if err := d.storeRead(block, old); err != nil {
+		return err
+	}
+	copy(old, data[:len(data)/2])
+	if err := d.storeWrite(block, old); err != nil || eff.torn {
+		return err
+	}
+	return fmt.Errorf("%w: injected short write of block %d", ErrShortIO, block)
+}
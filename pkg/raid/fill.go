@@ -0,0 +1,135 @@
+package raid
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+const fillCheckInterval = 10 * time.Second
+
+// FillLevel compares the space member images occupy on their backing
+// storage with what they were provisioned. Sparse images only grow as
+// blocks are written, so the storage can run out long before the array
+// is full.
+type FillLevel struct {
+	Provisioned int64 `json:"provisioned"` // bytes the member images may grow to
+	Allocated   int64 `json:"allocated"`   // bytes actually allocated to the images
+	Available   int64 `json:"available"`   // free bytes on the backing filesystems
+}
+
+// Ratio is the share of the storage reachable by the array that is in use.
+func (f FillLevel) Ratio() float64 {
+	if f.Allocated+f.Available == 0 {
+		return 0
+	}
+	return float64(f.Allocated) / float64(f.Allocated+f.Available)
+}
+
+// Overcommitted reports whether the images could not all grow to their
+// provisioned size with the space that is left.
+func (f FillLevel) Overcommitted() bool {
+	return f.Provisioned-f.Allocated > f.Available
+}
+
+type fillAlert int
+
+const (
+	fillOK fillAlert = iota
+	fillWarning
+	fillCritical
+)
+
+func (a fillAlert) logLevel() slog.Level {
+	switch a {
+	case fillWarning:
+		return slog.LevelWarn
+	case fillCritical:
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+func (a fillAlert) String() string {
+	switch a {
+	case fillWarning:
+		return "warning"
+	case fillCritical:
+		return "critical"
+	default:
+		return "ok"
+	}
+}
+
+// alert classifies a fill level against the thresholds. A threshold of 0 is
+// disabled. Storage that can hold every image at full size never alerts,
+// since the array itself cannot run it out of space.
+func (f FillLevel) alert(warn, critical float64) fillAlert {
+	if !f.Overcommitted() {
+		return fillOK
+	}
+	ratio := f.Ratio()
+	switch {
+	case critical > 0 && ratio >= critical:
+		return fillCritical
+	case warn > 0 && ratio >= warn:
+		return fillWarning
+	default:
+		return fillOK
+	}
+}
+
+// FillLevel sums allocation over all members. Members sharing a filesystem
+// count its free space once. Block devices and other backends are left out.
+func (r *RAIDArray) FillLevel() (FillLevel, error) {
+	var f FillLevel
+	seen := make(map[uint64]bool)
+	for i, disk := range r.disks {
+		if fb, ok := disk.store.(*fileBackend); !ok || fb.device {
+			continue
+		}
+		disk.mu.RLock()
+		path := disk.path
+		f.Provisioned += int64(disk.blockSize) * int64(disk.numBlocks+disk.remapReserve)
+		disk.mu.RUnlock()
+
+		allocated, dev, available, err := storageUsage(path)
+		if err != nil {
+			return FillLevel{}, fmt.Errorf("failed to measure disk %d: %w", i, err)
+		}
+		f.Allocated += allocated
+		if !seen[dev] {
+			seen[dev] = true
+			f.Available += available
+		}
+	}
+	return f, nil
+}
+
+func (r *RAIDArray) fillLoop(warn, critical float64) {
+	ticker := r.clock.NewTicker(fillCheckInterval)
+	defer ticker.Stop()
+
+	last := fillOK
+	for {
+		select {
+		case <-r.done:
+			return
+		case <-ticker.C():
+		}
+
+		f, err := r.FillLevel()
+		if err != nil {
+			r.log.Error("fill level check failed", "err", err)
+			continue
+		}
+		if a := f.alert(warn, critical); a != last {
+			last = a
+			r.log.Log(context.Background(), a.logLevel(), "backing storage fill level changed",
+				"alert", a.String(), "percent", f.Ratio()*100, "unallocated", f.Provisioned-f.Allocated, "available", f.Available)
+			r.emit(Event{Type: EventFillLevel, Disk: -1, Stripe: -1, Detail: a.String()})
+		}
+	}
+}
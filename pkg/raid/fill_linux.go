@@ -0,0 +1,22 @@
+package raid
+
+import (
+	"os"
+	"syscall"
+)
+
+// storageUsage returns the bytes allocated to the file at path, the device
+// holding it, and the space left on that device for unprivileged writers.
+func storageUsage(path string) (allocated int64, dev uint64, available int64, err error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	st := info.Sys().(*syscall.Stat_t)
+
+	var fs syscall.Statfs_t
+	if err := syscall.Statfs(path, &fs); err != nil {
+		return 0, 0, 0, err
+	}
+	return st.Blocks * 512, st.Dev, int64(fs.Bavail) * fs.Bsize, nil
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package raid
+
+import "errors"
+
+func storageUsage(path string) (allocated int64, dev uint64, available int64, err error) {
+	return 0, 0, 0, errors.New("fill level tracking is only supported on Linux")
+}
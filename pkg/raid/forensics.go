@@ -0,0 +1,218 @@
+package raid
+
+import (
+	"fmt"
+	"os"
+)
+
+type MemberGeometry struct {
+	Level         RAIDLevel
+	NumDisks      int
+	DiskIndex     int
+	BlockSize     int
+	BlocksPerDisk int             // 0 = derive from image size
+	RemapReserve  int             // blocks after the data set aside for remapping bad blocks
+	Placement     PlacementPolicy // RAID5 parity placement, rotating by default
+}
+
+type MemberBlock struct {
+	PhysicalBlock int  `json:"physical_block"`
+	LogicalBlock  int  `json:"logical_block"` // -1 for parity blocks
+	Parity        bool `json:"parity"`
+}
+
+type RecoveryEstimate struct {
+	ArrayCapacity int // logical blocks in the full array
+	Recoverable   int // logical blocks readable from this member alone
+	Percent       float64
+}
+
+// MemberImage is a read-only view of a single member image, interpreted
+// without the rest of the array. Blocks the member remapped into its
+// reserve are read from there, following the bad block list kept next to
+// the image.
+type MemberImage struct {
+	file  *os.File
+	geo   MemberGeometry
+	remap map[int]*remapEntry
+}
+
+func OpenMemberImage(path string, geo MemberGeometry) (*MemberImage, error) {
+	if geo.BlockSize <= 0 {
+		return nil, fmt.Errorf("block size must be positive")
+	}
+	if geo.DiskIndex < 0 || geo.DiskIndex >= geo.NumDisks {
+		return nil, fmt.Errorf("disk index %d out of bounds [0, %d)", geo.DiskIndex, geo.NumDisks)
+	}
+
+	switch geo.Level {
+	case RAID0, RAID1:
+		if geo.NumDisks < 2 {
+			return nil, fmt.Errorf("RAID requires at least 2 disks")
+		}
+	case RAID5:
+		if geo.NumDisks < 3 {
+			return nil, fmt.Errorf("RAID 5 requires at least 3 disks")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported RAID level: %d", geo.Level)
+	}
+
+	if geo.Placement == nil {
+		geo.Placement = rotatingParity{}
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open member %s: %w", path, err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	if geo.RemapReserve < 0 {
+		file.Close()
+		return nil, fmt.Errorf("remap reserve must not be negative")
+	}
+	available := int(info.Size() / int64(geo.BlockSize))
+	if geo.BlocksPerDisk == 0 {
+		geo.BlocksPerDisk = available - geo.RemapReserve
+	}
+	if geo.BlocksPerDisk <= 0 || geo.BlocksPerDisk+geo.RemapReserve > available {
+		file.Close()
+		return nil, fmt.Errorf("image %s holds %d blocks, geometry needs %d", path, available, geo.BlocksPerDisk+geo.RemapReserve)
+	}
+
+	remap, err := loadBadBlockList(path)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	for block, e := range remap {
+		if block < 0 || block >= geo.BlocksPerDisk || e.Slot < 0 || e.Slot >= geo.RemapReserve {
+			file.Close()
+			return nil, fmt.Errorf("bad block list for %s remaps block %d to slot %d, outside a reserve of %d", path, block, e.Slot, geo.RemapReserve)
+		}
+	}
+	if geo.Level == RAID5 {
+		if err := validatePlacement(geo.Placement, geo.NumDisks, geo.BlocksPerDisk); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+
+	return &MemberImage{file: file, geo: geo, remap: remap}, nil
+}
+
+func (m *MemberImage) Geometry() MemberGeometry {
+	return m.geo
+}
+
+func (m *MemberImage) Blocks() []MemberBlock {
+	blocks := make([]MemberBlock, m.geo.BlocksPerDisk)
+	for phys := range blocks {
+		blocks[phys] = m.locate(phys)
+	}
+	return blocks
+}
+
+func (m *MemberImage) Estimate() RecoveryEstimate {
+	est := RecoveryEstimate{}
+	switch m.geo.Level {
+	case RAID0:
+		est.ArrayCapacity = m.geo.BlocksPerDisk * m.geo.NumDisks
+	case RAID1:
+		est.ArrayCapacity = m.geo.BlocksPerDisk
+	case RAID5:
+		est.ArrayCapacity = m.geo.BlocksPerDisk * (m.geo.NumDisks - 1)
+	}
+
+	for _, b := range m.Blocks() {
+		if !b.Parity && !m.lost(b.PhysicalBlock) {
+			est.Recoverable++
+		}
+	}
+	est.Percent = 100 * float64(est.Recoverable) / float64(est.ArrayCapacity)
+	return est
+}
+
+// ReadPhysicalBlock reads a data-area block, from its remap slot if it was
+// remapped. A block remapped after a read error and never rewritten has no
+// contents to return.
+func (m *MemberImage) ReadPhysicalBlock(phys int) ([]byte, error) {
+	if phys < 0 || phys >= m.geo.BlocksPerDisk {
+		return nil, outOfRange("block ID", phys, m.geo.BlocksPerDisk)
+	}
+
+	at := phys
+	if e, ok := m.remap[phys]; ok {
+		if e.Pending {
+			return nil, fmt.Errorf("block %d was lost to a media error and not rewritten", phys)
+		}
+		at = m.geo.BlocksPerDisk + e.Slot
+	}
+
+	data := make([]byte, m.geo.BlockSize)
+	if _, err := m.file.ReadAt(data, int64(at)*int64(m.geo.BlockSize)); err != nil {
+		return nil, fmt.Errorf("read error on block %d: %w", phys, err)
+	}
+	return data, nil
+}
+
+// Extract calls fn for every logical data block held by the member, in
+// physical order. Parity blocks and blocks lost to media errors are
+// skipped.
+func (m *MemberImage) Extract(fn func(logicalBlockID int, data []byte) error) error {
+	for _, b := range m.Blocks() {
+		if b.Parity || m.lost(b.PhysicalBlock) {
+			continue
+		}
+		data, err := m.ReadPhysicalBlock(b.PhysicalBlock)
+		if err != nil {
+			return err
+		}
+		if err := fn(b.LogicalBlock, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *MemberImage) Close() error {
+	return m.file.Close()
+}
+
+// lost reports whether a block was remapped after a read error and its
+// contents never rewritten.
+func (m *MemberImage) lost(phys int) bool {
+	e, ok := m.remap[phys]
+	return ok && e.Pending
+}
+
+func (m *MemberImage) locate(phys int) MemberBlock {
+	return m.geo.locate(phys)
+}
+
+func (g MemberGeometry) locate(phys int) MemberBlock {
+	n, disk := g.NumDisks, g.DiskIndex
+
+	switch g.Level {
+	case RAID0:
+		return MemberBlock{PhysicalBlock: phys, LogicalBlock: phys*n + disk}
+	case RAID5:
+		parityDisk := g.Placement.ParityDisk(phys, n)
+		if disk == parityDisk {
+			return MemberBlock{PhysicalBlock: phys, LogicalBlock: -1, Parity: true}
+		}
+		offset := disk
+		if disk > parityDisk {
+			offset--
+		}
+		return MemberBlock{PhysicalBlock: phys, LogicalBlock: phys*(n-1) + offset}
+	default:
+		return MemberBlock{PhysicalBlock: phys, LogicalBlock: phys}
+	}
+}
@@ -0,0 +1,470 @@
+//go:build linux
+
+package raid
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+// FUSE kernel protocol, as in linux/fuse.h. Only what a directory holding
+// two files needs is implemented; everything else answers ENOSYS.
+const (
+	fuseLookup      = 1
+	fuseForget      = 2
+	fuseGetattr     = 3
+	fuseSetattr     = 4
+	fuseOpen        = 14
+	fuseRead        = 15
+	fuseWrite       = 16
+	fuseStatfs      = 17
+	fuseRelease     = 18
+	fuseFsync       = 20
+	fuseFlush       = 25
+	fuseInit        = 26
+	fuseOpendir     = 27
+	fuseReaddir     = 28
+	fuseReleasedir  = 29
+	fuseFsyncdir    = 30
+	fuseAccess      = 34
+	fuseInterrupt   = 36
+	fuseDestroy     = 38
+	fuseBatchForget = 42
+	fusePoll        = 40
+
+	fuseKernelMajor = 7
+	fuseKernelMinor = 26
+
+	fuseOpenDirectIO = 1 << 0 // FOPEN_DIRECT_IO: bypass the page cache
+
+	fuseMaxWrite   = 128 << 10
+	fuseBufferSize = fuseMaxWrite + 4096
+
+	fuseInHeaderSize  = 40
+	fuseOutHeaderSize = 16
+	fuseAttrSize      = 88
+)
+
+const (
+	fuseRootIno  = 1
+	fuseImageIno = 2
+	fuseStatsIno = 3
+)
+
+var fuseNames = map[string]uint64{
+	"array.img":  fuseImageIno,
+	"stats.json": fuseStatsIno,
+}
+
+// FUSEMount exposes an array as a directory holding array.img, the whole
+// logical address space as one file, and stats.json, a snapshot of the
+// array's statistics taken when the file is opened. I/O bypasses the page
+// cache, so reads and writes of array.img go straight to the array.
+type FUSEMount struct {
+	r          *RAIDArray
+	dir        string
+	dev        *os.File
+	fusermount string // helper that mounted it and must unmount it, empty when mounted directly
+
+	mu    sync.Mutex
+	stats map[uint64][]byte // stats.json contents by open file handle
+	fh    uint64
+
+	done chan struct{}
+	err  error
+}
+
+// MountFUSE mounts the array on dir, which must be an existing directory.
+// Root mounts directly; other users need fusermount3 or fusermount on the
+// PATH. Close unmounts it.
+func MountFUSE(r *RAIDArray, dir string) (*FUSEMount, error) {
+	dev, err := os.OpenFile("/dev/fuse", os.O_RDWR, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open /dev/fuse: %w", err)
+	}
+
+	m := &FUSEMount{r: r, dir: dir, stats: make(map[uint64][]byte), done: make(chan struct{})}
+	opts := fmt.Sprintf("fd=%d,rootmode=40000,user_id=%d,group_id=%d", dev.Fd(), os.Getuid(), os.Getgid())
+	err = syscall.Mount("raid", dir, "fuse.raid", syscall.MS_NOSUID|syscall.MS_NODEV, opts)
+	if errors.Is(err, syscall.EPERM) {
+		dev.Close()
+		dev, m.fusermount, err = fusermountFD(dir)
+	}
+	if err != nil {
+		if dev != nil {
+			dev.Close()
+		}
+		return nil, fmt.Errorf("failed to mount %s: %w", dir, err)
+	}
+	m.dev = dev
+
+	go m.serve()
+	m.disablePoll()
+	return m, nil
+}
+
+// disablePoll polls array.img once on a private epoll instance, so the
+// kernel learns from the ENOSYS answer that the files are not pollable.
+// Otherwise an os.Open of the file in this process would send FUSE_POLL
+// from the runtime's epoll registration, which does not release its P; with
+// GOMAXPROCS=1 the request could then never be served.
+func (m *FUSEMount) disablePoll() {
+	fd, err := syscall.Open(m.dir+"/array.img", syscall.O_RDONLY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return
+	}
+	defer syscall.Close(fd)
+	ep, err := syscall.EpollCreate1(syscall.EPOLL_CLOEXEC)
+	if err != nil {
+		return
+	}
+	defer syscall.Close(ep)
+
+	// syscall.EpollCtl is a raw syscall, so it is made directly here to
+	// let serve run while the kernel waits for the answer
+	ev := syscall.EpollEvent{Events: syscall.EPOLLIN, Fd: int32(fd)}
+	syscall.Syscall6(syscall.SYS_EPOLL_CTL, uintptr(ep), syscall.EPOLL_CTL_ADD, uintptr(fd), uintptr(unsafe.Pointer(&ev)), 0, 0)
+}
+
+// fusermountFD has the setuid fusermount helper mount dir and hand back the
+// /dev/fuse descriptor over a socket.
+func fusermountFD(dir string) (*os.File, string, error) {
+	var helper string
+	for _, name := range []string{"fusermount3", "fusermount"} {
+		if path, err := exec.LookPath(name); err == nil {
+			helper = path
+			break
+		}
+	}
+	if helper == "" {
+		return nil, "", fmt.Errorf("not permitted to mount and no fusermount found")
+	}
+
+	fds, err := syscall.Socketpair(syscall.AF_UNIX, syscall.SOCK_STREAM, 0)
+	if err != nil {
+		return nil, "", err
+	}
+	local := os.NewFile(uintptr(fds[0]), "fusermount")
+	remote := os.NewFile(uintptr(fds[1]), "fusermount")
+	defer local.Close()
+
+	cmd := exec.Command(helper, "-o", "fsname=raid,subtype=raid", "--", dir)
+	cmd.ExtraFiles = []*os.File{remote}
+	cmd.Env = append(os.Environ(), "_FUSE_COMMFD=3")
+	out, err := cmd.CombinedOutput()
+	remote.Close()
+	if err != nil {
+		return nil, "", fmt.Errorf("%s: %w: %s", helper, err, out)
+	}
+
+	buf := make([]byte, 1)
+	oob := make([]byte, syscall.CmsgSpace(4))
+	_, oobn, _, _, err := syscall.Recvmsg(int(local.Fd()), buf, oob, 0)
+	if err != nil {
+		return nil, "", err
+	}
+	msgs, err := syscall.ParseSocketControlMessage(oob[:oobn])
+	if err != nil || len(msgs) == 0 {
+		return nil, "", fmt.Errorf("%s did not pass a descriptor", helper)
+	}
+	rights, err := syscall.ParseUnixRights(&msgs[0])
+	if err != nil || len(rights) == 0 {
+		return nil, "", fmt.Errorf("%s did not pass a descriptor", helper)
+	}
+	return os.NewFile(uintptr(rights[0]), "/dev/fuse"), helper, nil
+}
+
+// Close unmounts the array and waits for outstanding requests to finish.
+func (m *FUSEMount) Close() error {
+	var err error
+	if m.fusermount != "" {
+		if out, runErr := exec.Command(m.fusermount, "-u", m.dir).CombinedOutput(); runErr != nil {
+			err = fmt.Errorf("%s -u: %w: %s", m.fusermount, runErr, out)
+		}
+	} else {
+		err = syscall.Unmount(m.dir, 0)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to unmount %s: %w", m.dir, err)
+	}
+	<-m.done
+	m.dev.Close()
+	return m.err
+}
+
+func (m *FUSEMount) serve() {
+	defer close(m.done)
+	buf := make([]byte, fuseBufferSize)
+	for {
+		n, err := syscall.Read(int(m.dev.Fd()), buf)
+		switch {
+		case err == syscall.EINTR || err == syscall.ENOENT || err == syscall.EAGAIN:
+			continue // interrupted, or the request was withdrawn
+		case err == syscall.ENODEV:
+			return // unmounted
+		case err != nil:
+			m.err = err
+			return
+		case n < fuseInHeaderSize:
+			m.err = fmt.Errorf("short FUSE request of %d bytes", n)
+			return
+		}
+
+		ne := binary.NativeEndian
+		opcode := ne.Uint32(buf[4:8])
+		unique := ne.Uint64(buf[8:16])
+		node := ne.Uint64(buf[16:24])
+		in := buf[fuseInHeaderSize:n]
+
+		switch opcode {
+		case fuseForget, fuseBatchForget, fuseInterrupt:
+			continue // no reply expected
+		}
+
+		out, errno := m.handle(opcode, node, in)
+		m.reply(unique, out, errno)
+		if opcode == fuseDestroy {
+			return
+		}
+	}
+}
+
+func (m *FUSEMount) reply(unique uint64, out []byte, errno syscall.Errno) {
+	msg := make([]byte, fuseOutHeaderSize, fuseOutHeaderSize+len(out))
+	ne := binary.NativeEndian
+	if errno == 0 {
+		msg = append(msg, out...)
+	}
+	ne.PutUint32(msg[0:4], uint32(len(msg)))
+	ne.PutUint32(msg[4:8], uint32(-int32(errno)))
+	ne.PutUint64(msg[8:16], unique)
+	syscall.Write(int(m.dev.Fd()), msg) // ENOENT here means the request was interrupted
+}
+
+func (m *FUSEMount) handle(opcode uint32, node uint64, in []byte) ([]byte, syscall.Errno) {
+	ne := binary.NativeEndian
+	switch opcode {
+	case fuseInit:
+		if len(in) < 8 || ne.Uint32(in[0:4]) < fuseKernelMajor {
+			return nil, syscall.EPROTO
+		}
+		out := make([]byte, 64)
+		ne.PutUint32(out[0:4], fuseKernelMajor)
+		ne.PutUint32(out[4:8], min(ne.Uint32(in[4:8]), fuseKernelMinor))
+		ne.PutUint32(out[8:12], 128<<10) // max_readahead
+		ne.PutUint16(out[16:18], 16)     // max_background
+		ne.PutUint16(out[18:20], 12)     // congestion_threshold
+		ne.PutUint32(out[20:24], fuseMaxWrite)
+		ne.PutUint32(out[24:28], 1) // time_gran
+		return out, 0
+
+	case fuseDestroy, fuseAccess, fuseFlush, fuseRelease, fuseReleasedir, fuseFsyncdir:
+		if opcode == fuseRelease && len(in) >= 8 {
+			m.mu.Lock()
+			delete(m.stats, ne.Uint64(in[0:8]))
+			m.mu.Unlock()
+		}
+		return nil, 0
+
+	case fuseLookup:
+		if node != fuseRootIno {
+			return nil, syscall.ENOENT
+		}
+		name := string(in)
+		if i := len(name) - 1; i >= 0 && name[i] == 0 {
+			name = name[:i]
+		}
+		ino, ok := fuseNames[name]
+		if !ok {
+			return nil, syscall.ENOENT
+		}
+		out := make([]byte, 40+fuseAttrSize)
+		ne.PutUint64(out[0:8], ino)
+		ne.PutUint64(out[16:24], 1) // entry_valid, seconds
+		ne.PutUint64(out[24:32], 1) // attr_valid
+		m.attr(out[40:], ino)
+		return out, 0
+
+	case fuseGetattr, fuseSetattr:
+		// the size of array.img is fixed, so truncation is ignored
+		if node != fuseRootIno && node != fuseImageIno && node != fuseStatsIno {
+			return nil, syscall.ENOENT
+		}
+		out := make([]byte, 16+fuseAttrSize)
+		ne.PutUint64(out[0:8], 1) // attr_valid
+		m.attr(out[16:], node)
+		return out, 0
+
+	case fuseOpen:
+		out := make([]byte, 16)
+		ne.PutUint32(out[8:12], fuseOpenDirectIO)
+		switch node {
+		case fuseImageIno:
+		case fuseStatsIno:
+			m.mu.Lock()
+			m.fh++
+			m.stats[m.fh] = m.renderStats()
+			ne.PutUint64(out[0:8], m.fh)
+			m.mu.Unlock()
+		default:
+			return nil, syscall.EISDIR
+		}
+		return out, 0
+
+	case fuseOpendir:
+		if node != fuseRootIno {
+			return nil, syscall.ENOTDIR
+		}
+		return make([]byte, 16), 0
+
+	case fuseRead:
+		if len(in) < 24 {
+			return nil, syscall.EINVAL
+		}
+		fh, off, size := ne.Uint64(in[0:8]), int64(ne.Uint64(in[8:16])), int64(ne.Uint32(in[16:20]))
+		switch node {
+		case fuseImageIno:
+			if off >= m.r.Size() {
+				return nil, 0
+			}
+			buf := make([]byte, min(size, m.r.Size()-off))
+			if _, err := m.r.ReadAt(buf, off); err != nil {
+				m.r.log.Error("fuse read failed", "offset", off, "err", err)
+				return nil, syscall.EIO
+			}
+			return buf, 0
+		case fuseStatsIno:
+			m.mu.Lock()
+			data := m.stats[fh]
+			m.mu.Unlock()
+			if off >= int64(len(data)) {
+				return nil, 0
+			}
+			return data[off:min(off+size, int64(len(data)))], 0
+		}
+		return nil, syscall.EISDIR
+
+	case fuseWrite:
+		if len(in) < 40 {
+			return nil, syscall.EINVAL
+		}
+		if node != fuseImageIno {
+			return nil, syscall.EACCES
+		}
+		off, size := int64(ne.Uint64(in[8:16])), int(ne.Uint32(in[16:20]))
+		data := in[40:]
+		if len(data) < size {
+			return nil, syscall.EINVAL
+		}
+		if off+int64(size) > m.r.Size() {
+			return nil, syscall.ENOSPC
+		}
+		if _, err := m.r.WriteAt(data[:size], off); err != nil {
+			m.r.log.Error("fuse write failed", "offset", off, "err", err)
+			if errors.Is(err, ErrNoSpace) {
+				return nil, syscall.ENOSPC
+			}
+			return nil, syscall.EIO
+		}
+		out := make([]byte, 8)
+		ne.PutUint32(out[0:4], uint32(size))
+		return out, 0
+
+	case fuseFsync:
+		if err := m.r.Flush(); err != nil {
+			return nil, syscall.EIO
+		}
+		return nil, 0
+
+	case fuseReaddir:
+		if len(in) < 24 {
+			return nil, syscall.EINVAL
+		}
+		return m.readdir(int(ne.Uint64(in[8:16])), int(ne.Uint32(in[16:20]))), 0
+
+	case fuseStatfs:
+		out := make([]byte, 80)
+		blocks := uint64(m.r.capacity)
+		ne.PutUint64(out[0:8], blocks)
+		ne.PutUint32(out[40:44], uint32(m.r.blockSize)) // bsize
+		ne.PutUint32(out[44:48], 255)                   // namelen
+		ne.PutUint32(out[48:52], uint32(m.r.blockSize)) // frsize
+		ne.PutUint64(out[24:32], uint64(len(fuseNames)))
+		return out, 0
+	}
+	return nil, syscall.ENOSYS
+}
+
+// attr fills a fuse_attr for one of the three inodes.
+func (m *FUSEMount) attr(out []byte, ino uint64) {
+	ne := binary.NativeEndian
+	var size uint64
+	mode := uint32(syscall.S_IFREG | 0644)
+	nlink := uint32(1)
+	switch ino {
+	case fuseRootIno:
+		mode, nlink = syscall.S_IFDIR|0755, 2
+	case fuseImageIno:
+		size = uint64(m.r.Size())
+	case fuseStatsIno:
+		mode = syscall.S_IFREG | 0444
+		size = uint64(len(m.renderStats()))
+	}
+	ne.PutUint64(out[0:8], ino)
+	ne.PutUint64(out[8:16], size)
+	ne.PutUint64(out[16:24], (size+511)/512)
+	ne.PutUint32(out[60:64], mode)
+	ne.PutUint32(out[64:68], nlink)
+	ne.PutUint32(out[68:72], uint32(os.Getuid()))
+	ne.PutUint32(out[72:76], uint32(os.Getgid()))
+	ne.PutUint32(out[80:84], uint32(m.r.blockSize))
+}
+
+// readdir lists the root directory from entry start, fitting what it can in
+// size bytes.
+func (m *FUSEMount) readdir(start, size int) []byte {
+	entries := []struct {
+		name string
+		ino  uint64
+		typ  uint32
+	}{
+		{".", fuseRootIno, syscall.DT_DIR},
+		{"..", fuseRootIno, syscall.DT_DIR},
+		{"array.img", fuseImageIno, syscall.DT_REG},
+		{"stats.json", fuseStatsIno, syscall.DT_REG},
+	}
+
+	ne := binary.NativeEndian
+	var out []byte
+	for i := start; i < len(entries); i++ {
+		e := entries[i]
+		rec := (24 + len(e.name) + 7) &^ 7
+		if len(out)+rec > size {
+			break
+		}
+		ent := make([]byte, rec)
+		ne.PutUint64(ent[0:8], e.ino)
+		ne.PutUint64(ent[8:16], uint64(i+1)) // offset of the next entry
+		ne.PutUint32(ent[16:20], uint32(len(e.name)))
+		ne.PutUint32(ent[20:24], e.typ)
+		copy(ent[24:], e.name)
+		out = append(out, ent...)
+	}
+	return out
+}
+
+func (m *FUSEMount) renderStats() []byte {
+	data, err := json.MarshalIndent(m.r.Snapshot(), "", "  ")
+	if err != nil {
+		return []byte(strconv.Quote(err.Error()))
+	}
+	return append(data, '\n')
+}
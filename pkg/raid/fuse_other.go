@@ -0,0 +1,13 @@
+//go:build !linux
+
+package raid
+
+import "errors"
+
+type FUSEMount struct{}
+
+func MountFUSE(r *RAIDArray, dir string) (*FUSEMount, error) {
+	return nil, errors.New("FUSE mounts are only supported on linux")
+}
+
+func (m *FUSEMount) Close() error { return nil }
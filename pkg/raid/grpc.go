@@ -0,0 +1,326 @@
+package raid
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// gRPC status codes used by the control API.
+const (
+	grpcOK                 = 0
+	grpcCanceled           = 1
+	grpcInvalidArgument    = 3
+	grpcFailedPrecondition = 9
+	grpcUnimplemented      = 12
+	grpcInternal           = 13
+	grpcUnavailable        = 14
+)
+
+const grpcService = "/raid.v1.RAIDControl/"
+
+// maxGRPCRequest bounds request messages, which are all a few bytes.
+const maxGRPCRequest = 1 << 16
+
+type grpcError struct {
+	code int
+	err  error
+}
+
+func (e *grpcError) Error() string { return e.err.Error() }
+func (e *grpcError) Unwrap() error { return e.err }
+
+func grpcErrorf(code int, format string, args ...any) error {
+	return &grpcError{code, fmt.Errorf(format, args...)}
+}
+
+// grpcStream is the response side of a call.
+type grpcStream struct {
+	w       http.ResponseWriter
+	started bool
+}
+
+// ready sends the response headers, telling the client the call is under
+// way.
+func (s *grpcStream) ready() {
+	if s.started {
+		return
+	}
+	s.started = true
+	s.w.Header().Set("Content-Type", "application/grpc")
+	s.w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+	s.w.WriteHeader(http.StatusOK)
+	if f, ok := s.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// send writes a response message: once for unary calls, once per update
+// for streaming ones.
+func (s *grpcStream) send(msg []byte) error {
+	s.ready()
+	frame := make([]byte, 5, 5+len(msg))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(msg)))
+	if _, err := s.w.Write(append(frame, msg...)); err != nil {
+		return err
+	}
+	if f, ok := s.w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return nil
+}
+
+// grpcMethod handles one call, given its request message.
+type grpcMethod func(ctx context.Context, req []pbField, s *grpcStream) error
+
+// GRPCHandler serves the array's control API as the gRPC service
+// raid.v1.RAIDControl, described in proto/raid/v1/raid.proto, with
+// server-streaming calls for rebuild and scrub progress and for events.
+// gRPC runs over HTTP/2: serve the handler over TLS, or enable unencrypted
+// HTTP/2 in the server's Protocols. Like Handler, rebuilds and scrubs it
+// starts are cancelled when the array closes.
+func (r *RAIDArray) GRPCHandler() http.Handler {
+	methods := map[string]grpcMethod{
+		"GetStatus": func(ctx context.Context, req []pbField, s *grpcStream) error {
+			return s.send(r.pbStatus())
+		},
+		"FailDisk": func(ctx context.Context, req []pbField, s *grpcStream) error {
+			disk, err := r.pbDisk(req)
+			if err != nil {
+				return err
+			}
+			if err := r.FailDisk(disk); err != nil {
+				return &grpcError{grpcFailedPrecondition, err}
+			}
+			return s.send(r.pbStatus())
+		},
+		"RebuildDisk": func(ctx context.Context, req []pbField, s *grpcStream) error {
+			disk, err := r.pbDisk(req)
+			if err != nil {
+				return err
+			}
+			switch {
+			case r.level != RAID5:
+				return grpcErrorf(grpcFailedPrecondition, "disk rebuild only supported for RAID 5")
+			case !r.disks[disk].IsFailed():
+				return grpcErrorf(grpcFailedPrecondition, "disk %d is not marked as failed", disk)
+			}
+			r.background(func(ctx context.Context) error {
+				return r.RebuildDisk(ctx, disk)
+			})
+			return s.send(r.pbStatus())
+		},
+		"StartScrub": func(ctx context.Context, req []pbField, s *grpcStream) error {
+			if r.ScrubProgress().Running {
+				return grpcErrorf(grpcFailedPrecondition, "a scrub is already running")
+			}
+			repair := false
+			for _, f := range req {
+				if f.num == 1 && f.wire == wireVarint {
+					repair = f.v != 0
+				}
+			}
+			r.background(func(ctx context.Context) error {
+				_, err := r.Scrub(ctx, ScrubOptions{Repair: repair})
+				return err
+			})
+			return s.send(pbScrubProgress(r.ScrubProgress()))
+		},
+		"ResetStats": func(ctx context.Context, req []pbField, s *grpcStream) error {
+			r.ResetStats()
+			return s.send(nil)
+		},
+		"WatchProgress": r.watchProgress,
+		"WatchEvents":   r.watchEvents,
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost || req.ProtoMajor != 2 || !strings.HasPrefix(req.Header.Get("Content-Type"), "application/grpc") {
+			http.Error(w, "gRPC requires POST over HTTP/2 with content type application/grpc", http.StatusUnsupportedMediaType)
+			return
+		}
+
+		s := &grpcStream{w: w}
+		err := grpcErrorf(grpcUnimplemented, "unknown method %s", req.URL.Path)
+		if method, ok := methods[strings.TrimPrefix(req.URL.Path, grpcService)]; ok && strings.HasPrefix(req.URL.Path, grpcService) {
+			var fields []pbField
+			if fields, err = readGRPCRequest(req.Body); err == nil {
+				err = method(req.Context(), fields, s)
+			}
+		}
+
+		s.ready()
+		code := grpcOK
+		var ge *grpcError
+		switch {
+		case err == nil:
+		case errors.As(err, &ge):
+			code = ge.code
+		case errors.Is(err, context.Canceled):
+			code = grpcCanceled
+		default:
+			code = grpcInternal
+		}
+		w.Header().Set("Grpc-Status", strconv.Itoa(code))
+		if err != nil {
+			w.Header().Set("Grpc-Message", url.PathEscape(err.Error()))
+		}
+	})
+}
+
+// readGRPCRequest reads the single length-prefixed message of a call.
+func readGRPCRequest(body io.Reader) ([]pbField, error) {
+	var prefix [5]byte
+	if _, err := io.ReadFull(body, prefix[:]); err != nil {
+		return nil, grpcErrorf(grpcInvalidArgument, "missing request message: %v", err)
+	}
+	if prefix[0] != 0 {
+		return nil, grpcErrorf(grpcUnimplemented, "compressed messages are not supported")
+	}
+	size := binary.BigEndian.Uint32(prefix[1:])
+	if size > maxGRPCRequest {
+		return nil, grpcErrorf(grpcInvalidArgument, "request message of %d bytes is too large", size)
+	}
+	msg := make([]byte, size)
+	if _, err := io.ReadFull(body, msg); err != nil {
+		return nil, grpcErrorf(grpcInvalidArgument, "short request message: %v", err)
+	}
+	fields, err := pbDecode(msg)
+	if err != nil {
+		return nil, &grpcError{grpcInvalidArgument, err}
+	}
+	return fields, nil
+}
+
+// pbDisk returns the disk of a DiskRequest.
+func (r *RAIDArray) pbDisk(req []pbField) (int, error) {
+	disk := 0
+	for _, f := range req {
+		if f.num == 1 && f.wire == wireVarint {
+			disk = int(int32(f.v))
+		}
+	}
+	if disk < 0 || disk >= r.numDisks {
+		return 0, grpcErrorf(grpcInvalidArgument, "no disk %d", disk)
+	}
+	return disk, nil
+}
+
+// watchProgress streams Progress messages, as GET /progress does.
+func (r *RAIDArray) watchProgress(ctx context.Context, req []pbField, s *grpcStream) error {
+	interval := time.Second
+	for _, f := range req {
+		if f.num == 1 && f.wire == wireVarint {
+			interval = time.Duration(f.v)
+		}
+	}
+	if interval <= 0 {
+		return grpcErrorf(grpcInvalidArgument, "bad interval %v", interval)
+	}
+
+	ticker := r.clock.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		rep := r.Health()
+		var w pbWriter
+		w.int(1, rep.Time.UnixNano())
+		if rep.Rebuild != nil {
+			w.message(2, pbRebuildProgress(*rep.Rebuild))
+		}
+		w.message(3, pbScrubProgress(rep.Scrub))
+		if err := s.send(w.b); err != nil {
+			return err
+		}
+		if rep.Rebuild == nil && !rep.Scrub.Running {
+			return nil
+		}
+
+		select {
+		case <-ticker.C():
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-r.done:
+			return grpcErrorf(grpcUnavailable, "array closed")
+		}
+	}
+}
+
+// watchEvents streams Event messages until the client goes away or the
+// array closes.
+func (r *RAIDArray) watchEvents(ctx context.Context, req []pbField, s *grpcStream) error {
+	events, cancel := r.Subscribe(0)
+	defer cancel()
+	s.ready() // the client may rely on the subscription once it has the headers
+	for {
+		select {
+		case e, ok := <-events:
+			if !ok {
+				return grpcErrorf(grpcUnavailable, "array closed")
+			}
+			var w pbWriter
+			w.string(1, e.Type.String())
+			w.int(2, e.Time.UnixNano())
+			w.int(3, int64(e.Disk))
+			w.int(4, int64(e.Stripe))
+			w.string(5, e.Detail)
+			if e.Err != nil {
+				w.string(6, e.Err.Error())
+			}
+			if err := s.send(w.b); err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (r *RAIDArray) pbStatus() []byte {
+	st := r.State()
+	var w pbWriter
+	w.int(1, int64(r.level))
+	w.int(2, int64(r.capacity))
+	w.int(3, int64(r.blockSize))
+	w.string(4, st.State.String())
+	for i, role := range st.Roles {
+		var d pbWriter
+		d.int(1, int64(i))
+		d.string(2, r.disks[i].path)
+		d.string(3, role.String())
+		w.message(5, d.b)
+	}
+	if st.Rebuild != nil {
+		w.message(6, pbRebuildProgress(*st.Rebuild))
+	}
+	return w.b
+}
+
+func pbRebuildProgress(p RebuildProgress) []byte {
+	var w pbWriter
+	w.int(1, int64(p.Disk))
+	w.int(2, int64(p.Stripe))
+	w.int(3, int64(p.TotalStripes))
+	w.int(4, int64(p.Remaining))
+	if !p.StartedAt.IsZero() {
+		w.int(5, p.StartedAt.UnixNano())
+	}
+	w.double(6, p.BlocksPerSec)
+	w.int(7, int64(p.ETA))
+	return w.b
+}
+
+func pbScrubProgress(p ScrubProgress) []byte {
+	var w pbWriter
+	w.int(1, int64(p.Stripe))
+	w.int(2, int64(p.TotalStripes))
+	w.int(3, int64(p.Mismatches))
+	w.bool(4, p.Running)
+	return w.b
+}
@@ -0,0 +1,108 @@
+package raid
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+var (
+	_ io.ReadWriteSeeker = (*Handle)(nil)
+	_ io.Closer          = (*Handle)(nil)
+)
+
+// Handle is a file-like view of the logical address space with its own
+// offset. Handles are independent of each other and safe for concurrent use;
+// closing one does not close the array.
+type Handle struct {
+	array  *RAIDArray
+	mu     sync.Mutex
+	off    int64
+	closed bool
+}
+
+// Open returns a Handle positioned at the start of the array.
+func (r *RAIDArray) Open() *Handle {
+	return &Handle{array: r}
+}
+
+func (h *Handle) Read(p []byte) (int, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return 0, os.ErrClosed
+	}
+	if h.off >= h.array.Size() {
+		return 0, io.EOF
+	}
+
+	n, err := h.array.ReadAt(p, h.off)
+	h.off += int64(n)
+	if err == io.EOF && n > 0 {
+		err = nil // reported by the next Read
+	}
+	return n, err
+}
+
+// Write writes at the current offset. Writes that do not fit are cut at the
+// end of the array and fail with io.ErrShortWrite.
+func (h *Handle) Write(p []byte) (int, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return 0, os.ErrClosed
+	}
+
+	var short bool
+	if rest := h.array.Size() - h.off; int64(len(p)) > rest {
+		p, short = p[:max(rest, 0)], true
+	}
+
+	n, err := h.array.WriteAt(p, h.off)
+	h.off += int64(n)
+	if err == nil && short {
+		err = fmt.Errorf("write reached the end of the array: %w", io.ErrShortWrite)
+	}
+	return n, err
+}
+
+// Seek sets the offset for the next Read or Write. Seeking past the end is
+// allowed; reads there return io.EOF and writes fail.
+func (h *Handle) Seek(offset int64, whence int) (int64, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return 0, os.ErrClosed
+	}
+
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += h.off
+	case io.SeekEnd:
+		offset += h.array.Size()
+	default:
+		return 0, fmt.Errorf("invalid whence %d", whence)
+	}
+	if offset < 0 {
+		return 0, fmt.Errorf("negative position %d", offset)
+	}
+	h.off = offset
+	return offset, nil
+}
+
+// Close releases the handle. The array stays open.
+func (h *Handle) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return os.ErrClosed
+	}
+	h.closed = true
+	return nil
+}
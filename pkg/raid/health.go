@@ -0,0 +1,319 @@
+package raid
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+type HealthStatus int
+
+const (
+	HealthPass HealthStatus = iota
+	HealthWarn
+	HealthFail
+)
+
+func (s HealthStatus) String() string {
+	switch s {
+	case HealthWarn:
+		return "warn"
+	case HealthFail:
+		return "fail"
+	default:
+		return "pass"
+	}
+}
+
+func (s HealthStatus) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// HealthState is the view of the array that health rules are evaluated
+// against.
+type HealthState struct {
+	Time          time.Time
+	Level         RAIDLevel
+	Disks         int
+	FailedDisks   []int
+	DegradedSince time.Time // earliest failure among the failed members, zero when none
+	WritesPaused  bool
+	Probe         *ProbeResult     // nil when no startup probe ran
+	Rebuild       *RebuildProgress // nil when no rebuild is running
+	Scrub         ScrubProgress
+	Predictions   []FailurePrediction // active members likely to fail soon
+}
+
+// HealthRule inspects the state and returns HealthPass, or another status
+// with a reason.
+type HealthRule func(HealthState) (HealthStatus, string)
+
+// HealthPolicy is a set of rules. The worst status among them wins.
+type HealthPolicy []HealthRule
+
+type HealthVerdict struct {
+	Status  HealthStatus `json:"status"`
+	Reasons []string     `json:"reasons"`
+}
+
+// Evaluate runs every rule of the policy against the current state.
+func (r *RAIDArray) Evaluate(policy HealthPolicy) HealthVerdict {
+	state := r.healthState()
+	verdict := HealthVerdict{Reasons: []string{}}
+	for _, rule := range policy {
+		status, reason := rule(state)
+		if status == HealthPass {
+			continue
+		}
+		verdict.Reasons = append(verdict.Reasons, fmt.Sprintf("%s: %s", status, reason))
+		verdict.Status = max(verdict.Status, status)
+	}
+	return verdict
+}
+
+func (r *RAIDArray) healthState() HealthState {
+	s := HealthState{
+		Time:         r.clock.Now(),
+		Level:        r.level,
+		Disks:        r.numDisks,
+		FailedDisks:  []int{},
+		WritesPaused: r.WritesPaused(),
+		Probe:        r.ProbeResult(),
+		Scrub:        r.ScrubProgress(),
+		Predictions:  r.PredictFailures(),
+	}
+	if r.raid5 != nil {
+		s.Rebuild = r.raid5.progress()
+	}
+	for i, disk := range r.disks {
+		failed, since := disk.failedSince()
+		if !failed {
+			continue
+		}
+		s.FailedDisks = append(s.FailedDisks, i)
+		if s.DegradedSince.IsZero() || since.Before(s.DegradedSince) {
+			s.DegradedSince = since
+		}
+	}
+	return s
+}
+
+// FailedDisksAtLeast reports status once n or more members have failed.
+func FailedDisksAtLeast(n int, status HealthStatus) HealthRule {
+	return func(s HealthState) (HealthStatus, string) {
+		if len(s.FailedDisks) < n {
+			return HealthPass, ""
+		}
+		return status, fmt.Sprintf("%d of %d disks failed %v", len(s.FailedDisks), s.Disks, s.FailedDisks)
+	}
+}
+
+// DegradedLongerThan reports status once a member has been failed for
+// longer than d.
+func DegradedLongerThan(d time.Duration, status HealthStatus) HealthRule {
+	return func(s HealthState) (HealthStatus, string) {
+		if s.DegradedSince.IsZero() {
+			return HealthPass, ""
+		}
+		if age := s.Time.Sub(s.DegradedSince); age > d {
+			return status, fmt.Sprintf("degraded for %v (limit %v)", age.Round(time.Second), d)
+		}
+		return HealthPass, ""
+	}
+}
+
+// RebuildETALongerThan reports status while a running rebuild is expected
+// to take longer than d to finish. A rebuild without an estimate yet
+// passes.
+func RebuildETALongerThan(d time.Duration, status HealthStatus) HealthRule {
+	return func(s HealthState) (HealthStatus, string) {
+		if s.Rebuild == nil || s.Rebuild.ETA <= d {
+			return HealthPass, ""
+		}
+		return status, fmt.Sprintf("rebuild of disk %d has ETA %v (limit %v)", s.Rebuild.Disk, s.Rebuild.ETA.Round(time.Second), d)
+	}
+}
+
+// WhenWritesPaused reports status while writes are suspended for lack of
+// space.
+func WhenWritesPaused(status HealthStatus) HealthRule {
+	return func(s HealthState) (HealthStatus, string) {
+		if !s.WritesPaused {
+			return HealthPass, ""
+		}
+		return status, "writes paused: backing storage full"
+	}
+}
+
+// ProbeConfidenceBelow reports status when the startup probe ran and ended
+// below the given confidence.
+func ProbeConfidenceBelow(c Confidence, status HealthStatus) HealthRule {
+	return func(s HealthState) (HealthStatus, string) {
+		if s.Probe == nil || s.Probe.Confidence >= c {
+			return HealthPass, ""
+		}
+		return status, fmt.Sprintf("startup probe confidence %s (want %s)", s.Probe.Confidence, c)
+	}
+}
+
+// AllOf reports status only when every given rule reports a problem, e.g.
+// to fail an array that is both degraded and out of space.
+func AllOf(status HealthStatus, rules ...HealthRule) HealthRule {
+	return func(s HealthState) (HealthStatus, string) {
+		reasons := make([]string, 0, len(rules))
+		for _, rule := range rules {
+			st, reason := rule(s)
+			if st == HealthPass {
+				return HealthPass, ""
+			}
+			reasons = append(reasons, reason)
+		}
+		return status, strings.Join(reasons, " and ")
+	}
+}
+
+// HealthReport is a snapshot of the array for dashboards and alerting.
+type HealthReport struct {
+	Time              time.Time        `json:"time"`
+	Status            HealthStatus     `json:"status"`
+	Reasons           []string         `json:"reasons"`
+	State             State            `json:"state"`
+	Level             RAIDLevel        `json:"level"`
+	RemainingFailures int              `json:"remaining_failures"` // members that can still fail without losing data
+	Disks             []DiskHealth     `json:"disks"`
+	Rebuild           *RebuildProgress `json:"rebuild,omitempty"` // nil when no rebuild is running
+	Scrub             ScrubProgress    `json:"scrub"`
+	Probe             *ProbeResult     `json:"probe,omitempty"`
+}
+
+type DiskHealth struct {
+	Path           string          `json:"path"`
+	Role           DiskRole        `json:"role"`
+	FailedSince    time.Time       `json:"failed_since,omitzero"`
+	ReadErrors     uint64          `json:"read_errors"`
+	WriteErrors    uint64          `json:"write_errors"`
+	Timeouts       uint64          `json:"timeouts"` // transfers that missed the I/O timeout, also counted as errors
+	RemappedBlocks int             `json:"remapped_blocks"`
+	LastError      string          `json:"last_error,omitempty"`
+	LastErrorTime  time.Time       `json:"last_error_time,omitzero"`
+	Latency        time.Duration   `json:"latency_ns"` // moving average of transfer times
+	Slow           bool            `json:"slow,omitempty"`
+	WriteMostly    bool            `json:"write_mostly,omitempty"`
+	SMART          SMARTAttributes `json:"smart"`
+}
+
+type RebuildProgress struct {
+	Disk         int           `json:"disk"`
+	Stripe       int           `json:"stripe"` // stripes below this one are rebuilt
+	TotalStripes int           `json:"total_stripes"`
+	Remaining    int           `json:"remaining_stripes"`
+	StartedAt    time.Time     `json:"started_at"`     // when this run started, after any resumed part
+	BlocksPerSec float64       `json:"blocks_per_sec"` // average over this run
+	ETA          time.Duration `json:"eta_ns"`         // 0 until a stripe is rebuilt
+}
+
+// rebuildProgressInterval is how often a running rebuild logs its progress
+// and emits EventRebuildProgress.
+const rebuildProgressInterval = 10 * time.Second
+
+func (p RebuildProgress) String() string {
+	s := fmt.Sprintf("stripe %d of %d", p.Stripe, p.TotalStripes)
+	if p.BlocksPerSec > 0 {
+		prec := 0
+		if p.BlocksPerSec < 10 {
+			prec = 1
+		}
+		s += fmt.Sprintf(", %.*f blocks/s, ETA %v", prec, p.BlocksPerSec, p.ETA.Round(time.Second))
+	}
+	return s
+}
+
+// progress reports the running rebuild, nil when there is none.
+func (r *raid5Impl) progress() *RebuildProgress {
+	disk := int(r.rebuild.disk.Load())
+	if disk < 0 {
+		return nil
+	}
+	p := &RebuildProgress{
+		Disk:         disk,
+		Stripe:       int(r.rebuild.next.Load()),
+		TotalStripes: r.array.disks[disk].Capacity(),
+		StartedAt:    time.Unix(0, r.rebuild.started.Load()),
+	}
+	p.Remaining = p.TotalStripes - p.Stripe
+	done := p.Stripe - int(r.rebuild.from.Load())
+	if elapsed := r.array.clock.Now().Sub(p.StartedAt); done > 0 && elapsed > 0 {
+		p.BlocksPerSec = float64(done) / elapsed.Seconds()
+		p.ETA = time.Duration(float64(p.Remaining) / p.BlocksPerSec * float64(time.Second))
+	}
+	return p
+}
+
+// Health builds a report of the array and each member. The status fails
+// once data is lost and warns while the array is degraded, rebuilding,
+// out of space, or a member has reported I/O errors or is likely to fail.
+func (r *RAIDArray) Health() HealthReport {
+	state := r.State()
+	rep := HealthReport{
+		Time:  r.clock.Now(),
+		State: state.State,
+		Level: r.level,
+		Disks: make([]DiskHealth, r.numDisks),
+		Scrub: r.ScrubProgress(),
+		Probe: r.ProbeResult(),
+	}
+
+	verdict := r.Evaluate(HealthPolicy{
+		FailedDisksAtLeast(1, HealthWarn),
+		FailedDisksAtLeast(r.tolerated()+1, HealthFail),
+		WhenWritesPaused(HealthWarn),
+		ProbeConfidenceBelow(ConfidenceMedium, HealthWarn),
+		WhenFailurePredicted(HealthWarn),
+	})
+	rep.Status, rep.Reasons = verdict.Status, verdict.Reasons
+
+	warn := func(format string, args ...any) {
+		rep.Status = max(rep.Status, HealthWarn)
+		rep.Reasons = append(rep.Reasons, fmt.Sprintf("%s: %s", HealthWarn, fmt.Sprintf(format, args...)))
+	}
+
+	failed := 0
+	for i, disk := range r.disks {
+		stats := disk.GetStats()
+		h := DiskHealth{
+			Path:           stats.Path,
+			Role:           state.Roles[i],
+			ReadErrors:     disk.metrics.readErrors.lifetime(),
+			WriteErrors:    disk.metrics.writeErrors.lifetime(),
+			Timeouts:       disk.timeouts.Load(),
+			RemappedBlocks: stats.RemappedBlocks,
+			Latency:        time.Duration(disk.opLatency.Load()),
+			Slow:           disk.slow.Load(),
+			WriteMostly:    disk.writeMostly.Load(),
+			SMART:          disk.SMART(),
+		}
+		if isFailed, since := disk.failedSince(); isFailed {
+			h.FailedSince = since
+		}
+		if h.Role != RoleActive {
+			failed++ // a member being rebuilt covers no failure yet
+		}
+		if e := disk.lastErr.Load(); e != nil {
+			h.LastError, h.LastErrorTime = e.msg, e.at
+		}
+		if n := h.ReadErrors + h.WriteErrors; n > 0 && h.Role == RoleActive {
+			warn("disk %d reported %d I/O errors", i, n)
+		}
+		if h.Slow && h.Role != RoleFailed {
+			warn("disk %d is slow, averaging %v per transfer", i, h.Latency)
+		}
+		rep.Disks[i] = h
+	}
+	rep.RemainingFailures = max(r.tolerated()-failed, 0)
+
+	if p := state.Rebuild; p != nil {
+		rep.Rebuild = p
+		warn("rebuilding disk %d, %s", p.Disk, p)
+	}
+	return rep
+}
@@ -0,0 +1,143 @@
+package raid
+
+import (
+	"math/bits"
+	"sync/atomic"
+	"time"
+)
+
+// Latency histogram buckets double in width: bucket 0 holds transfers up
+// to histogramBase, bucket i those up to histogramBase<<i, and the last one
+// everything slower.
+const (
+	histogramBase    = time.Microsecond
+	histogramBuckets = 26 // the last bound is about 16s
+)
+
+// LatencyBucketBound is the longest transfer counted in bucket i of a
+// LatencyHistogram. The last bucket has no bound.
+func LatencyBucketBound(i int) time.Duration {
+	return histogramBase << i
+}
+
+// LatencyHistogram is the distribution of a disk's transfer times.
+type LatencyHistogram struct {
+	Count   uint64        `json:"count"`
+	Sum     time.Duration `json:"sum_ns"`
+	Max     time.Duration `json:"max_ns"`
+	Buckets []uint64      `json:"buckets"` // transfers per bucket, see LatencyBucketBound
+}
+
+// Percentile returns the transfer time below which fraction p of the
+// transfers completed, to the resolution of the buckets.
+func (h LatencyHistogram) Percentile(p float64) time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+	rank := uint64(p * float64(h.Count))
+	var seen uint64
+	for i, n := range h.Buckets {
+		seen += n
+		if seen > rank && i < len(h.Buckets)-1 {
+			return min(LatencyBucketBound(i), h.Max)
+		}
+	}
+	return h.Max
+}
+
+// Mean returns the average transfer time.
+func (h LatencyHistogram) Mean() time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+	return h.Sum / time.Duration(h.Count)
+}
+
+// Summary returns the histogram's percentiles. Min is the bound of the
+// fastest bucket in use.
+func (h LatencyHistogram) Summary() LatencySummary {
+	s := LatencySummary{P50: h.Percentile(0.50), P90: h.Percentile(0.90), P99: h.Percentile(0.99), Max: h.Max}
+	for i, n := range h.Buckets {
+		if n > 0 {
+			s.Min = min(LatencyBucketBound(i), h.Max)
+			break
+		}
+	}
+	return s
+}
+
+func (h *LatencyHistogram) merge(o LatencyHistogram) {
+	if h.Buckets == nil {
+		h.Buckets = make([]uint64, histogramBuckets)
+	}
+	h.Count += o.Count
+	h.Sum += o.Sum
+	h.Max = max(h.Max, o.Max)
+	for i, n := range o.Buckets {
+		h.Buckets[i] += n
+	}
+}
+
+// since returns the transfers recorded after prev was taken, or all of
+// them if the counters were reset since. Max is left as it is, the longest
+// transfer since the last reset.
+func (h LatencyHistogram) since(prev LatencyHistogram) LatencyHistogram {
+	if h.Count < prev.Count || h.Sum < prev.Sum {
+		prev = LatencyHistogram{}
+	}
+	d := LatencyHistogram{Sum: h.Sum - prev.Sum, Max: h.Max, Buckets: make([]uint64, len(h.Buckets))}
+	for i, n := range h.Buckets {
+		if i < len(prev.Buckets) {
+			n = since(n, prev.Buckets[i])
+		}
+		d.Buckets[i] = n
+		d.Count += n
+	}
+	return d
+}
+
+// latencyHistogram records transfer times without locking.
+type latencyHistogram struct {
+	count   atomic.Uint64
+	sum     atomic.Int64
+	max     atomic.Int64
+	buckets [histogramBuckets]atomic.Uint64
+}
+
+func (h *latencyHistogram) record(sample time.Duration) {
+	i := 0
+	if sample > histogramBase {
+		i = min(bits.Len64(uint64((sample-1)/histogramBase)), histogramBuckets-1)
+	}
+	h.buckets[i].Add(1)
+	h.count.Add(1)
+	h.sum.Add(int64(sample))
+	for {
+		old := h.max.Load()
+		if int64(sample) <= old || h.max.CompareAndSwap(old, int64(sample)) {
+			break
+		}
+	}
+}
+
+func (h *latencyHistogram) reset() {
+	h.count.Store(0)
+	h.sum.Store(0)
+	h.max.Store(0)
+	for i := range h.buckets {
+		h.buckets[i].Store(0)
+	}
+}
+
+func (h *latencyHistogram) snapshot() LatencyHistogram {
+	s := LatencyHistogram{
+		Count:   h.count.Load(),
+		Sum:     time.Duration(h.sum.Load()),
+		Max:     time.Duration(h.max.Load()),
+		Buckets: make([]uint64, histogramBuckets),
+	}
+	for i := range h.buckets {
+		s.Buckets[i] = h.buckets[i].Load()
+	}
+	return s
+}
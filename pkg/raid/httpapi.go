@@ -0,0 +1,234 @@
+package raid
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Handler serves the array over HTTP for monitoring and management:
+//
+//	GET  /status               level, capacity, state and member roles
+//	GET  /stats                a StatsSnapshot
+//	GET  /stats?interval=1s    a StatsDelta over the interval
+//	POST /stats/reset          zero the counters
+//	GET  /health               the HealthReport, with status 503 once it fails
+//	POST /disks/{disk}/fail    mark a member failed
+//	POST /disks/{disk}/rebuild start rebuilding a failed member
+//	POST /scrub?repair=true    start a scrub, repairing if asked
+//	GET  /progress?interval=1s stream rebuild and scrub progress
+//	GET  /events               the event log, 404 when none is configured
+//
+// Rebuilds and scrubs run in the background and answer 202 Accepted; their
+// progress shows in /health and /progress. They are cancelled when the
+// array closes.
+func (r *RAIDArray) Handler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /status", func(w http.ResponseWriter, req *http.Request) {
+		writeJSON(w, http.StatusOK, struct {
+			Level     RAIDLevel `json:"level"`
+			Capacity  int       `json:"capacity"`
+			BlockSize int       `json:"block_size"`
+			ArrayState
+		}{r.level, r.capacity, r.blockSize, r.State()})
+	})
+
+	mux.HandleFunc("GET /stats", func(w http.ResponseWriter, req *http.Request) {
+		s := req.URL.Query().Get("interval")
+		if s == "" {
+			writeJSON(w, http.StatusOK, r.Snapshot())
+			return
+		}
+		interval, err := time.ParseDuration(s)
+		if err != nil || interval <= 0 {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("bad interval %q", s))
+			return
+		}
+		start := r.Snapshot()
+		ticker := r.clock.NewTicker(interval)
+		defer ticker.Stop()
+		select {
+		case <-ticker.C():
+		case <-req.Context().Done():
+			return
+		case <-r.done:
+			writeError(w, http.StatusServiceUnavailable, fmt.Errorf("array closed"))
+			return
+		}
+		writeJSON(w, http.StatusOK, r.Snapshot().Since(start))
+	})
+
+	mux.HandleFunc("POST /stats/reset", func(w http.ResponseWriter, req *http.Request) {
+		r.ResetStats()
+		writeJSON(w, http.StatusOK, r.Snapshot())
+	})
+
+	mux.HandleFunc("GET /health", func(w http.ResponseWriter, req *http.Request) {
+		rep := r.Health()
+		code := http.StatusOK
+		if rep.Status == HealthFail {
+			code = http.StatusServiceUnavailable
+		}
+		writeJSON(w, code, rep)
+	})
+
+	mux.HandleFunc("POST /disks/{disk}/fail", func(w http.ResponseWriter, req *http.Request) {
+		disk, ok := r.diskParam(w, req)
+		if !ok {
+			return
+		}
+		r.FailDisk(disk)
+		writeJSON(w, http.StatusOK, r.State())
+	})
+
+	mux.HandleFunc("POST /disks/{disk}/rebuild", func(w http.ResponseWriter, req *http.Request) {
+		disk, ok := r.diskParam(w, req)
+		if !ok {
+			return
+		}
+		switch {
+		case r.level != RAID5:
+			writeError(w, http.StatusBadRequest, fmt.Errorf("disk rebuild only supported for RAID 5"))
+			return
+		case !r.disks[disk].IsFailed():
+			writeError(w, http.StatusConflict, fmt.Errorf("disk %d is not marked as failed", disk))
+			return
+		}
+		r.background(func(ctx context.Context) error {
+			return r.RebuildDisk(ctx, disk)
+		})
+		writeJSON(w, http.StatusAccepted, r.State())
+	})
+
+	mux.HandleFunc("POST /scrub", func(w http.ResponseWriter, req *http.Request) {
+		if r.ScrubProgress().Running {
+			writeError(w, http.StatusConflict, fmt.Errorf("a scrub is already running"))
+			return
+		}
+		repair, _ := strconv.ParseBool(req.URL.Query().Get("repair"))
+		r.background(func(ctx context.Context) error {
+			_, err := r.Scrub(ctx, ScrubOptions{Repair: repair})
+			return err
+		})
+		writeJSON(w, http.StatusAccepted, r.ScrubProgress())
+	})
+
+	mux.HandleFunc("GET /progress", r.serveProgress)
+
+	mux.HandleFunc("GET /events", func(w http.ResponseWriter, req *http.Request) {
+		if r.config.EventLog == "" {
+			writeError(w, http.StatusNotFound, fmt.Errorf("no event log configured"))
+			return
+		}
+		events, err := r.EventLog()
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, events)
+	})
+
+	return mux
+}
+
+// Progress is one update streamed by GET /progress.
+type Progress struct {
+	Time    time.Time        `json:"time"`
+	Rebuild *RebuildProgress `json:"rebuild,omitempty"` // nil when no rebuild is running
+	Scrub   ScrubProgress    `json:"scrub"`
+}
+
+// serveProgress writes a Progress line every interval while a rebuild or
+// scrub runs, and a last one once neither does.
+func (r *RAIDArray) serveProgress(w http.ResponseWriter, req *http.Request) {
+	interval := time.Second
+	if s := req.URL.Query().Get("interval"); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil || d <= 0 {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("bad interval %q", s))
+			return
+		}
+		interval = d
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	flusher, _ := w.(http.Flusher)
+	enc := json.NewEncoder(w)
+	ticker := r.clock.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		rep := r.Health()
+		p := Progress{Time: rep.Time, Rebuild: rep.Rebuild, Scrub: rep.Scrub}
+		if err := enc.Encode(p); err != nil {
+			return
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		if p.Rebuild == nil && !p.Scrub.Running {
+			return
+		}
+
+		select {
+		case <-ticker.C():
+		case <-req.Context().Done():
+			return
+		case <-r.done:
+			return
+		}
+	}
+}
+
+// background runs fn until it returns or the array closes. Close waits for
+// it to stop.
+func (r *RAIDArray) background(fn func(ctx context.Context) error) {
+	select {
+	case <-r.done:
+		return
+	default:
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r.async.Add(1)
+	go func() {
+		defer r.async.Done()
+		select {
+		case <-r.done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	r.async.Add(1)
+	go func() {
+		defer r.async.Done()
+		defer cancel()
+		if err := fn(ctx); err != nil && ctx.Err() == nil {
+			r.log.Error("background task failed", "err", err)
+		}
+	}()
+}
+
+func (r *RAIDArray) diskParam(w http.ResponseWriter, req *http.Request) (int, bool) {
+	disk, err := strconv.Atoi(req.PathValue("disk"))
+	if err != nil || disk < 0 || disk >= r.numDisks {
+		writeError(w, http.StatusNotFound, fmt.Errorf("no disk %q", req.PathValue("disk")))
+		return 0, false
+	}
+	return disk, true
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,78 @@
+package raid
+
+import "bytes"
+
+// BlockInspection shows where a logical block lives and what each member
+// holds on disk in its stripe.
+type BlockInspection struct {
+	LogicalBlock int              `json:"logical_block"`
+	Stripe       int              `json:"stripe"`      // physical block on every member
+	DataDisks    []int            `json:"data_disks"`  // the member holding the block, or every mirror
+	ParityDisk   int              `json:"parity_disk"` // -1 outside RAID 5
+	Consistent   bool             `json:"consistent"`  // parity or mirrors agree; false if a member could not be read
+	Members      []MemberContents `json:"members"`
+}
+
+type MemberContents struct {
+	Disk         int         `json:"disk"`
+	Holds        MemberBlock `json:"holds"`
+	BackendBlock int         `json:"backend_block"` // location in the image, after bad block remapping
+	Data         []byte      `json:"data,omitempty"`
+	Error        string      `json:"error,omitempty"`
+}
+
+// Inspect reads the stripe holding a logical block straight from the
+// members, bypassing the caches, for forensics when data mismatches.
+func (r *RAIDArray) Inspect(logicalBlockID int) (*BlockInspection, error) {
+	if logicalBlockID < 0 || logicalBlockID >= r.capacity {
+		return nil, outOfRange("block ID", logicalBlockID, r.capacity)
+	}
+	stripe := logicalBlockID / r.BlocksPerStripe()
+	layout, err := r.Layout(stripe, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	in := &BlockInspection{
+		LogicalBlock: logicalBlockID,
+		Stripe:       stripe,
+		ParityDisk:   -1,
+		Consistent:   true,
+		Members:      make([]MemberContents, r.numDisks),
+	}
+	parity := make([]byte, r.blockSize)
+	var mirror []byte
+	for i, disk := range r.disks {
+		m := MemberContents{Disk: i, Holds: layout[0][i]}
+		switch {
+		case m.Holds.Parity:
+			in.ParityDisk = i
+		case m.Holds.LogicalBlock == logicalBlockID:
+			in.DataDisks = append(in.DataDisks, i)
+		}
+
+		disk.mu.RLock()
+		m.BackendBlock = disk.physical(stripe)
+		disk.mu.RUnlock()
+
+		data, err := disk.ReadBlock(stripe)
+		if err != nil {
+			m.Error = err.Error()
+			in.Consistent = false
+		} else {
+			m.Data = data
+			xorBytes(parity, data)
+			if r.level == RAID1 {
+				if mirror != nil && !bytes.Equal(data, mirror) {
+					in.Consistent = false
+				}
+				mirror = data
+			}
+		}
+		in.Members[i] = m
+	}
+	if r.level == RAID5 && in.Consistent {
+		in.Consistent = bytes.Equal(parity, make([]byte, r.blockSize))
+	}
+	return in, nil
+}
@@ -0,0 +1,96 @@
+package raid
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// LatencyModel delays a member's transfers the way a real drive would, so
+// the effect of slow members on each RAID level can be shown. A transfer
+// to the block after the previous one is sequential and pays only Base and
+// Jitter. The zero value adds no delay.
+type LatencyModel struct {
+	Base     time.Duration // fixed cost of every transfer
+	Seek     time.Duration // full-stroke seek; shorter seeks take the square root of the distance's share of it
+	Rotation time.Duration // one revolution; random transfers wait a uniformly drawn part of it
+	Jitter   time.Duration // mean of an exponentially distributed extra delay
+}
+
+var (
+	// HDDLatency is a 7200 RPM hard disk.
+	HDDLatency = LatencyModel{Base: 100 * time.Microsecond, Seek: 15 * time.Millisecond, Rotation: 8333 * time.Microsecond}
+	// SSDLatency is a SATA solid state drive.
+	SSDLatency = LatencyModel{Base: 80 * time.Microsecond, Jitter: 20 * time.Microsecond}
+)
+
+var latencyModels = map[string]LatencyModel{
+	"none": {},
+	"ssd":  SSDLatency,
+	"hdd":  HDDLatency,
+}
+
+// ParseLatencyModel returns the model named "none", "ssd" or "hdd".
+func ParseLatencyModel(name string) (LatencyModel, error) {
+	m, ok := latencyModels[name]
+	if !ok {
+		return LatencyModel{}, fmt.Errorf("unknown latency model %q", name)
+	}
+	return m, nil
+}
+
+func (m LatencyModel) validate() error {
+	if m.Base < 0 || m.Seek < 0 || m.Rotation < 0 || m.Jitter < 0 {
+		return fmt.Errorf("invalid latency model %+v", m)
+	}
+	return nil
+}
+
+type latencyState struct {
+	LatencyModel
+	mu   sync.Mutex
+	head int // block after the last transfer
+	rand *lockedRand
+}
+
+func (d *Disk) setLatencyModel(m LatencyModel, rnd *lockedRand) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.latency = nil
+	if m != (LatencyModel{}) {
+		d.latency = &latencyState{LatencyModel: m, rand: rnd}
+	}
+}
+
+// SetLatencyModel makes a member's transfers as slow as m describes; the
+// zero LatencyModel removes the delay.
+func (r *RAIDArray) SetLatencyModel(diskIndex int, m LatencyModel) error {
+	if diskIndex < 0 || diskIndex >= r.numDisks {
+		return fmt.Errorf("invalid disk index %d", diskIndex)
+	}
+	if err := m.validate(); err != nil {
+		return err
+	}
+	r.disks[diskIndex].setLatencyModel(m, r.rand)
+	return nil
+}
+
+// delay returns how long a transfer of blockID takes on a disk of
+// numBlocks blocks, and moves the head past it.
+func (l *latencyState) delay(blockID, numBlocks int) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	t := l.Base
+	if blockID != l.head {
+		dist := math.Abs(float64(blockID-l.head)) / float64(max(numBlocks, 1))
+		t += time.Duration(math.Sqrt(min(dist, 1)) * float64(l.Seek))
+		t += time.Duration(l.rand.Float64() * float64(l.Rotation))
+	}
+	if l.Jitter > 0 {
+		t += time.Duration(l.rand.ExpFloat64() * float64(l.Jitter))
+	}
+	l.head = blockID + 1
+	return t
+}
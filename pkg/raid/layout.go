@@ -0,0 +1,95 @@
+package raid
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// StripeLayout returns what each member holds in stripes [first,
+// first+count) of an array with the given level, width and placement,
+// indexed by stripe and then disk. DiskIndex, BlockSize and BlocksPerDisk
+// are ignored.
+func StripeLayout(geo MemberGeometry, first, count int) ([][]MemberBlock, error) {
+	switch {
+	case geo.Level != RAID0 && geo.Level != RAID1 && geo.Level != RAID5:
+		return nil, fmt.Errorf("unsupported RAID level: %d", geo.Level)
+	case geo.Level == RAID5 && geo.NumDisks < 3:
+		return nil, fmt.Errorf("RAID 5 requires at least 3 disks")
+	case geo.NumDisks < 2:
+		return nil, fmt.Errorf("RAID requires at least 2 disks")
+	case first < 0 || count < 0:
+		return nil, fmt.Errorf("invalid stripe range %d+%d", first, count)
+	}
+	if geo.Placement == nil {
+		geo.Placement = rotatingParity{}
+	}
+	if geo.Level == RAID5 {
+		for stripe := first; stripe < first+count; stripe++ {
+			if d := geo.Placement.ParityDisk(stripe, geo.NumDisks); d < 0 || d >= geo.NumDisks {
+				return nil, fmt.Errorf("placement policy put parity for stripe %d on invalid disk %d", stripe, d)
+			}
+		}
+	}
+
+	layout := make([][]MemberBlock, count)
+	for i := range layout {
+		layout[i] = make([]MemberBlock, geo.NumDisks)
+		for disk := range layout[i] {
+			geo.DiskIndex = disk
+			layout[i][disk] = geo.locate(first + i)
+		}
+	}
+	return layout, nil
+}
+
+// Layout is StripeLayout for this array's geometry, clipped to its stripes.
+func (r *RAIDArray) Layout(first, count int) ([][]MemberBlock, error) {
+	geo := MemberGeometry{Level: r.level, NumDisks: r.numDisks}
+	if r.raid5 != nil {
+		geo.Placement = r.raid5.placement
+	}
+	stripes := r.capacity / r.BlocksPerStripe()
+	first = min(max(first, 0), stripes)
+	return StripeLayout(geo, first, min(count, stripes-first))
+}
+
+// WriteLayout renders a layout as a table with a row per stripe and a
+// column per disk. Data blocks show as D followed by their logical block,
+// parity as P.
+func WriteLayout(w io.Writer, layout [][]MemberBlock) error {
+	if len(layout) == 0 {
+		return nil
+	}
+	bw := bufio.NewWriter(w)
+
+	width := len("disk") + len(fmt.Sprint(len(layout[0])-1))
+	for _, row := range layout {
+		for _, b := range row {
+			width = max(width, len(layoutCell(b)))
+		}
+	}
+
+	fmt.Fprintf(bw, "%6s", "stripe")
+	for disk := range layout[0] {
+		fmt.Fprintf(bw, "  %-*s", width, fmt.Sprintf("disk%d", disk))
+	}
+	fmt.Fprintln(bw)
+	for _, row := range layout {
+		fmt.Fprintf(bw, "%6d", row[0].PhysicalBlock)
+		cells := make([]string, len(row))
+		for disk, b := range row {
+			cells[disk] = fmt.Sprintf("%-*s", width, layoutCell(b))
+		}
+		fmt.Fprintln(bw, strings.TrimRight("  "+strings.Join(cells, "  "), " "))
+	}
+	return bw.Flush()
+}
+
+func layoutCell(b MemberBlock) string {
+	if b.Parity {
+		return "P"
+	}
+	return fmt.Sprintf("D%d", b.LogicalBlock)
+}
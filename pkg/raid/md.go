@@ -0,0 +1,291 @@
+package raid
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Linux md version 1.2 metadata, as in linux/raid/md_p.h.
+const (
+	mdMagic          = 0xa92b4efc
+	mdSuperOffset    = 4096 // v1.2 superblocks sit 4 KiB into the device
+	mdSuperSize      = 256  // fixed part, followed by a 16-bit role per device
+	mdMaxDevs        = 1920
+	mdFeatureRecover = 1 << 1 // recovery_offset is valid: the member is partly rebuilt
+	mdSector         = 512
+
+	MDRoleSpare  = 0xffff
+	MDRoleFaulty = 0xfffe
+)
+
+// MDSuperblock is the metadata mdadm writes to each member of an array
+// created with --metadata=1.2. Sizes and offsets are in bytes.
+type MDSuperblock struct {
+	ArrayUUID      string    `json:"array_uuid"`
+	Name           string    `json:"name"`
+	Created        time.Time `json:"created"`
+	Updated        time.Time `json:"updated"`
+	Level          int       `json:"level"` // md personality: 0, 1, 4, 5, 6, 10, or -1 for linear
+	Layout         int       `json:"layout"`
+	ChunkSize      int64     `json:"chunk_size"`
+	RaidDisks      int       `json:"raid_disks"`
+	ComponentSize  int64     `json:"component_size"` // bytes of each member used by the array
+	DataOffset     int64     `json:"data_offset"`    // where the member's data starts
+	DataSize       int64     `json:"data_size"`
+	DevNumber      int       `json:"dev_number"`
+	DeviceUUID     string    `json:"device_uuid"`
+	Events         uint64    `json:"events"`
+	Recovering     bool      `json:"recovering"` // the member is in sync only below RecoveryOffset
+	RecoveryOffset int64     `json:"recovery_offset"`
+	Roles          []int     `json:"roles"` // slot of each device number, or MDRoleSpare / MDRoleFaulty
+}
+
+// Role is this member's slot in the array, or MDRoleSpare / MDRoleFaulty.
+func (sb *MDSuperblock) Role() int {
+	if sb.DevNumber < len(sb.Roles) {
+		return sb.Roles[sb.DevNumber]
+	}
+	return MDRoleSpare
+}
+
+// ReadMDSuperblock reads the md v1.2 superblock of a device or image.
+func ReadMDSuperblock(path string) (*MDSuperblock, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	buf := make([]byte, mdSuperSize+2*mdMaxDevs)
+	n, err := file.ReadAt(buf, mdSuperOffset)
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("failed to read superblock of %s: %w", path, err)
+	}
+	sb, err := parseMDSuperblock(buf[:n])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return sb, nil
+}
+
+func parseMDSuperblock(buf []byte) (*MDSuperblock, error) {
+	le := binary.LittleEndian
+	if len(buf) < mdSuperSize || le.Uint32(buf[0:4]) != mdMagic {
+		return nil, fmt.Errorf("no md superblock")
+	}
+	if v := le.Uint32(buf[4:8]); v != 1 {
+		return nil, fmt.Errorf("unsupported md superblock version %d", v)
+	}
+	maxDev := int(le.Uint32(buf[220:224]))
+	if maxDev > mdMaxDevs || mdSuperSize+2*maxDev > len(buf) {
+		return nil, fmt.Errorf("md superblock lists %d devices", maxDev)
+	}
+	buf = buf[:mdSuperSize+2*maxDev]
+	if sum, want := mdChecksum(buf), le.Uint32(buf[216:220]); sum != want {
+		return nil, fmt.Errorf("md superblock checksum %#x, expected %#x", sum, want)
+	}
+
+	sectors := func(off int) int64 { return int64(le.Uint64(buf[off:off+8])) * mdSector }
+	sb := &MDSuperblock{
+		ArrayUUID:      mdUUID(buf[16:32]),
+		Name:           strings.TrimRight(string(buf[32:64]), "\x00"),
+		Created:        mdTime(le.Uint64(buf[64:72])),
+		Level:          int(int32(le.Uint32(buf[72:76]))),
+		Layout:         int(le.Uint32(buf[76:80])),
+		ComponentSize:  sectors(80),
+		ChunkSize:      int64(le.Uint32(buf[88:92])) * mdSector,
+		RaidDisks:      int(le.Uint32(buf[92:96])),
+		DataOffset:     sectors(128),
+		DataSize:       sectors(136),
+		Recovering:     le.Uint32(buf[8:12])&mdFeatureRecover != 0,
+		RecoveryOffset: sectors(152),
+		DevNumber:      int(le.Uint32(buf[160:164])),
+		DeviceUUID:     mdUUID(buf[168:184]),
+		Updated:        mdTime(le.Uint64(buf[192:200])),
+		Events:         le.Uint64(buf[200:208]),
+		Roles:          make([]int, maxDev),
+	}
+	for i := range sb.Roles {
+		sb.Roles[i] = int(le.Uint16(buf[mdSuperSize+2*i:]))
+	}
+	return sb, nil
+}
+
+// mdChecksum is the kernel's calc_sb_1_csum: a 64-bit sum of the
+// little-endian words of the superblock with its checksum field zeroed,
+// folded to 32 bits.
+func mdChecksum(buf []byte) uint32 {
+	le := binary.LittleEndian
+	var sum uint64
+	for i := 0; i+4 <= len(buf); i += 4 {
+		if i != 216 {
+			sum += uint64(le.Uint32(buf[i:]))
+		}
+	}
+	if len(buf)%4 == 2 {
+		sum += uint64(le.Uint16(buf[len(buf)-2:]))
+	}
+	return uint32(sum&0xffffffff + sum>>32)
+}
+
+func mdUUID(b []byte) string {
+	h := hex.EncodeToString(b)
+	return h[0:8] + ":" + h[8:16] + ":" + h[16:24] + ":" + h[24:32]
+}
+
+// mdTime decodes md's timestamps: seconds in the low 40 bits and
+// microseconds above them.
+func mdTime(v uint64) time.Time {
+	return time.Unix(int64(v&(1<<40-1)), int64(v>>40)*1000)
+}
+
+// MDArray is a read-only view of a RAID 0 or RAID 1 array created by mdadm,
+// assembled from its members' v1.2 superblocks.
+type MDArray struct {
+	sb      *MDSuperblock // from the most recently updated member
+	members []*os.File    // by slot; nil where a RAID 1 mirror is missing
+	offsets []int64       // data offset of each member
+	size    int64
+}
+
+// OpenMDArray assembles the md array whose members are at paths. Members
+// with a different array UUID are rejected; stale, faulty, spare and
+// partly recovered members are left out, which RAID 1 tolerates as long as
+// one mirror remains. RAID 0 members must all be the same size.
+func OpenMDArray(paths ...string) (*MDArray, error) {
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no members given")
+	}
+	sbs := make([]*MDSuperblock, len(paths))
+	var newest *MDSuperblock
+	for i, path := range paths {
+		sb, err := ReadMDSuperblock(path)
+		if err != nil {
+			return nil, err
+		}
+		if newest != nil && sb.ArrayUUID != newest.ArrayUUID {
+			return nil, fmt.Errorf("%s belongs to array %s, not %s", path, sb.ArrayUUID, newest.ArrayUUID)
+		}
+		if newest == nil || sb.Events > newest.Events {
+			newest = sb
+		}
+		sbs[i] = sb
+	}
+	if newest.Level != 0 && newest.Level != 1 {
+		return nil, fmt.Errorf("md level %d arrays are not supported, only 0 and 1", newest.Level)
+	}
+	if newest.RaidDisks < 1 || newest.RaidDisks > mdMaxDevs {
+		return nil, fmt.Errorf("md superblock claims %d members", newest.RaidDisks)
+	}
+
+	a := &MDArray{
+		sb:      newest,
+		members: make([]*os.File, newest.RaidDisks),
+		offsets: make([]int64, newest.RaidDisks),
+	}
+	sizes := make([]int64, newest.RaidDisks)
+	for i, sb := range sbs {
+		role := sb.Role()
+		if role >= newest.RaidDisks || sb.Events < newest.Events || sb.Recovering || a.members[role] != nil {
+			continue
+		}
+		file, err := os.Open(paths[i])
+		if err != nil {
+			a.Close()
+			return nil, err
+		}
+		a.members[role], a.offsets[role], sizes[role] = file, sb.DataOffset, sb.DataSize
+	}
+
+	switch newest.Level {
+	case 0:
+		if newest.ChunkSize <= 0 {
+			a.Close()
+			return nil, fmt.Errorf("RAID 0 chunk size is %d", newest.ChunkSize)
+		}
+		var size int64
+		for slot, file := range a.members {
+			if file == nil {
+				a.Close()
+				return nil, fmt.Errorf("RAID 0 member %d is missing", slot)
+			}
+			s := sizes[slot] / newest.ChunkSize * newest.ChunkSize
+			if slot > 0 && s != size {
+				a.Close()
+				return nil, fmt.Errorf("RAID 0 members differ in size, which is not supported")
+			}
+			size = s
+		}
+		a.size = size * int64(newest.RaidDisks)
+	case 1:
+		if !slices.ContainsFunc(a.members, func(f *os.File) bool { return f != nil }) {
+			a.Close()
+			return nil, fmt.Errorf("no in-sync RAID 1 member")
+		}
+		a.size = newest.ComponentSize
+	}
+	return a, nil
+}
+
+// Superblock returns the superblock the array was assembled from.
+func (a *MDArray) Superblock() *MDSuperblock {
+	return a.sb
+}
+
+// Size is the array's capacity in bytes.
+func (a *MDArray) Size() int64 {
+	return a.size
+}
+
+// ReadAt reads from the array's address space. RAID 1 reads are served by
+// the first in-sync mirror.
+func (a *MDArray) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, fmt.Errorf("negative offset %d", off)
+	}
+	n := 0
+	for n < len(p) {
+		pos := off + int64(n)
+		if pos >= a.size {
+			return n, io.EOF
+		}
+		slot, memberOff, length := a.locate(pos)
+		length = min(length, int64(len(p)-n), a.size-pos)
+		if _, err := a.members[slot].ReadAt(p[n:n+int(length)], a.offsets[slot]+memberOff); err != nil {
+			return n, fmt.Errorf("md member %d: %w", slot, err)
+		}
+		n += int(length)
+	}
+	return n, nil
+}
+
+// locate maps an array offset to a member slot and offset within its data,
+// and how many bytes from there are contiguous on that member.
+func (a *MDArray) locate(pos int64) (slot int, memberOff, length int64) {
+	if a.sb.Level == 1 {
+		slot = slices.IndexFunc(a.members, func(f *os.File) bool { return f != nil })
+		return slot, pos, a.size - pos
+	}
+	chunk := a.sb.ChunkSize
+	n := int64(a.sb.RaidDisks)
+	c := pos / chunk
+	return int(c % n), c/n*chunk + pos%chunk, chunk - pos%chunk
+}
+
+func (a *MDArray) Close() error {
+	var firstErr error
+	for _, f := range a.members {
+		if f != nil {
+			if err := f.Close(); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
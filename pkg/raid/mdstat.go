@@ -0,0 +1,77 @@
+package raid
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// WriteMDStat renders arrays in the layout of Linux's /proc/mdstat, naming
+// them md0, md1 and so on in order. Sizes are in 1 KiB blocks as md prints
+// them; a running rebuild shows as recovery and a running scrub as check.
+func WriteMDStat(w io.Writer, arrays ...*RAIDArray) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "Personalities : [raid0] [raid1] [raid5]")
+	for i, r := range arrays {
+		r.writeMDStat(bw, fmt.Sprintf("md%d", i))
+	}
+	fmt.Fprintln(bw, "unused devices: <none>")
+	return bw.Flush()
+}
+
+func (r *RAIDArray) writeMDStat(w io.Writer, name string) {
+	rep := r.Health()
+
+	status := "active"
+	if rep.State == StateReadOnly {
+		status = "active (read-only)"
+	}
+	members := make([]string, r.numDisks)
+	health := make([]byte, r.numDisks)
+	inSync := 0
+	for i, d := range rep.Disks {
+		members[i] = fmt.Sprintf("%s[%d]", filepath.Base(d.Path), i)
+		health[i] = '_'
+		switch d.Role {
+		case RoleActive:
+			health[i] = 'U'
+			inSync++
+		case RoleFailed:
+			members[i] += "(F)"
+		}
+	}
+	fmt.Fprintf(w, "%s : %s raid%d %s\n", name, status, r.level, strings.Join(members, " "))
+
+	chunk := max(r.blockSize/1024, 1)
+	switch r.level {
+	case RAID0:
+		fmt.Fprintf(w, "      %d blocks %dk chunks\n", r.Size()/1024, chunk)
+	case RAID1:
+		fmt.Fprintf(w, "      %d blocks [%d/%d] [%s]\n", r.Size()/1024, r.numDisks, inSync, health)
+	case RAID5:
+		fmt.Fprintf(w, "      %d blocks level 5, %dk chunk [%d/%d] [%s]\n", r.Size()/1024, chunk, r.numDisks, inSync, health)
+	}
+
+	switch {
+	case rep.Rebuild != nil:
+		r.writeMDStatProgress(w, "recovery", rep.Rebuild.Stripe, rep.Rebuild.TotalStripes)
+	case rep.Scrub.Running:
+		r.writeMDStatProgress(w, "check", rep.Scrub.Stripe, rep.Scrub.TotalStripes)
+	}
+	fmt.Fprintln(w)
+}
+
+// writeMDStatProgress draws md's resync bar for done of total stripes.
+func (r *RAIDArray) writeMDStatProgress(w io.Writer, action string, done, total int) {
+	permille := 0
+	if total > 0 {
+		permille = done * 1000 / total
+	}
+	filled := permille / 50
+	kib := func(stripes int) int64 { return int64(stripes) * int64(r.blockSize) / 1024 }
+	fmt.Fprintf(w, "      [%s>%s]  %s = %2d.%d%% (%d/%d)\n",
+		strings.Repeat("=", filled), strings.Repeat(".", 20-filled),
+		action, permille/10, permille%10, kib(done), kib(total))
+}
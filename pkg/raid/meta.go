@@ -0,0 +1,256 @@
+package raid
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"time"
+)
+
+const metaVersion = 1
+
+// ArrayMeta is the portable description of an array: enough to reassemble
+// it on another host from the member images alone.
+type ArrayMeta struct {
+	Version       int           `json:"version"`
+	Level         RAIDLevel     `json:"level"`
+	BlockSize     int           `json:"block_size"`
+	BlocksPerDisk int           `json:"blocks_per_disk"`
+	Members       []MemberMeta  `json:"members"`
+	Placement     PlacementMeta `json:"placement"`
+
+	VerifyReads     bool           `json:"verify_reads,omitempty"`
+	ReadPolicy      ReadPolicy     `json:"read_policy"`
+	PreferredDisk   int            `json:"preferred_disk"`
+	RemapReserve    int            `json:"remap_reserve"`
+	Prealloc        PreallocMode   `json:"prealloc"`
+	ReadCacheBlocks int            `json:"read_cache_blocks,omitempty"`
+	StripeCacheSize int            `json:"stripe_cache_size,omitempty"`
+	ProbePercent    float64        `json:"probe_percent,omitempty"`
+	FillWarn        float64        `json:"fill_warn,omitempty"`
+	FillCritical    float64        `json:"fill_critical,omitempty"`
+	IOUring         bool           `json:"io_uring,omitempty"`
+	DirectIO        bool           `json:"direct_io,omitempty"`
+	Mmap            bool           `json:"mmap,omitempty"`
+	SyncPolicy      SyncPolicy     `json:"sync_policy"`
+	SyncInterval    time.Duration  `json:"sync_interval,omitempty"`
+	SlowOpThreshold time.Duration  `json:"slow_op_threshold,omitempty"`
+	SlowDisk        SlowDiskPolicy `json:"slow_disk,omitzero"`
+	IOTimeout       time.Duration  `json:"io_timeout,omitempty"`
+	IOTimeoutLimit  int            `json:"io_timeout_limit,omitempty"`
+	Retry           RetryPolicy    `json:"retry,omitzero"`
+	EventLog        string         `json:"event_log,omitempty"`
+	ReadRecovery    []ReadRecovery `json:"read_recovery"`
+	RebuildRate     int64          `json:"rebuild_rate,omitempty"`
+	Volumes         []VolumeMeta   `json:"volumes,omitempty"`
+}
+
+type MemberMeta struct {
+	Path      string              `json:"path"`
+	Failed    bool                `json:"failed"`
+	BadBlocks map[int]*remapEntry `json:"bad_blocks,omitempty"`
+
+	RebuildCheckpoint *int `json:"rebuild_checkpoint,omitempty"` // block an interrupted rebuild resumes at
+}
+
+// PlacementMeta records RAID 5 parity placement. Policies other than the
+// built-in ones are exported as an explicit per-stripe table.
+type PlacementMeta struct {
+	Policy string `json:"policy"`          // "rotating", "restricted" or "table"
+	Disks  []int  `json:"disks,omitempty"` // restricted: parity members; table: parity member per stripe
+}
+
+// ExportMeta serializes the array definition, member state and bad block
+// tables as JSON.
+func (r *RAIDArray) ExportMeta() ([]byte, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	cfg := r.config
+	meta := ArrayMeta{
+		Version:         metaVersion,
+		Level:           r.level,
+		BlockSize:       r.blockSize,
+		BlocksPerDisk:   cfg.BlocksPerDisk,
+		Members:         make([]MemberMeta, len(r.disks)),
+		Placement:       PlacementMeta{Policy: "rotating"},
+		VerifyReads:     cfg.VerifyReads,
+		ReadPolicy:      cfg.ReadPolicy,
+		PreferredDisk:   cfg.PreferredDisk,
+		RemapReserve:    cfg.RemapReserve,
+		Prealloc:        cfg.Prealloc,
+		ReadCacheBlocks: cfg.ReadCacheBlocks,
+		StripeCacheSize: cfg.StripeCacheSize,
+		ProbePercent:    cfg.ProbePercent,
+		FillWarn:        cfg.FillWarn,
+		FillCritical:    cfg.FillCritical,
+		IOUring:         cfg.IOUring,
+		DirectIO:        cfg.DirectIO,
+		Mmap:            cfg.Mmap,
+		SyncPolicy:      cfg.SyncPolicy,
+		SyncInterval:    cfg.SyncInterval,
+		SlowOpThreshold: cfg.SlowOpThreshold,
+		SlowDisk:        cfg.SlowDisk,
+		IOTimeout:       cfg.IOTimeout,
+		IOTimeoutLimit:  cfg.IOTimeoutLimit,
+		Retry:           cfg.Retry,
+		EventLog:        cfg.EventLog,
+		ReadRecovery:    cfg.ReadRecovery,
+		RebuildRate:     r.rebuildRate.Load(),
+		Volumes:         r.Volumes(),
+	}
+
+	for i, disk := range r.disks {
+		disk.mu.RLock()
+		meta.Members[i] = MemberMeta{
+			Path:      disk.path,
+			Failed:    disk.failed,
+			BadBlocks: maps.Clone(disk.badBlocks),
+		}
+		if cp := disk.rebuildFrom; cp >= 0 {
+			meta.Members[i].RebuildCheckpoint = &cp
+		}
+		disk.mu.RUnlock()
+	}
+
+	if r.level == RAID5 {
+		switch p := r.raid5.placement.(type) {
+		case rotatingParity:
+		case restrictedParity:
+			meta.Placement = PlacementMeta{Policy: "restricted", Disks: p.disks}
+		default:
+			table := make([]int, cfg.BlocksPerDisk)
+			for stripe := range table {
+				table[stripe] = r.raid5.parityDisk(stripe)
+			}
+			meta.Placement = PlacementMeta{Policy: "table", Disks: table}
+		}
+	}
+
+	return json.MarshalIndent(meta, "", "  ")
+}
+
+// ImportMeta reassembles an array from a document written by ExportMeta.
+// diskPaths replaces the recorded member paths when the images have moved;
+// nil keeps them. Recorded bad blocks are merged into each member's table
+// and recorded failures are reapplied. Images of healthy members must exist.
+func ImportMeta(doc []byte, diskPaths []string) (*RAIDArray, error) {
+	var meta ArrayMeta
+	if err := json.Unmarshal(doc, &meta); err != nil {
+		return nil, fmt.Errorf("corrupt array metadata: %w", err)
+	}
+	if meta.Version != metaVersion {
+		return nil, fmt.Errorf("unsupported array metadata version %d", meta.Version)
+	}
+
+	if diskPaths == nil {
+		diskPaths = make([]string, len(meta.Members))
+		for i, m := range meta.Members {
+			diskPaths[i] = m.Path
+		}
+	}
+	if len(diskPaths) != len(meta.Members) {
+		return nil, fmt.Errorf("metadata describes %d members, got %d paths", len(meta.Members), len(diskPaths))
+	}
+
+	placement, err := meta.Placement.policy()
+	if err != nil {
+		return nil, err
+	}
+
+	for i, m := range meta.Members {
+		if _, err := os.Stat(diskPaths[i]); err != nil && !m.Failed {
+			return nil, fmt.Errorf("member %d: %w", i, err)
+		}
+		if err := mergeBadBlockList(diskPaths[i], m.BadBlocks); err != nil {
+			return nil, fmt.Errorf("member %d: %w", i, err)
+		}
+		if m.RebuildCheckpoint != nil {
+			if err := writeRebuildCheckpoint(diskPaths[i], *m.RebuildCheckpoint); err != nil {
+				return nil, fmt.Errorf("member %d: %w", i, err)
+			}
+		}
+	}
+
+	r, err := NewRAIDArray(RAIDConfig{
+		Level:           meta.Level,
+		DiskPaths:       diskPaths,
+		BlockSize:       meta.BlockSize,
+		BlocksPerDisk:   meta.BlocksPerDisk,
+		VerifyReads:     meta.VerifyReads,
+		ReadPolicy:      meta.ReadPolicy,
+		PreferredDisk:   meta.PreferredDisk,
+		RemapReserve:    meta.RemapReserve,
+		Prealloc:        meta.Prealloc,
+		ReadCacheBlocks: meta.ReadCacheBlocks,
+		StripeCacheSize: meta.StripeCacheSize,
+		ProbePercent:    meta.ProbePercent,
+		FillWarn:        meta.FillWarn,
+		FillCritical:    meta.FillCritical,
+		IOUring:         meta.IOUring,
+		DirectIO:        meta.DirectIO,
+		Mmap:            meta.Mmap,
+		SyncPolicy:      meta.SyncPolicy,
+		SyncInterval:    meta.SyncInterval,
+		SlowOpThreshold: meta.SlowOpThreshold,
+		SlowDisk:        meta.SlowDisk,
+		IOTimeout:       meta.IOTimeout,
+		IOTimeoutLimit:  meta.IOTimeoutLimit,
+		Retry:           meta.Retry,
+		ReadRecovery:    meta.ReadRecovery,
+		RebuildRate:     meta.RebuildRate,
+		Placement:       placement,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i, m := range meta.Members {
+		if m.Failed {
+			r.disks[i].SetFailed(true)
+		}
+	}
+	if err := r.volumes.restore(meta.Volumes, r.capacity); err != nil {
+		r.Close()
+		return nil, err
+	}
+	// open the log after restoring failures, which are not news
+	if err := r.openEventLog(meta.EventLog); err != nil {
+		r.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+func (p PlacementMeta) policy() (PlacementPolicy, error) {
+	switch p.Policy {
+	case "", "rotating":
+		return nil, nil
+	case "restricted":
+		return RotateParityAmong(p.Disks...), nil
+	case "table":
+		return parityTable(p.Disks), nil
+	default:
+		return nil, fmt.Errorf("unknown placement policy %q", p.Policy)
+	}
+}
+
+// mergeBadBlockList adds recorded remaps to the member's bad block list.
+// Remaps only ever accumulate, so entries already on disk take precedence.
+func mergeBadBlockList(path string, recorded map[int]*remapEntry) error {
+	if len(recorded) == 0 {
+		return nil
+	}
+
+	badBlocks, err := loadBadBlockList(path)
+	if err != nil {
+		return err
+	}
+	for block, e := range recorded {
+		if _, ok := badBlocks[block]; !ok {
+			badBlocks[block] = e
+		}
+	}
+	return writeBadBlockList(path, badBlocks)
+}
@@ -0,0 +1,239 @@
+package raid
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+type StatsSnapshot struct {
+	Time  time.Time     `json:"time"`
+	Level RAIDLevel     `json:"level"`
+	Array ArrayStats    `json:"array"`
+	Disks []DiskStats   `json:"disks"`
+	Scrub ScrubProgress `json:"scrub"`
+}
+
+func (r *RAIDArray) Snapshot() StatsSnapshot {
+	return StatsSnapshot{
+		Time:  r.clock.Now(),
+		Level: r.level,
+		Array: r.GetArrayStats(),
+		Disks: r.GetStats(),
+		Scrub: r.ScrubProgress(),
+	}
+}
+
+// DiskDelta is a member's activity between two snapshots.
+type DiskDelta struct {
+	Reads            uint64           `json:"reads"`
+	Writes           uint64           `json:"writes"`
+	ReadsPerSec      float64          `json:"reads_per_sec"`
+	WritesPerSec     float64          `json:"writes_per_sec"`
+	ReadBytesPerSec  float64          `json:"read_bytes_per_sec"`
+	WriteBytesPerSec float64          `json:"write_bytes_per_sec"`
+	ReadErrors       uint64           `json:"read_errors"`
+	WriteErrors      uint64           `json:"write_errors"`
+	ReadLatency      LatencyHistogram `json:"read_latency"` // Max is the longest since the stats were reset
+	WriteLatency     LatencyHistogram `json:"write_latency"`
+}
+
+// StatsDelta is the array's activity between two snapshots, as rates
+// ready for a dashboard.
+type StatsDelta struct {
+	Start       time.Time     `json:"start"`
+	End         time.Time     `json:"end"`
+	Interval    time.Duration `json:"interval_ns"`
+	Disks       []DiskDelta   `json:"disks"`
+	Total       DiskDelta     `json:"total"` // summed over the members
+	CacheHits   uint64        `json:"cache_hits"`
+	CacheMisses uint64        `json:"cache_misses"`
+}
+
+// Since returns the activity between prev and s. Counters reset in between
+// count from the reset.
+func (s StatsSnapshot) Since(prev StatsSnapshot) StatsDelta {
+	d := StatsDelta{
+		Start:       prev.Time,
+		End:         s.Time,
+		Interval:    s.Time.Sub(prev.Time),
+		Disks:       make([]DiskDelta, len(s.Disks)),
+		CacheHits:   since(s.Array.CacheHits, prev.Array.CacheHits),
+		CacheMisses: since(s.Array.CacheMisses, prev.Array.CacheMisses),
+	}
+	for i, cur := range s.Disks {
+		var old DiskStats
+		if i < len(prev.Disks) {
+			old = prev.Disks[i]
+		}
+		dd := DiskDelta{
+			Reads:        since(cur.ReadCount, old.ReadCount),
+			Writes:       since(cur.WriteCount, old.WriteCount),
+			ReadErrors:   since(cur.ReadErrors, old.ReadErrors),
+			WriteErrors:  since(cur.WriteErrors, old.WriteErrors),
+			ReadLatency:  cur.ReadLatency.since(old.ReadLatency),
+			WriteLatency: cur.WriteLatency.since(old.WriteLatency),
+		}
+		d.Total.Reads += dd.Reads
+		d.Total.Writes += dd.Writes
+		d.Total.ReadErrors += dd.ReadErrors
+		d.Total.WriteErrors += dd.WriteErrors
+		d.Total.ReadLatency.merge(dd.ReadLatency)
+		d.Total.WriteLatency.merge(dd.WriteLatency)
+
+		if secs := d.Interval.Seconds(); secs > 0 {
+			dd.ReadsPerSec = float64(dd.Reads) / secs
+			dd.WritesPerSec = float64(dd.Writes) / secs
+			dd.ReadBytesPerSec = float64(since(cur.BytesRead, old.BytesRead)) / secs
+			dd.WriteBytesPerSec = float64(since(cur.BytesWritten, old.BytesWritten)) / secs
+			d.Total.ReadsPerSec += dd.ReadsPerSec
+			d.Total.WritesPerSec += dd.WritesPerSec
+			d.Total.ReadBytesPerSec += dd.ReadBytesPerSec
+			d.Total.WriteBytesPerSec += dd.WriteBytesPerSec
+		}
+		d.Disks[i] = dd
+	}
+	return d
+}
+
+// since is cur-prev for a counter that may have been reset to zero after
+// prev was taken.
+func since(cur, prev uint64) uint64 {
+	if cur < prev {
+		return cur
+	}
+	return cur - prev
+}
+
+// ResetStats zeroes the counters reported by GetStats and GetArrayStats,
+// for measuring from a known point. SMART attributes and health are about
+// the drives' whole lives and are not reset.
+func (r *RAIDArray) ResetStats() {
+	r.mu.RLock()
+	for _, disk := range r.disks {
+		disk.resetStats()
+	}
+	r.mu.RUnlock()
+
+	if r.cache != nil {
+		r.cache.resetStats()
+	}
+	if r.level == RAID5 {
+		r.raid5.mu.RLock()
+		if c := r.raid5.stripeCache; c != nil {
+			c.resetStats()
+		}
+		r.raid5.mu.RUnlock()
+		r.raid5.phases.reset()
+		r.raid5.recovery.reset()
+	}
+}
+
+// PushMetrics sends a stats snapshot to target every interval until ctx is
+// cancelled. An http:// or https:// target receives the snapshot as a JSON
+// POST; a statsd://host:port target receives gauges over UDP. Failed pushes
+// are reported and retried on the next tick.
+func (r *RAIDArray) PushMetrics(ctx context.Context, target string, interval time.Duration) error {
+	if interval <= 0 {
+		return fmt.Errorf("push interval must be positive")
+	}
+
+	u, err := url.Parse(target)
+	if err != nil {
+		return fmt.Errorf("invalid metrics target %q: %w", target, err)
+	}
+
+	var push func(StatsSnapshot) error
+	switch u.Scheme {
+	case "http", "https":
+		push = func(s StatsSnapshot) error { return pushJSON(ctx, target, s) }
+	case "statsd":
+		conn, err := net.Dial("udp", u.Host)
+		if err != nil {
+			return fmt.Errorf("failed to reach statsd at %s: %w", u.Host, err)
+		}
+		defer conn.Close()
+		push = func(s StatsSnapshot) error { return pushStatsd(conn, s) }
+	default:
+		return fmt.Errorf("unsupported metrics target scheme %q", u.Scheme)
+	}
+
+	ticker := r.clock.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C():
+			if err := push(r.Snapshot()); err != nil {
+				r.log.Error("metrics push failed", "target", u.Redacted(), "err", err)
+			}
+		}
+	}
+}
+
+func pushJSON(ctx context.Context, target string, s StatsSnapshot) error {
+	body, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+func pushStatsd(conn net.Conn, s StatsSnapshot) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "raid.cache.hits:%d|g\n", s.Array.CacheHits)
+	fmt.Fprintf(&b, "raid.cache.misses:%d|g\n", s.Array.CacheMisses)
+	if s.Level == RAID5 {
+		p := s.Array.WritePhases
+		fmt.Fprintf(&b, "raid.raid5.writes:%d|g\n", s.Array.RAID5Writes)
+		fmt.Fprintf(&b, "raid.raid5.phase.pre_read_ns:%d|g\n", p.PreRead)
+		fmt.Fprintf(&b, "raid.raid5.phase.xor_ns:%d|g\n", p.XOR)
+		fmt.Fprintf(&b, "raid.raid5.phase.data_write_ns:%d|g\n", p.DataWrite)
+		fmt.Fprintf(&b, "raid.raid5.phase.parity_write_ns:%d|g\n", p.ParityWrite)
+		fmt.Fprintf(&b, "raid.raid5.phase.sync_ns:%d|g\n", p.Sync)
+	}
+	for i, d := range s.Disks {
+		failed := 0
+		if d.Failed {
+			failed = 1
+		}
+		fmt.Fprintf(&b, "raid.disk%d.reads:%d|g\n", i, d.ReadCount)
+		fmt.Fprintf(&b, "raid.disk%d.writes:%d|g\n", i, d.WriteCount)
+		fmt.Fprintf(&b, "raid.disk%d.failed:%d|g\n", i, failed)
+		fmt.Fprintf(&b, "raid.disk%d.remapped:%d|g\n", i, d.RemappedBlocks)
+		fmt.Fprintf(&b, "raid.disk%d.bytes_read:%d|g\n", i, d.BytesRead)
+		fmt.Fprintf(&b, "raid.disk%d.bytes_written:%d|g\n", i, d.BytesWritten)
+		fmt.Fprintf(&b, "raid.disk%d.read_errors:%d|g\n", i, d.ReadErrors)
+		fmt.Fprintf(&b, "raid.disk%d.write_errors:%d|g\n", i, d.WriteErrors)
+		fmt.Fprintf(&b, "raid.disk%d.in_flight:%d|g\n", i, d.InFlight)
+		fmt.Fprintf(&b, "raid.disk%d.read_p99_ns:%d|g\n", i, d.ReadLatency.Percentile(0.99))
+		fmt.Fprintf(&b, "raid.disk%d.write_p99_ns:%d|g\n", i, d.WriteLatency.Percentile(0.99))
+	}
+
+	_, err := conn.Write([]byte(b.String()))
+	return err
+}
@@ -0,0 +1,25 @@
+package raid
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+const msSync = 0x4 // MS_SYNC
+
+func mapImage(file *os.File, size int) ([]byte, error) {
+	return syscall.Mmap(int(file.Fd()), 0, size, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+}
+
+func unmapImage(m []byte) error {
+	return syscall.Munmap(m)
+}
+
+func syncImage(m []byte) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_MSYNC, uintptr(unsafe.Pointer(&m[0])), uintptr(len(m)), msSync)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
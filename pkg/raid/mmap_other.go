@@ -0,0 +1,16 @@
+//go:build !linux
+
+package raid
+
+import (
+	"errors"
+	"os"
+)
+
+func mapImage(file *os.File, size int) ([]byte, error) {
+	return nil, errors.New("memory-mapped images are only supported on linux")
+}
+
+func unmapImage(m []byte) error { return nil }
+
+func syncImage(m []byte) error { return nil }
@@ -0,0 +1,305 @@
+package raid
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+)
+
+// NBD protocol constants, from the fixed newstyle handshake described in
+// the NBD project's proto.md.
+const (
+	nbdMagic        = 0x4e42444d41474943 // "NBDMAGIC"
+	nbdOptMagic     = 0x49484156454f5054 // "IHAVEOPT"
+	nbdReplyMagic   = 0x0003e889045565a9
+	nbdRequestMagic = 0x25609513
+	nbdSimpleReply  = 0x67446698
+
+	nbdFlagFixedNewstyle = 1 << 0
+	nbdFlagNoZeroes      = 1 << 1
+
+	nbdOptExportName = 1
+	nbdOptAbort      = 2
+	nbdOptList       = 3
+	nbdOptInfo       = 6
+	nbdOptGo         = 7
+
+	nbdRepAck        = 1
+	nbdRepServer     = 2
+	nbdRepInfo       = 3
+	nbdRepErrUnsup   = 1<<31 | 1
+	nbdRepErrInvalid = 1<<31 | 3
+
+	nbdInfoExport    = 0
+	nbdInfoBlockSize = 3
+
+	nbdFlagHasFlags        = 1 << 0
+	nbdFlagSendFlush       = 1 << 2
+	nbdFlagSendFUA         = 1 << 3
+	nbdFlagSendTrim        = 1 << 5
+	nbdFlagSendWriteZeroes = 1 << 6
+	nbdTransmissionFlags   = nbdFlagHasFlags | nbdFlagSendFlush | nbdFlagSendFUA | nbdFlagSendTrim | nbdFlagSendWriteZeroes
+
+	nbdCmdRead        = 0
+	nbdCmdWrite       = 1
+	nbdCmdDisc        = 2
+	nbdCmdFlush       = 3
+	nbdCmdTrim        = 4
+	nbdCmdWriteZeroes = 6
+
+	nbdCmdFlagFUA = 1 << 0
+
+	nbdEIO    = 5
+	nbdEINVAL = 22
+	nbdENOSPC = 28
+
+	nbdMaxRequest = 32 << 20 // largest read or write accepted in one request
+)
+
+// ServeNBD exports the array's logical address space over the NBD protocol
+// on connections accepted from ln, until ln is closed. The export has a
+// single unnamed volume, so Linux can attach it with
+// nbd-client host port /dev/nbd0. Requests on one connection are handled
+// in order; several connections may share the array.
+func ServeNBD(ln net.Listener, r *RAIDArray) error {
+	for {
+		conn, err := ln.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		go func() {
+			defer conn.Close()
+			if err := r.serveNBD(conn); err != nil {
+				r.log.Warn("nbd connection ended", "remote", conn.RemoteAddr().String(), "err", err)
+			}
+		}()
+	}
+}
+
+func (r *RAIDArray) serveNBD(conn net.Conn) error {
+	rd := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+
+	ok, err := r.nbdHandshake(rd, w)
+	if err != nil || !ok {
+		return err
+	}
+	return r.nbdTransmit(rd, w)
+}
+
+// nbdHandshake negotiates options until the client picks the export. It
+// returns false if the client went away without doing so.
+func (r *RAIDArray) nbdHandshake(rd *bufio.Reader, w *bufio.Writer) (bool, error) {
+	var hello [18]byte
+	binary.BigEndian.PutUint64(hello[0:8], nbdMagic)
+	binary.BigEndian.PutUint64(hello[8:16], nbdOptMagic)
+	binary.BigEndian.PutUint16(hello[16:18], nbdFlagFixedNewstyle|nbdFlagNoZeroes)
+	w.Write(hello[:])
+	if err := w.Flush(); err != nil {
+		return false, err
+	}
+
+	var clientFlags uint32
+	if err := binary.Read(rd, binary.BigEndian, &clientFlags); err != nil {
+		return false, err
+	}
+	if clientFlags&nbdFlagFixedNewstyle == 0 {
+		return false, fmt.Errorf("client does not support fixed newstyle negotiation")
+	}
+	noZeroes := clientFlags&nbdFlagNoZeroes != 0
+
+	for {
+		var hdr [16]byte
+		if _, err := io.ReadFull(rd, hdr[:]); err != nil {
+			return false, err
+		}
+		if binary.BigEndian.Uint64(hdr[0:8]) != nbdOptMagic {
+			return false, fmt.Errorf("bad option magic")
+		}
+		opt := binary.BigEndian.Uint32(hdr[8:12])
+		data, err := readPayload(rd, binary.BigEndian.Uint32(hdr[12:16]))
+		if err != nil {
+			return false, err
+		}
+
+		switch opt {
+		case nbdOptExportName:
+			var reply [10 + 124]byte
+			binary.BigEndian.PutUint64(reply[0:8], uint64(r.Size()))
+			binary.BigEndian.PutUint16(reply[8:10], nbdTransmissionFlags)
+			if noZeroes {
+				w.Write(reply[:10])
+			} else {
+				w.Write(reply[:])
+			}
+			return true, w.Flush()
+		case nbdOptAbort:
+			nbdOptReply(w, opt, nbdRepAck, nil)
+			return false, w.Flush()
+		case nbdOptList:
+			nbdOptReply(w, opt, nbdRepServer, make([]byte, 4)) // the unnamed export
+			nbdOptReply(w, opt, nbdRepAck, nil)
+		case nbdOptInfo, nbdOptGo:
+			if len(data) < 6 || int(binary.BigEndian.Uint32(data[0:4]))+6 > len(data) {
+				nbdOptReply(w, opt, nbdRepErrInvalid, nil)
+				break
+			}
+			export := make([]byte, 12)
+			binary.BigEndian.PutUint16(export[0:2], nbdInfoExport)
+			binary.BigEndian.PutUint64(export[2:10], uint64(r.Size()))
+			binary.BigEndian.PutUint16(export[10:12], nbdTransmissionFlags)
+			nbdOptReply(w, opt, nbdRepInfo, export)
+
+			sizes := make([]byte, 14)
+			binary.BigEndian.PutUint16(sizes[0:2], nbdInfoBlockSize)
+			binary.BigEndian.PutUint32(sizes[2:6], 1)
+			binary.BigEndian.PutUint32(sizes[6:10], uint32(r.blockSize))
+			binary.BigEndian.PutUint32(sizes[10:14], nbdMaxRequest)
+			nbdOptReply(w, opt, nbdRepInfo, sizes)
+
+			nbdOptReply(w, opt, nbdRepAck, nil)
+			if opt == nbdOptGo {
+				return true, w.Flush()
+			}
+		default:
+			nbdOptReply(w, opt, nbdRepErrUnsup, nil)
+		}
+		if err := w.Flush(); err != nil {
+			return false, err
+		}
+	}
+}
+
+func nbdOptReply(w *bufio.Writer, opt, reply uint32, data []byte) {
+	var hdr [20]byte
+	binary.BigEndian.PutUint64(hdr[0:8], nbdReplyMagic)
+	binary.BigEndian.PutUint32(hdr[8:12], opt)
+	binary.BigEndian.PutUint32(hdr[12:16], reply)
+	binary.BigEndian.PutUint32(hdr[16:20], uint32(len(data)))
+	w.Write(hdr[:])
+	w.Write(data)
+}
+
+// nbdTransmit serves block requests until the client disconnects.
+func (r *RAIDArray) nbdTransmit(rd *bufio.Reader, w *bufio.Writer) error {
+	for {
+		var hdr [28]byte
+		if _, err := io.ReadFull(rd, hdr[:]); err != nil {
+			return err
+		}
+		if binary.BigEndian.Uint32(hdr[0:4]) != nbdRequestMagic {
+			return fmt.Errorf("bad request magic")
+		}
+		flags := binary.BigEndian.Uint16(hdr[4:6])
+		cmd := binary.BigEndian.Uint16(hdr[6:8])
+		handle := hdr[8:16]
+		off := int64(binary.BigEndian.Uint64(hdr[16:24]))
+		length := binary.BigEndian.Uint32(hdr[24:28])
+
+		var payload []byte
+		if cmd == nbdCmdWrite {
+			if length > nbdMaxRequest {
+				return fmt.Errorf("write of %d bytes exceeds limit", length)
+			}
+			payload = make([]byte, length)
+			if _, err := io.ReadFull(rd, payload); err != nil {
+				return err
+			}
+		}
+		if cmd == nbdCmdDisc {
+			return nil
+		}
+
+		data, errno := r.nbdRequest(cmd, flags, off, length, payload)
+
+		var reply [16]byte
+		binary.BigEndian.PutUint32(reply[0:4], nbdSimpleReply)
+		binary.BigEndian.PutUint32(reply[4:8], errno)
+		copy(reply[8:16], handle)
+		w.Write(reply[:])
+		if errno == 0 {
+			w.Write(data)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+}
+
+// nbdRequest runs one transmission request and returns the read data and
+// an NBD error number, 0 on success.
+func (r *RAIDArray) nbdRequest(cmd, flags uint16, off int64, length uint32, payload []byte) ([]byte, uint32) {
+	if cmd != nbdCmdFlush && (off < 0 || off+int64(length) > r.Size()) {
+		return nil, nbdEINVAL
+	}
+
+	var data []byte
+	var err error
+	switch cmd {
+	case nbdCmdRead:
+		if length > nbdMaxRequest {
+			return nil, nbdEINVAL
+		}
+		data = make([]byte, length)
+		_, err = r.ReadAt(data, off)
+	case nbdCmdWrite:
+		_, err = r.WriteAt(payload, off)
+	case nbdCmdFlush:
+		err = r.Flush()
+	case nbdCmdTrim, nbdCmdWriteZeroes:
+		err = r.zeroRange(off, int64(length), cmd == nbdCmdWriteZeroes)
+	default:
+		return nil, nbdEINVAL
+	}
+
+	if err == nil && flags&nbdCmdFlagFUA != 0 && cmd != nbdCmdRead {
+		err = r.Flush()
+	}
+	switch {
+	case err == nil:
+		return data, 0
+	case errors.Is(err, ErrNoSpace):
+		return nil, nbdENOSPC
+	case errors.Is(err, ErrBlockOutOfRange):
+		return nil, nbdEINVAL
+	default:
+		r.log.Error("nbd request failed", "cmd", cmd, "offset", off, "length", length, "err", err)
+		return nil, nbdEIO
+	}
+}
+
+// zeroRange discards the whole blocks in [off, off+n). With partial set the
+// bytes of partially covered blocks at either end are zeroed too; without
+// it, as for a trim, they are left alone.
+func (r *RAIDArray) zeroRange(off, n int64, partial bool) error {
+	bs := int64(r.blockSize)
+	first, end := (off+bs-1)/bs, (off+n)/bs
+
+	if first >= end {
+		if partial && n > 0 {
+			_, err := r.WriteAt(make([]byte, n), off)
+			return err
+		}
+		return nil
+	}
+
+	if partial {
+		if head := first*bs - off; head > 0 {
+			if _, err := r.WriteAt(make([]byte, head), off); err != nil {
+				return err
+			}
+		}
+		if tail := off + n - end*bs; tail > 0 {
+			if _, err := r.WriteAt(make([]byte, tail), end*bs); err != nil {
+				return err
+			}
+		}
+	}
+	return r.Discard(int(first), int(end-first))
+}
@@ -0,0 +1,85 @@
+package raid
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var ErrNoSpace = errors.New("no space left on backing storage")
+
+const noSpaceProbeInterval = time.Second
+
+type noSpaceState struct {
+	mu     sync.Mutex
+	paused bool
+}
+
+// WritesPaused reports whether writes are suspended because a member's
+// backing storage ran out of space.
+func (r *RAIDArray) WritesPaused() bool {
+	r.noSpace.mu.Lock()
+	defer r.noSpace.mu.Unlock()
+	return r.noSpace.paused
+}
+
+func (r *RAIDArray) checkWritable() error {
+	if r.WritesPaused() {
+		return fmt.Errorf("writes paused: %w", ErrNoSpace)
+	}
+	return nil
+}
+
+// noteWriteError pauses writes for the whole array when a member reports
+// ENOSPC, rather than letting members fail one by one. Writes resume on
+// their own once the failed member write can be completed again.
+func (r *RAIDArray) noteWriteError(err error) {
+	if !errors.Is(err, ErrNoSpace) {
+		return
+	}
+
+	r.noSpace.mu.Lock()
+	if r.noSpace.paused {
+		r.noSpace.mu.Unlock()
+		return
+	}
+	r.noSpace.paused = true
+	r.noSpace.mu.Unlock()
+
+	r.log.Error("backing storage full, writes paused until space is freed")
+	r.emit(Event{Type: EventWritesPaused, Disk: -1, Stripe: -1})
+	go r.waitForSpace()
+}
+
+func (r *RAIDArray) waitForSpace() {
+	ticker := r.clock.NewTicker(noSpaceProbeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.done:
+			return
+		case <-ticker.C():
+		}
+
+		ok := true
+		for _, disk := range r.disks {
+			if err := disk.probeSpace(); err != nil {
+				ok = false
+				break
+			}
+		}
+		if !ok {
+			continue
+		}
+
+		r.noSpace.mu.Lock()
+		r.noSpace.paused = false
+		r.noSpace.mu.Unlock()
+
+		r.log.Info("space available again, writes resumed")
+		r.emit(Event{Type: EventWritesResumed, Disk: -1, Stripe: -1})
+		return
+	}
+}
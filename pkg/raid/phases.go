@@ -0,0 +1,69 @@
+package raid
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// WritePhases breaks the time of a RAID 5 read-modify-write down by phase.
+// Write phases exclude fsync, which is reported separately.
+type WritePhases struct {
+	PreRead     time.Duration `json:"pre_read_ns"`
+	XOR         time.Duration `json:"xor_ns"`
+	DataWrite   time.Duration `json:"data_write_ns"`
+	ParityWrite time.Duration `json:"parity_write_ns"`
+	Sync        time.Duration `json:"sync_ns"`
+}
+
+func (p WritePhases) Total() time.Duration {
+	return p.PreRead + p.XOR + p.DataWrite + p.ParityWrite + p.Sync
+}
+
+func (p WritePhases) String() string {
+	return fmt.Sprintf("pre-read %v, xor %v, data write %v, parity write %v, sync %v",
+		p.PreRead, p.XOR, p.DataWrite, p.ParityWrite, p.Sync)
+}
+
+func (p *WritePhases) add(o WritePhases) {
+	p.PreRead += o.PreRead
+	p.XOR += o.XOR
+	p.DataWrite += o.DataWrite
+	p.ParityWrite += o.ParityWrite
+	p.Sync += o.Sync
+}
+
+// phaseStats accumulates WritePhases across writes for ArrayStats.
+type phaseStats struct {
+	mu     sync.Mutex
+	writes uint64
+	total  WritePhases
+}
+
+func (s *phaseStats) record(p WritePhases) {
+	s.mu.Lock()
+	s.writes++
+	s.total.add(p)
+	s.mu.Unlock()
+}
+
+func (s *phaseStats) snapshot() (uint64, WritePhases) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.writes, s.total
+}
+
+func (s *phaseStats) reset() {
+	s.mu.Lock()
+	s.writes, s.total = 0, WritePhases{}
+	s.mu.Unlock()
+}
+
+// notePhases aggregates a finished write and logs it when it was slower
+// than the configured threshold.
+func (r *raid5Impl) notePhases(logicalBlockID int, p WritePhases) {
+	r.phases.record(p)
+	if t := r.slowOpThreshold; t > 0 && p.Total() >= t {
+		r.array.log.Warn("slow RAID 5 write", "block", logicalBlockID, "total", p.Total(), "phases", p.String())
+	}
+}
@@ -0,0 +1,54 @@
+package raid
+
+import "fmt"
+
+// PlacementPolicy decides which member holds parity for each RAID 5
+// stripe. It must be deterministic, and the same policy has to be supplied
+// whenever the array is reopened.
+type PlacementPolicy interface {
+	ParityDisk(stripe, numDisks int) int
+}
+
+type rotatingParity struct{}
+
+func (rotatingParity) ParityDisk(stripe, numDisks int) int {
+	return stripe % numDisks
+}
+
+type restrictedParity struct {
+	disks []int
+}
+
+// RotateParityAmong rotates parity across the given members only, e.g. to
+// keep parity off a slow disk.
+func RotateParityAmong(disks ...int) PlacementPolicy {
+	return restrictedParity{disks: disks}
+}
+
+func (p restrictedParity) ParityDisk(stripe, numDisks int) int {
+	return p.disks[stripe%len(p.disks)]
+}
+
+// parityTable places parity from an explicit per-stripe list, as imported
+// from array metadata.
+type parityTable []int
+
+func (t parityTable) ParityDisk(stripe, numDisks int) int {
+	if stripe >= len(t) {
+		return -1
+	}
+	return t[stripe]
+}
+
+func validatePlacement(p PlacementPolicy, numDisks, numStripes int) error {
+	if rp, ok := p.(restrictedParity); ok && len(rp.disks) == 0 {
+		return fmt.Errorf("placement policy has no parity disks")
+	}
+
+	for stripe := 0; stripe < numStripes; stripe++ {
+		if d := p.ParityDisk(stripe, numDisks); d < 0 || d >= numDisks {
+			return fmt.Errorf("placement policy put parity for stripe %d on invalid disk %d", stripe, d)
+		}
+	}
+	return nil
+}
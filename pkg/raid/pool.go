@@ -0,0 +1,36 @@
+package raid
+
+import "sync"
+
+// bufferPool recycles block-sized scratch buffers for the parity,
+// reconstruction and scrub paths. Buffers handed out are not cleared.
+type bufferPool struct {
+	size int
+	pool sync.Pool
+}
+
+func newBufferPool(size int, aligned bool) *bufferPool {
+	p := &bufferPool{size: size}
+	p.pool.New = func() any {
+		var b []byte
+		if aligned {
+			b = alignedBuffer(size)
+		} else {
+			b = make([]byte, size)
+		}
+		return &b
+	}
+	return p
+}
+
+func (p *bufferPool) get() []byte {
+	return *p.pool.Get().(*[]byte)
+}
+
+func (p *bufferPool) put(b []byte) {
+	if cap(b) < p.size {
+		return
+	}
+	b = b[:p.size]
+	p.pool.Put(&b)
+}
@@ -0,0 +1,32 @@
+package raid
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// allocate reserves real blocks for the first size bytes of the file.
+// Filesystems without fallocate support fall back to writing zeros.
+func allocate(file *os.File, size int64) error {
+	err := syscall.Fallocate(int(file.Fd()), 0, 0, size)
+	if errors.Is(err, syscall.EOPNOTSUPP) || errors.Is(err, syscall.ENOSYS) {
+		return zeroFill(file, size)
+	}
+	return err
+}
+
+const (
+	fallocKeepSize  = 0x1 // FALLOC_FL_KEEP_SIZE
+	fallocPunchHole = 0x2 // FALLOC_FL_PUNCH_HOLE
+)
+
+// punchHole deallocates size bytes at off without changing the file size.
+// The range reads as zeros afterwards.
+func punchHole(file *os.File, off, size int64) error {
+	err := syscall.Fallocate(int(file.Fd()), fallocKeepSize|fallocPunchHole, off, size)
+	if errors.Is(err, syscall.EOPNOTSUPP) || errors.Is(err, syscall.ENOSYS) {
+		return errors.ErrUnsupported
+	}
+	return err
+}
@@ -0,0 +1,16 @@
+//go:build !linux
+
+package raid
+
+import (
+	"errors"
+	"os"
+)
+
+func allocate(file *os.File, size int64) error {
+	return zeroFill(file, size)
+}
+
+func punchHole(file *os.File, off, size int64) error {
+	return errors.ErrUnsupported
+}
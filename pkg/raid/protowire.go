@@ -0,0 +1,120 @@
+package raid
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Protocol buffer wire format, enough for the messages of the gRPC control
+// API in proto/raid/v1/raid.proto.
+
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+var errCorruptMessage = errors.New("corrupt protobuf message")
+
+// pbWriter appends fields to a message. Like proto3, it leaves out scalar
+// fields holding their zero value.
+type pbWriter struct {
+	b []byte
+}
+
+func (w *pbWriter) tag(field, wire int) {
+	w.b = binary.AppendUvarint(w.b, uint64(field)<<3|uint64(wire))
+}
+
+func (w *pbWriter) uint(field int, v uint64) {
+	if v != 0 {
+		w.tag(field, wireVarint)
+		w.b = binary.AppendUvarint(w.b, v)
+	}
+}
+
+// int writes an int32 or int64 field; negative values take ten bytes.
+func (w *pbWriter) int(field int, v int64) {
+	w.uint(field, uint64(v))
+}
+
+func (w *pbWriter) bool(field int, v bool) {
+	if v {
+		w.uint(field, 1)
+	}
+}
+
+func (w *pbWriter) double(field int, v float64) {
+	if v != 0 {
+		w.tag(field, wireFixed64)
+		w.b = binary.LittleEndian.AppendUint64(w.b, math.Float64bits(v))
+	}
+}
+
+func (w *pbWriter) string(field int, s string) {
+	if s != "" {
+		w.tag(field, wireBytes)
+		w.b = binary.AppendUvarint(w.b, uint64(len(s)))
+		w.b = append(w.b, s...)
+	}
+}
+
+// message writes an embedded message, even an empty one, so the reader
+// sees it is set.
+func (w *pbWriter) message(field int, m []byte) {
+	w.tag(field, wireBytes)
+	w.b = binary.AppendUvarint(w.b, uint64(len(m)))
+	w.b = append(w.b, m...)
+}
+
+// pbField is a decoded field: v holds varint and fixed values, data the
+// contents of length-delimited ones.
+type pbField struct {
+	num  int
+	wire int
+	v    uint64
+	data []byte
+}
+
+// pbDecode splits a message into its fields, in wire order.
+func pbDecode(b []byte) ([]pbField, error) {
+	var fields []pbField
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		if n <= 0 || key>>3 == 0 {
+			return nil, errCorruptMessage
+		}
+		b = b[n:]
+		f := pbField{num: int(key >> 3), wire: int(key & 7)}
+		switch f.wire {
+		case wireVarint:
+			if f.v, n = binary.Uvarint(b); n <= 0 {
+				return nil, errCorruptMessage
+			}
+			b = b[n:]
+		case wireFixed64:
+			if len(b) < 8 {
+				return nil, errCorruptMessage
+			}
+			f.v, b = binary.LittleEndian.Uint64(b), b[8:]
+		case wireFixed32:
+			if len(b) < 4 {
+				return nil, errCorruptMessage
+			}
+			f.v, b = uint64(binary.LittleEndian.Uint32(b)), b[4:]
+		case wireBytes:
+			size, n := binary.Uvarint(b)
+			if n <= 0 || size > uint64(len(b)-n) {
+				return nil, errCorruptMessage
+			}
+			f.data, b = b[n:n+int(size)], b[n+int(size):]
+		default:
+			return nil, fmt.Errorf("%w: wire type %d", errCorruptMessage, f.wire)
+		}
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
@@ -0,0 +1,138 @@
+package raid
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+)
+
+// Quirks make a member misbehave the way real drives sometimes do, so the
+// effect on the array and what scrub or Check catch can be shown. The zero
+// value is an honest drive.
+type Quirks struct {
+	DropEveryNth  int  // acknowledge every Nth write without storing it, 0 disables
+	WriteCache    int  // blocks held in a volatile cache before reaching the image, 0 writes through
+	LieAboutFlush bool // Sync reports success without emptying the write cache
+	ReorderWrites bool // the write cache empties in random order instead of oldest first
+}
+
+type quirkState struct {
+	Quirks
+	writes int            // writes seen, for DropEveryNth
+	cached map[int][]byte // blocks not yet on the image
+	order  []int          // cached blocks, oldest first
+	rand   *lockedRand
+}
+
+// SetQuirks changes how the disk misbehaves. Writes held in the previous
+// write cache are stored first.
+func (d *Disk) SetQuirks(q Quirks) error {
+	return d.setQuirks(q, newLockedRand(nil))
+}
+
+func (d *Disk) setQuirks(q Quirks, rnd *lockedRand) error {
+	if q.DropEveryNth < 0 || q.WriteCache < 0 {
+		return fmt.Errorf("quirk settings must not be negative")
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.quirks != nil {
+		if err := d.drainWriteCache(); err != nil {
+			return err
+		}
+		d.quirks = nil
+	}
+	if q != (Quirks{}) {
+		d.quirks = &quirkState{Quirks: q, cached: make(map[int][]byte), rand: rnd}
+	}
+	return nil
+}
+
+// PowerLoss simulates a power cut: writes still in the volatile write cache
+// are lost. It returns how many blocks were lost.
+func (d *Disk) PowerLoss() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.quirks == nil {
+		return 0
+	}
+	lost := len(d.quirks.order)
+	for _, blockID := range d.quirks.order {
+		d.setZero(blockID, false) // back to whatever the image holds
+	}
+	clear(d.quirks.cached)
+	d.quirks.order = d.quirks.order[:0]
+	return lost
+}
+
+// quirkWrite takes the place of storeBlock while quirks are set. Callers
+// hold d.mu.
+func (d *Disk) quirkWrite(blockID int, data []byte) error {
+	q := d.quirks
+	q.writes++
+	if q.DropEveryNth > 0 && q.writes%q.DropEveryNth == 0 {
+		return nil
+	}
+
+	if q.WriteCache == 0 {
+		_, err := d.storeBlock(blockID, data)
+		return err
+	}
+
+	if cached, ok := q.cached[blockID]; ok {
+		copy(cached, data)
+	} else {
+		q.cached[blockID] = bytes.Clone(data)
+		q.order = append(q.order, blockID)
+	}
+
+	if d.syncPolicy == SyncAlways && !q.LieAboutFlush {
+		return d.drainWriteCache()
+	}
+	d.dirty = true
+
+	for len(q.order) > q.WriteCache {
+		if err := d.evictCached(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// drainWriteCache stores every cached write on the image.
+func (d *Disk) drainWriteCache() error {
+	for len(d.quirks.order) > 0 {
+		if err := d.evictCached(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *Disk) evictCached() error {
+	q := d.quirks
+	i := 0
+	if q.ReorderWrites {
+		i = q.rand.Intn(len(q.order))
+	}
+	blockID := q.order[i]
+
+	if _, err := d.storeBlock(blockID, q.cached[blockID]); err != nil {
+		return err
+	}
+	delete(q.cached, blockID)
+	q.order = slices.Delete(q.order, i, i+1)
+	return nil
+}
+
+// SetQuirks makes a member emulate drive misbehavior; the zero Quirks
+// restores an honest drive.
+func (r *RAIDArray) SetQuirks(diskIndex int, q Quirks) error {
+	if diskIndex < 0 || diskIndex >= r.numDisks {
+		return fmt.Errorf("invalid disk index %d", diskIndex)
+	}
+	return r.disks[diskIndex].setQuirks(q, r.rand)
+}
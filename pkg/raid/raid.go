@@ -0,0 +1,662 @@
+// Package raid implements software RAID 0, 1 and 5 over disks backed by
+// flat image files.
+package raid
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type RAIDLevel int
+
+const (
+	RAID0 RAIDLevel = 0 // striping
+	RAID1 RAIDLevel = 1 // mirroring
+	RAID5 RAIDLevel = 5 // striping + distributed parity
+)
+
+type RAIDArray struct {
+	level     RAIDLevel
+	disks     []*Disk
+	blockSize int
+	numDisks  int
+	capacity  int // total logical blocks
+	mu        sync.RWMutex
+
+	scrub    scrubState
+	noSpace  noSpaceState
+	done     chan struct{}  // closed by Close to stop background work
+	async    sync.WaitGroup // operations started by the *Async methods
+	inflight sync.RWMutex   // held shared by each running *Async operation
+	cache    *blockCache    // nil when read caching is disabled
+	buffers  *bufferPool    // block-sized scratch buffers
+	partial  partialLocks   // WriteAt read-modify-writes
+	volumes  volumeTable
+
+	config      RAIDConfig // as passed to NewRAIDArray, for ExportMeta
+	probeResult *ProbeResult
+	ring        *ioRing // shared by all members when IOUring is set
+
+	clock  Clock
+	rand   *lockedRand
+	log    *slog.Logger
+	tracer Tracer
+
+	events   eventBus
+	eventLog eventLog
+	created  bool // NewRAIDArray made the member images
+
+	rebuildRate atomic.Int64 // bytes per second, 0 = unlimited
+
+	raid0 *raid0Impl
+	raid1 *raid1Impl
+	raid5 *raid5Impl
+}
+
+type RAIDConfig struct {
+	Level         RAIDLevel
+	DiskPaths     []string
+	BlockSize     int
+	BlocksPerDisk int
+
+	VerifyReads   bool         // RAID1: read every mirror and arbitrate by majority, needs 3 or more mirrors
+	ReadPolicy    ReadPolicy   // RAID1: which mirror serves a read
+	PreferredDisk int          // RAID1: mirror used by ReadPreferred
+	RemapReserve  int          // per-disk relocation blocks for bad block remapping
+	Prealloc      PreallocMode // how member images are sized on creation
+	IOUring       bool         // batch member I/O through one shared io_uring (Linux only)
+	DirectIO      bool         // open members with O_DIRECT, block size must be a multiple of 4096
+	Mmap          bool         // memory-map member images instead of using pread/pwrite (Linux only)
+	Backends      []Backend    // per-member storage in DiskPaths order, nil entries use the image file at the path
+
+	SyncPolicy   SyncPolicy    // when member writes are fsynced, after every block by default
+	SyncInterval time.Duration // SyncPeriodic: time between syncs, one second by default
+
+	ReadCacheBlocks int // LRU read cache size in blocks, 0 disables it
+	StripeCacheSize int // RAID5: whole stripes kept in memory to skip pre-reads
+
+	SlowOpThreshold time.Duration  // RAID5: log writes slower than this with a per-phase breakdown, 0 disables
+	ReadRecovery    []ReadRecovery // RAID5: steps tried after a failed member read, nil means retry then reconstruct
+	RebuildRate     int64          // rebuild limit in bytes per second, 0 = unlimited; see SetRebuildRate
+
+	IOTimeout      time.Duration // fail member transfers taking longer than this, 0 waits forever
+	IOTimeoutLimit int           // timed out transfers after which a member is failed, 0 never fails it
+	Retry          RetryPolicy   // how member transfers failing with transient errors are retried
+
+	ProbePercent float64 // share of stripes checked for consistency on open, 0 disables
+
+	Failures FailureModel // simulated spontaneous failures of every member, for soak runs
+	Latency  LatencyModel // simulated drive latency of every member
+
+	SlowDisk SlowDiskPolicy // flag members slower than a threshold or than their peers
+
+	EventLog string // append every event to this file as a JSON line, "" disables
+
+	FillWarn     float64 // share of backing storage in use that logs a warning while overcommitted, 0 disables
+	FillCritical float64 // as FillWarn, for the critical level
+
+	Clock      Clock        // time source for background work, real time by default
+	RandSource rand.Source  // randomness for sampling, seeded from the clock by default
+	Logger     *slog.Logger // destination for operational messages, slog.Default() when nil
+	Tracer     Tracer       // receives spans for reads, writes and rebuilds, nil disables tracing
+
+	Placement PlacementPolicy // RAID5 parity placement, rotating by default
+}
+
+func NewRAIDArray(config RAIDConfig) (*RAIDArray, error) {
+	if len(config.DiskPaths) < 2 {
+		return nil, fmt.Errorf("RAID requires at least 2 disks")
+	}
+
+	if config.Level == RAID5 && len(config.DiskPaths) < 3 {
+		return nil, fmt.Errorf("RAID 5 requires at least 3 disks")
+	}
+
+	// two copies that disagree leave nothing to break the tie
+	if config.Level == RAID1 && config.VerifyReads && len(config.DiskPaths) < 3 {
+		return nil, fmt.Errorf("verified reads require at least 3 mirrors")
+	}
+
+	if config.BlockSize <= 0 {
+		return nil, fmt.Errorf("block size must be positive")
+	}
+
+	if config.BlocksPerDisk <= 0 {
+		return nil, fmt.Errorf("blocks per disk must be positive")
+	}
+
+	if config.ProbePercent < 0 || config.ProbePercent > 100 {
+		return nil, fmt.Errorf("probe percentage must be within [0, 100]")
+	}
+
+	if err := config.Failures.validate(); err != nil {
+		return nil, err
+	}
+
+	if err := config.Latency.validate(); err != nil {
+		return nil, err
+	}
+
+	if err := config.SlowDisk.validate(); err != nil {
+		return nil, err
+	}
+
+	if config.FillWarn < 0 || config.FillWarn > 1 || config.FillCritical < 0 || config.FillCritical > 1 {
+		return nil, fmt.Errorf("fill thresholds must be within [0, 1]")
+	}
+
+	if config.FillWarn > 0 && config.FillCritical > 0 && config.FillWarn > config.FillCritical {
+		return nil, fmt.Errorf("fill warning threshold above critical threshold")
+	}
+
+	if config.ReadCacheBlocks < 0 || config.StripeCacheSize < 0 {
+		return nil, fmt.Errorf("cache sizes must not be negative")
+	}
+
+	if err := validateReadRecovery(config.ReadRecovery); err != nil {
+		return nil, err
+	}
+
+	if config.SyncPolicy < SyncAlways || config.SyncPolicy > SyncOnFlush {
+		return nil, fmt.Errorf("unknown sync policy %d", config.SyncPolicy)
+	}
+
+	if config.SyncInterval < 0 {
+		return nil, fmt.Errorf("sync interval must not be negative")
+	}
+
+	if config.ReadPolicy < ReadFirst || config.ReadPolicy > ReadPreferred {
+		return nil, fmt.Errorf("unknown read policy %d", config.ReadPolicy)
+	}
+
+	if config.ReadPolicy == ReadPreferred && (config.PreferredDisk < 0 || config.PreferredDisk >= len(config.DiskPaths)) {
+		return nil, fmt.Errorf("preferred disk %d out of bounds [0, %d)", config.PreferredDisk, len(config.DiskPaths))
+	}
+
+	clock := config.Clock
+	if clock == nil {
+		clock = realClock{}
+	}
+
+	if config.Backends != nil && len(config.Backends) != len(config.DiskPaths) {
+		return nil, fmt.Errorf("got %d backends for %d disks", len(config.Backends), len(config.DiskPaths))
+	}
+
+	created := len(config.Backends) == 0
+	for _, path := range config.DiskPaths {
+		if _, err := os.Stat(path); err == nil {
+			created = false
+		}
+	}
+
+	var ring *ioRing
+	if config.IOUring {
+		var err error
+		if ring, err = newIORing(); err != nil {
+			return nil, err
+		}
+	}
+
+	disks := make([]*Disk, len(config.DiskPaths))
+	for i, path := range config.DiskPaths {
+		opts := DiskOptions{
+			RemapReserve: config.RemapReserve,
+			Prealloc:     config.Prealloc,
+			IOUring:      config.IOUring,
+			DirectIO:     config.DirectIO,
+			Mmap:         config.Mmap,
+			SyncPolicy:   config.SyncPolicy,
+			Timeout:      config.IOTimeout,
+			TimeoutLimit: config.IOTimeoutLimit,
+			Retry:        config.Retry,
+			ring:         ring,
+			clock:        clock,
+		}
+
+		var disk *Disk
+		var err error
+		if i < len(config.Backends) && config.Backends[i] != nil {
+			if config.Backends[i].BlockSize() != config.BlockSize {
+				err = fmt.Errorf("backend block size %d does not match %d", config.Backends[i].BlockSize(), config.BlockSize)
+			} else {
+				disk, err = NewDiskWithBackend(path, config.Backends[i], config.BlocksPerDisk, opts)
+			}
+		} else {
+			disk, err = NewDiskWithOptions(path, config.BlockSize, config.BlocksPerDisk, opts)
+		}
+		if err != nil {
+			for j := 0; j < i; j++ {
+				disks[j].Close()
+			}
+			if ring != nil {
+				ring.Close()
+			}
+			return nil, fmt.Errorf("failed to create disk %d: %w", i, err)
+		}
+		disks[i] = disk
+	}
+
+	r := &RAIDArray{
+		level:     config.Level,
+		disks:     disks,
+		blockSize: config.BlockSize,
+		numDisks:  len(disks),
+		done:      make(chan struct{}),
+		ring:      ring,
+		config:    config,
+		buffers:   newBufferPool(config.BlockSize, config.DirectIO),
+		clock:     clock,
+		rand:      newLockedRand(config.RandSource),
+		log:       config.Logger,
+		tracer:    config.Tracer,
+	}
+	if r.log == nil {
+		r.log = slog.Default()
+	}
+
+	r.SetRebuildRate(config.RebuildRate)
+
+	for i, disk := range disks {
+		disk.onFail = func() { r.diskFailed(i) }
+		disk.setFailureModel(config.Failures, r.rand)
+		disk.setLatencyModel(config.Latency, r.rand)
+	}
+
+	for i, disk := range disks {
+		if cp := disk.RebuildCheckpoint(); cp >= 0 {
+			r.log.Warn("disk has an interrupted rebuild, keeping it failed", "disk", i, "resume_stripe", cp)
+			disk.SetFailed(true)
+		}
+	}
+
+	if config.ReadCacheBlocks > 0 {
+		r.cache = newBlockCache(config.ReadCacheBlocks)
+	}
+
+	switch config.Level {
+	case RAID0:
+		r.capacity = config.BlocksPerDisk * len(disks)
+		r.raid0 = newRAID0(r)
+	case RAID1:
+		r.capacity = config.BlocksPerDisk
+		r.raid1 = newRAID1(r, config)
+	case RAID5:
+		placement := config.Placement
+		if placement == nil {
+			placement = rotatingParity{}
+		}
+		if err := validatePlacement(placement, len(disks), config.BlocksPerDisk); err != nil {
+			r.Close()
+			return nil, err
+		}
+		r.capacity = config.BlocksPerDisk * (len(disks) - 1)
+		r.raid5 = newRAID5(r, placement, config)
+	default:
+		r.Close()
+		return nil, fmt.Errorf("unsupported RAID level: %d", config.Level)
+	}
+
+	if config.ProbePercent > 0 {
+		if err := r.probe(config.ProbePercent); err != nil {
+			r.Close()
+			return nil, err
+		}
+	}
+
+	if config.SyncPolicy == SyncPeriodic {
+		interval := config.SyncInterval
+		if interval == 0 {
+			interval = defaultSyncInterval
+		}
+		go r.syncLoop(interval)
+	}
+
+	if config.FillWarn > 0 || config.FillCritical > 0 {
+		if _, err := r.FillLevel(); err != nil {
+			r.Close()
+			return nil, err
+		}
+		go r.fillLoop(config.FillWarn, config.FillCritical)
+	}
+
+	if config.SlowDisk.Threshold > 0 || config.SlowDisk.PeerFactor > 0 {
+		go r.slowDiskLoop(config.SlowDisk)
+	}
+
+	r.created = created
+	if err := r.openEventLog(config.EventLog); err != nil {
+		r.Close()
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *RAIDArray) Capacity() int {
+	return r.capacity
+}
+
+func (r *RAIDArray) Level() RAIDLevel {
+	return r.level
+}
+
+func (r *RAIDArray) WriteBlock(logicalBlockID int, data []byte) error {
+	return r.WriteBlockContext(context.Background(), logicalBlockID, data)
+}
+
+// WriteBlockContext is WriteBlock that gives up if ctx is done before the
+// write reaches the members. A write that has started updating members is
+// always finished, so redundancy is never left half-updated.
+func (r *RAIDArray) WriteBlockContext(ctx context.Context, logicalBlockID int, data []byte) (err error) {
+	ctx, span := r.startSpan(ctx, "raid.write", -1, logicalBlockID)
+	defer func() { endSpan(span, err) }()
+
+	if logicalBlockID < 0 || logicalBlockID >= r.capacity {
+		return outOfRange("logical block", logicalBlockID, r.capacity)
+	}
+
+	if len(data) != r.blockSize {
+		return fmt.Errorf("data size must match block size %d", r.blockSize)
+	}
+
+	if err := r.checkWritable(); err != nil {
+		return err
+	}
+
+	switch r.level {
+	case RAID0:
+		err = r.raid0.writeBlock(ctx, logicalBlockID, data)
+	case RAID1:
+		err = r.raid1.writeBlock(ctx, logicalBlockID, data)
+	case RAID5:
+		err = r.raid5.writeBlock(ctx, logicalBlockID, data)
+	default:
+		err = fmt.Errorf("unsupported RAID level: %d", r.level)
+	}
+
+	if r.cache != nil {
+		r.cache.invalidate(logicalBlockID)
+	}
+
+	r.noteWriteError(err)
+	return err
+}
+
+func (r *RAIDArray) ReadBlock(logicalBlockID int) ([]byte, error) {
+	return r.ReadBlockContext(context.Background(), logicalBlockID)
+}
+
+// ReadBlockContext is ReadBlock that stops issuing member reads once ctx
+// is done, which bounds degraded RAID 5 reads that touch every disk.
+func (r *RAIDArray) ReadBlockContext(ctx context.Context, logicalBlockID int) (data []byte, err error) {
+	ctx, span := r.startSpan(ctx, "raid.read", -1, logicalBlockID)
+	defer func() { endSpan(span, err) }()
+
+	if logicalBlockID < 0 || logicalBlockID >= r.capacity {
+		return nil, outOfRange("logical block", logicalBlockID, r.capacity)
+	}
+
+	var gen uint64
+	if r.cache != nil {
+		data, g, ok := r.cache.get(logicalBlockID)
+		if ok {
+			return data, nil
+		}
+		gen = g
+	}
+
+	switch r.level {
+	case RAID0:
+		data, err = r.raid0.readBlock(ctx, logicalBlockID)
+	case RAID1:
+		data, err = r.raid1.readBlock(ctx, logicalBlockID)
+	case RAID5:
+		data, err = r.raid5.readBlock(ctx, logicalBlockID)
+	default:
+		err = fmt.Errorf("unsupported RAID level: %d", r.level)
+	}
+
+	if err == nil && r.cache != nil {
+		r.cache.put(logicalBlockID, data, gen)
+	}
+	return data, err
+}
+
+// ReadBlockInto reads a logical block into buf, which must be exactly one
+// block long. Apart from filling the read cache, it does not allocate.
+func (r *RAIDArray) ReadBlockInto(logicalBlockID int, buf []byte) (err error) {
+	ctx, span := r.startSpan(context.Background(), "raid.read", -1, logicalBlockID)
+	defer func() { endSpan(span, err) }()
+
+	if logicalBlockID < 0 || logicalBlockID >= r.capacity {
+		return outOfRange("logical block", logicalBlockID, r.capacity)
+	}
+
+	if len(buf) != r.blockSize {
+		return fmt.Errorf("buffer size must match block size %d", r.blockSize)
+	}
+
+	var gen uint64
+	if r.cache != nil {
+		data, g, ok := r.cache.get(logicalBlockID)
+		if ok {
+			copy(buf, data)
+			return nil
+		}
+		gen = g
+	}
+
+	switch r.level {
+	case RAID0:
+		err = r.raid0.readBlockInto(ctx, logicalBlockID, buf)
+	case RAID1:
+		err = r.raid1.readBlockInto(ctx, logicalBlockID, buf)
+	case RAID5:
+		err = r.raid5.readBlockInto(ctx, logicalBlockID, buf)
+	default:
+		err = fmt.Errorf("unsupported RAID level: %d", r.level)
+	}
+
+	if err == nil && r.cache != nil {
+		r.cache.put(logicalBlockID, bytes.Clone(buf), gen)
+	}
+	return err
+}
+
+// RebuildDisk reconstructs a failed RAID 5 member. If ctx is cancelled the
+// disk stays failed and a later rebuild resumes where this one stopped.
+// Closing the array stops the rebuild the same way, with ErrClosed, and
+// waits for it to finish.
+func (r *RAIDArray) RebuildDisk(ctx context.Context, diskIndex int) (err error) {
+	ctx, span := r.startSpan(ctx, "raid.rebuild", diskIndex, -1)
+	defer func() { endSpan(span, err) }()
+
+	if r.level != RAID5 {
+		return fmt.Errorf("disk rebuild only supported for RAID 5")
+	}
+
+	// Close cannot start waiting between the check and Add while we hold
+	// the read lock
+	r.mu.RLock()
+	select {
+	case <-r.done:
+		r.mu.RUnlock()
+		return ErrClosed
+	default:
+	}
+	r.async.Add(1)
+	r.mu.RUnlock()
+	defer r.async.Done()
+
+	return r.raid5.rebuildDisk(ctx, diskIndex)
+}
+
+// SetParityInjector installs (or with nil removes) a hook on the RAID 5
+// parity engine. Scrub verification itself is never routed through it.
+func (r *RAIDArray) SetParityInjector(fn ParityInjector) error {
+	if r.level != RAID5 {
+		return fmt.Errorf("parity injection only supported for RAID 5")
+	}
+
+	r.raid5.mu.Lock()
+	r.raid5.injector = fn
+	r.raid5.mu.Unlock()
+	return nil
+}
+
+// FailDisk marks a member failed as if its hardware had died. Reads are
+// served from the remaining members and a RAID 5 member comes back through
+// RebuildDisk.
+func (r *RAIDArray) FailDisk(diskIndex int) error {
+	if diskIndex < 0 || diskIndex >= r.numDisks {
+		return fmt.Errorf("invalid disk index %d", diskIndex)
+	}
+	r.disks[diskIndex].SetFailed(true)
+	return nil
+}
+
+// MoveDisk relocates a healthy member to a new backing file without taking
+// it out of the array. The old image is kept and can be removed by the caller.
+func (r *RAIDArray) MoveDisk(diskIndex int, newPath string) error {
+	if diskIndex < 0 || diskIndex >= r.numDisks {
+		return fmt.Errorf("invalid disk index %d", diskIndex)
+	}
+
+	disk := r.disks[diskIndex]
+	if disk.IsFailed() {
+		return &RAIDError{Op: "move", Disk: diskIndex, Block: -1, Err: fmt.Errorf("%w, rebuild it instead", ErrDiskFailed)}
+	}
+
+	if err := disk.migrateTo(newPath); err != nil {
+		return fmt.Errorf("failed to move disk %d: %w", diskIndex, err)
+	}
+	return nil
+}
+
+func (r *RAIDArray) GetStats() []DiskStats {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	stats := make([]DiskStats, len(r.disks))
+	for i, disk := range r.disks {
+		stats[i] = disk.GetStats()
+	}
+	return stats
+}
+
+type ArrayStats struct {
+	CacheHits         uint64 `json:"cache_hits"`
+	CacheMisses       uint64 `json:"cache_misses"`
+	CacheBlocks       int    `json:"cache_blocks"`
+	StripeCacheHits   uint64 `json:"stripe_cache_hits"`
+	StripeCacheMisses uint64 `json:"stripe_cache_misses"`
+	StripeCacheSize   int    `json:"stripe_cache_size"`
+
+	RAID5Writes uint64      `json:"raid5_writes"` // read-modify-writes included in WritePhases
+	WritePhases WritePhases `json:"write_phases"` // cumulative time per phase
+
+	ReadRetries           uint64 `json:"read_retries"`
+	ReadRetryRecoveries   uint64 `json:"read_retry_recoveries"`
+	Reconstructions       uint64 `json:"reconstructions"`
+	ReconstructRecoveries uint64 `json:"reconstruct_recoveries"`
+	UnrecoverableReads    uint64 `json:"unrecoverable_reads"`
+	RewriteFailures       uint64 `json:"rewrite_failures"` // reconstructed blocks that could not be written back
+
+	// summed over the members
+	ReadErrors   uint64           `json:"read_errors"`
+	WriteErrors  uint64           `json:"write_errors"`
+	BytesRead    uint64           `json:"bytes_read"`
+	BytesWritten uint64           `json:"bytes_written"`
+	InFlight     int              `json:"in_flight"`
+	ReadLatency  LatencyHistogram `json:"read_latency"`
+	WriteLatency LatencyHistogram `json:"write_latency"`
+}
+
+func (r *RAIDArray) GetArrayStats() ArrayStats {
+	var st ArrayStats
+	if r.cache != nil {
+		st.CacheHits, st.CacheMisses, st.CacheBlocks = r.cache.stats()
+	}
+	if r.level == RAID5 {
+		r.raid5.mu.RLock()
+		if c := r.raid5.stripeCache; c != nil {
+			st.StripeCacheHits, st.StripeCacheMisses, _ = c.stats()
+			st.StripeCacheSize = c.capacity
+		}
+		r.raid5.mu.RUnlock()
+		st.RAID5Writes, st.WritePhases = r.raid5.phases.snapshot()
+
+		rs := &r.raid5.recovery
+		st.ReadRetries = rs.retries.Load()
+		st.ReadRetryRecoveries = rs.retryRecoveries.Load()
+		st.Reconstructions = rs.reconstructions.Load()
+		st.ReconstructRecoveries = rs.reconstructRecoveries.Load()
+		st.UnrecoverableReads = rs.unrecoverable.Load()
+		st.RewriteFailures = rs.rewriteFailures.Load()
+	}
+	for _, ds := range r.GetStats() {
+		st.ReadErrors += ds.ReadErrors
+		st.WriteErrors += ds.WriteErrors
+		st.BytesRead += ds.BytesRead
+		st.BytesWritten += ds.BytesWritten
+		st.InFlight += ds.InFlight
+		st.ReadLatency.merge(ds.ReadLatency)
+		st.WriteLatency.merge(ds.WriteLatency)
+	}
+	return st
+}
+
+// SetStripeCacheSize resizes the RAID 5 stripe cache, dropping its
+// contents. Zero disables it.
+func (r *RAIDArray) SetStripeCacheSize(stripes int) error {
+	if r.level != RAID5 {
+		return fmt.Errorf("stripe cache only supported for RAID 5")
+	}
+	if stripes < 0 {
+		return fmt.Errorf("stripe cache size must not be negative")
+	}
+
+	r.raid5.mu.Lock()
+	defer r.raid5.mu.Unlock()
+
+	r.raid5.stripeCache = nil
+	if stripes > 0 {
+		r.raid5.stripeCache = newBlockCache(stripes)
+	}
+	r.emit(Event{Type: EventConfigChanged, Disk: -1, Stripe: -1, Detail: fmt.Sprintf("stripe_cache_size %d", stripes)})
+	return nil
+}
+
+func (r *RAIDArray) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	select {
+	case <-r.done:
+	default:
+		close(r.done)
+	}
+	r.async.Wait()
+
+	firstError := r.flush()
+	for i, disk := range r.disks {
+		if err := disk.Close(); err != nil && firstError == nil {
+			firstError = fmt.Errorf("failed to close disk %d: %w", i, err)
+		}
+	}
+	if r.ring != nil {
+		r.ring.Close()
+	}
+	r.events.close()
+	if err := r.eventLog.close(); err != nil && firstError == nil {
+		firstError = fmt.Errorf("failed to close event log: %w", err)
+	}
+	return firstError
+}
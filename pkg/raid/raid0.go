@@ -0,0 +1,89 @@
+package raid
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+type raid0Impl struct {
+	array *RAIDArray
+	mu    sync.RWMutex
+	lost  atomic.Int64 // first member that failed, -1 while the array is whole
+}
+
+func newRAID0(array *RAIDArray) *raid0Impl {
+	r := &raid0Impl{array: array}
+	r.lost.Store(-1)
+	return r
+}
+
+// memberLost fails the whole array: without redundancy every file spread
+// across the stripes has lost part of its data, so blocks on the
+// surviving members are not served either. The array stays failed until
+// it is reassembled.
+func (r *raid0Impl) memberLost(diskIndex int) {
+	if r.lost.CompareAndSwap(-1, int64(diskIndex)) {
+		r.array.log.Error("RAID 0 member failed, the array has failed", "disk", diskIndex)
+	}
+}
+
+// checkLost returns the error for op once the array has failed.
+func (r *raid0Impl) checkLost(op string) error {
+	disk := int(r.lost.Load())
+	if disk < 0 {
+		return nil
+	}
+	return &RAIDError{Op: op, Disk: disk, Block: -1, Err: fmt.Errorf("%w: %w", ErrArrayFailed, ErrDiskFailed)}
+}
+
+func (r *raid0Impl) writeBlock(ctx context.Context, logicalBlockID int, data []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := r.checkLost("write"); err != nil {
+		return err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	diskIndex := logicalBlockID % r.array.numDisks
+	physicalBlockID := logicalBlockID / r.array.numDisks
+
+	if _, err := r.array.writeMember(ctx, diskIndex, physicalBlockID, data); err != nil {
+		return &RAIDError{Op: "write", Disk: diskIndex, Block: physicalBlockID, Err: err}
+	}
+	return nil
+}
+
+func (r *raid0Impl) readBlock(ctx context.Context, logicalBlockID int) ([]byte, error) {
+	data := make([]byte, r.array.blockSize)
+	if err := r.readBlockInto(ctx, logicalBlockID, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (r *raid0Impl) readBlockInto(ctx context.Context, logicalBlockID int, buf []byte) error {
+	if err := r.checkLost("read"); err != nil {
+		return err
+	}
+	return r.readBlockRaw(ctx, logicalBlockID, buf)
+}
+
+// readBlockRaw reads a block from its member even after the array has
+// failed, for Salvage.
+func (r *raid0Impl) readBlockRaw(ctx context.Context, logicalBlockID int, buf []byte) error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	diskIndex := logicalBlockID % r.array.numDisks
+	physicalBlockID := logicalBlockID / r.array.numDisks
+
+	if err := r.array.readMember(ctx, diskIndex, physicalBlockID, buf); err != nil {
+		return &RAIDError{Op: "read", Disk: diskIndex, Block: physicalBlockID, Err: err}
+	}
+	return nil
+}
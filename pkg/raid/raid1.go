@@ -0,0 +1,294 @@
+package raid
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+type raid1Impl struct {
+	array         *RAIDArray
+	verifyReads   bool
+	readPolicy    ReadPolicy
+	preferredDisk int
+	nextDisk      atomic.Uint64
+	mu            sync.RWMutex
+}
+
+type ReadPolicy int
+
+const (
+	ReadFirst            ReadPolicy = iota // lowest-numbered healthy disk
+	ReadRoundRobin                         // rotate across mirrors
+	ReadLeastOutstanding                   // disk with the fewest reads in flight
+	ReadLowestLatency                      // disk with the lowest observed read latency
+	ReadPreferred                          // RAIDConfig.PreferredDisk, others as fallback
+)
+
+type writeResult struct {
+	diskIndex int
+	err       error
+}
+
+func newRAID1(array *RAIDArray, config RAIDConfig) *raid1Impl {
+	return &raid1Impl{
+		array:         array,
+		verifyReads:   config.VerifyReads,
+		readPolicy:    config.ReadPolicy,
+		preferredDisk: config.PreferredDisk,
+	}
+}
+
+// writeBlock writes every mirror. ctx is only checked up front: once the
+// copies start diverging the write is always finished.
+func (r *raid1Impl) writeBlock(ctx context.Context, logicalBlockID int, data []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var wg sync.WaitGroup
+	resultChan := make(chan writeResult, r.array.numDisks)
+
+	for i := 0; i < r.array.numDisks; i++ {
+		wg.Add(1)
+		go func(diskIndex int) {
+			defer wg.Done()
+			var err error
+			if _, werr := r.array.writeMember(ctx, diskIndex, logicalBlockID, data); werr != nil {
+				err = &RAIDError{Op: "write", Disk: diskIndex, Block: logicalBlockID, Err: werr}
+			}
+			resultChan <- writeResult{diskIndex: diskIndex, err: err}
+		}(i)
+	}
+
+	wg.Wait()
+	close(resultChan)
+
+	successCount := 0
+	var lastErr error
+	failedDisks := make([]int, 0)
+
+	for result := range resultChan {
+		if result.err == nil {
+			successCount++
+		} else {
+			lastErr = result.err
+			failedDisks = append(failedDisks, result.diskIndex)
+		}
+	}
+
+	if successCount == 0 {
+		return fmt.Errorf("all disks failed to write: %w", lastErr)
+	}
+
+	if successCount < r.array.numDisks {
+		return fmt.Errorf("%w: write reached %d/%d disks, failed disks: %v: %w",
+			ErrArrayDegraded, successCount, r.array.numDisks, failedDisks, lastErr)
+	}
+
+	return nil
+}
+
+func (r *raid1Impl) readBlock(ctx context.Context, logicalBlockID int) ([]byte, error) {
+	if r.verifyReads {
+		return r.readVerified(ctx, logicalBlockID)
+	}
+
+	data := make([]byte, r.array.blockSize)
+	if err := r.readBlockInto(ctx, logicalBlockID, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (r *raid1Impl) readBlockInto(ctx context.Context, logicalBlockID int, buf []byte) error {
+	if r.verifyReads {
+		data, err := r.readVerified(ctx, logicalBlockID)
+		if err != nil {
+			return err
+		}
+		copy(buf, data)
+		return nil
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var lastErr error
+	for _, i := range r.readOrder() {
+		if r.array.disks[i].IsFailed() {
+			continue
+		}
+
+		err := r.array.readMember(ctx, i, logicalBlockID, buf)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return err
+		}
+		lastErr = &RAIDError{Op: "read", Disk: i, Block: logicalBlockID, Err: err}
+	}
+
+	return fmt.Errorf("failed to read from any disk: %w", lastErr)
+}
+
+// readOrder lists the mirrors in the order the read policy wants them
+// tried, write-mostly members last. Failed disks are filtered out by the
+// caller.
+func (r *raid1Impl) readOrder() []int {
+	n := r.array.numDisks
+	order := make([]int, n)
+	for i := range order {
+		order[i] = i
+	}
+
+	switch r.readPolicy {
+	case ReadRoundRobin:
+		start := int(r.nextDisk.Add(1) % uint64(n))
+		for i := range order {
+			order[i] = (start + i) % n
+		}
+	case ReadLeastOutstanding:
+		sort.SliceStable(order, func(a, b int) bool {
+			return r.array.disks[order[a]].metrics.inFlight.Load() < r.array.disks[order[b]].metrics.inFlight.Load()
+		})
+	case ReadLowestLatency:
+		sort.SliceStable(order, func(a, b int) bool {
+			return r.array.disks[order[a]].readLatency.Load() < r.array.disks[order[b]].readLatency.Load()
+		})
+	case ReadPreferred:
+		order[0], order[r.preferredDisk] = order[r.preferredDisk], order[0]
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return !r.array.disks[order[a]].writeMostly.Load() && r.array.disks[order[b]].writeMostly.Load()
+	})
+	return order
+}
+
+// readVerified reads every healthy copy and returns the one held by a
+// majority of mirrors, rewriting the copies that disagree. Writes are
+// excluded for the duration so an in-flight write is never mistaken for
+// corruption. Two readable copies that disagree have no majority, so with a
+// mirror down the mismatch is only reported.
+func (r *raid1Impl) readVerified(ctx context.Context, logicalBlockID int) ([]byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	copies := make([][]byte, r.array.numDisks)
+	healthy := 0
+	var lastErr error
+	for i := 0; i < r.array.numDisks; i++ {
+		if r.array.disks[i].IsFailed() {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		data := make([]byte, r.array.blockSize)
+		if err := r.array.readMember(ctx, i, logicalBlockID, data); err != nil {
+			lastErr = &RAIDError{Op: "read", Disk: i, Block: logicalBlockID, Err: err}
+			continue
+		}
+		copies[i] = data
+		healthy++
+	}
+
+	if healthy == 0 {
+		return nil, fmt.Errorf("failed to read from any disk: %w", lastErr)
+	}
+
+	winner := majority(copies, healthy)
+	if winner == nil {
+		return nil, fmt.Errorf("block %d: %w", logicalBlockID, errNoMajority)
+	}
+
+	for i, c := range copies {
+		if c == nil || bytes.Equal(c, winner) {
+			continue
+		}
+		if _, err := r.array.writeMember(ctx, i, logicalBlockID, winner); err != nil {
+			return nil, fmt.Errorf("failed to rewrite bad copy on disk %d: %w", i, err)
+		}
+	}
+
+	return winner, nil
+}
+
+// errNoMajority reports mirror copies that disagree with no majority to
+// tell which one is right, so there is nothing safe to repair from.
+var errNoMajority = errors.New("mirror copies disagree with no majority")
+
+// majority returns the copy held by more than half of the healthy mirrors,
+// or nil if there is none. Unreadable mirrors have nil copies.
+func majority(copies [][]byte, healthy int) []byte {
+	for _, c := range copies {
+		if c == nil {
+			continue
+		}
+		votes := 0
+		for _, other := range copies {
+			if other != nil && bytes.Equal(c, other) {
+				votes++
+			}
+		}
+		if votes*2 > healthy {
+			return c
+		}
+	}
+	return nil
+}
+
+// scrubStripe compares every healthy copy of a block. A repair rewrites the
+// copies that disagree with the majority, as readVerified does; without a
+// majority it fails with errNoMajority and changes nothing.
+func (r *raid1Impl) scrubStripe(ctx context.Context, blockID int, repair bool) (bool, bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	copies := make([][]byte, r.array.numDisks)
+	var ref []byte
+	mismatch := false
+	healthy := 0
+	for i := 0; i < r.array.numDisks; i++ {
+		if r.array.disks[i].IsFailed() {
+			continue
+		}
+		healthy++
+
+		data, err := r.array.disks[i].ReadBlock(blockID)
+		if err != nil {
+			return false, false, fmt.Errorf("failed to read disk %d: %w", i, err)
+		}
+		copies[i] = data
+		if ref == nil {
+			ref = data
+		} else if !bytes.Equal(ref, data) {
+			mismatch = true
+		}
+	}
+	if !mismatch || !repair {
+		return mismatch, healthy < 2, nil
+	}
+
+	winner := majority(copies, healthy)
+	if winner == nil {
+		return true, false, fmt.Errorf("block %d: %w", blockID, errNoMajority)
+	}
+	for i, c := range copies {
+		if c == nil || bytes.Equal(c, winner) {
+			continue
+		}
+		if _, err := r.array.writeMember(ctx, i, blockID, winner); err != nil {
+			return true, false, fmt.Errorf("failed to resync disk %d: %w", i, err)
+		}
+	}
+	return true, false, nil
+}
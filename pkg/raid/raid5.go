@@ -0,0 +1,698 @@
+package raid
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type raid5Impl struct {
+	array     *RAIDArray
+	placement PlacementPolicy
+	injector  ParityInjector
+
+	stripeCache *blockCache // whole stripes keyed by stripe number, nil when disabled
+
+	phases          phaseStats
+	slowOpThreshold time.Duration // log writes slower than this, 0 disables
+
+	readRecovery []ReadRecovery
+	recovery     recoveryStats
+
+	// mu is held shared by every stripe operation and exclusively by
+	// whole-array operations; stripeLocks then serialise operations on the
+	// same stripe.
+	mu          sync.RWMutex
+	stripeLocks [stripeLockCount]sync.Mutex
+
+	rebuildMu sync.Mutex // held for the duration of a rebuild
+	rebuild   rebuildCursor
+}
+
+// rebuildCursor tracks a rebuild running alongside foreground I/O. Stripes
+// below next are valid on the disk being rebuilt.
+type rebuildCursor struct {
+	disk    atomic.Int64 // member being rebuilt, -1 when idle
+	next    atomic.Int64 // first stripe not yet rebuilt
+	from    atomic.Int64 // stripe this run started at
+	started atomic.Int64 // array clock at the start of this run, in Unix nanoseconds
+}
+
+const stripeLockCount = 64
+
+type ParityOp int
+
+const (
+	ParityCompute     ParityOp = iota // parity computed for a write or rebuild
+	ParityReconstruct                 // data block rebuilt from parity
+)
+
+// ParityInjector sees every buffer the parity engine produces and may
+// modify it in place, letting tests simulate engine-level corruption.
+type ParityInjector func(op ParityOp, stripeNum int, buf []byte)
+
+func newRAID5(array *RAIDArray, placement PlacementPolicy, config RAIDConfig) *raid5Impl {
+	r := &raid5Impl{
+		array:           array,
+		placement:       placement,
+		slowOpThreshold: config.SlowOpThreshold,
+		readRecovery:    config.ReadRecovery,
+	}
+	r.rebuild.disk.Store(-1)
+	if r.readRecovery == nil {
+		r.readRecovery = defaultReadRecovery
+	}
+	if config.StripeCacheSize > 0 {
+		r.stripeCache = newBlockCache(config.StripeCacheSize)
+	}
+	return r
+}
+
+func (r *raid5Impl) lockStripe(stripeNum int) (unlock func()) {
+	r.mu.RLock()
+	l := &r.stripeLocks[stripeNum%stripeLockCount]
+	l.Lock()
+	return func() {
+		l.Unlock()
+		r.mu.RUnlock()
+	}
+}
+
+func (r *raid5Impl) inject(op ParityOp, stripeNum int, buf []byte) {
+	if r.injector != nil {
+		r.injector(op, stripeNum, buf)
+	}
+}
+
+func (r *raid5Impl) parityDisk(stripeNum int) int {
+	return r.placement.ParityDisk(stripeNum, r.array.numDisks)
+}
+
+// locate maps a logical block to its stripe, the disk holding its data and
+// the disk holding the stripe's parity.
+func (r *raid5Impl) locate(logicalBlockID int) (stripeNum, dataDisk, parityDisk int) {
+	stripeNum = logicalBlockID / (r.array.numDisks - 1)
+	parityDisk = r.parityDisk(stripeNum)
+
+	dataDisk = logicalBlockID % (r.array.numDisks - 1)
+	if dataDisk >= parityDisk {
+		dataDisk++
+	}
+	return stripeNum, dataDisk, parityDisk
+}
+
+// writeBlock updates a block and its parity. ctx is honoured during the
+// pre-reads; once parity is computed both writes are always issued.
+//
+// Parity normally comes from the new data and the stripe's other data
+// blocks. If one of those cannot be read, parity is instead updated from
+// its old value and the block being replaced, so it goes on covering the
+// missing block. If the target disk is the one missing, only parity is
+// written, and the new data lives on in it until the disk is rebuilt. With
+// more than one member of the stripe unavailable nothing is written.
+func (r *raid5Impl) writeBlock(ctx context.Context, logicalBlockID int, data []byte) error {
+	stripeNum, dataDisk, parityDisk := r.locate(logicalBlockID)
+	defer r.lockStripe(stripeNum)()
+
+	var phases WritePhases
+
+	bs := r.array.blockSize
+	stripe, cached := r.cachedStripe(stripeNum)
+	if !cached {
+		stripe = make([]byte, r.array.numDisks*bs)
+	}
+
+	parity := r.array.buffers.get()
+	defer r.array.buffers.put(parity)
+
+	lost, err := r.missingMember("write", stripeNum)
+	if err != nil {
+		return err
+	}
+	missing := -1
+	if !cached && lost != dataDisk && lost != parityDisk {
+		missing = lost
+	}
+
+	switch {
+	case cached:
+		copy(parity, data)
+		start := time.Now()
+		for diskIdx := 0; diskIdx < r.array.numDisks; diskIdx++ {
+			if diskIdx != dataDisk && diskIdx != parityDisk {
+				xorBytes(parity, stripe[diskIdx*bs:(diskIdx+1)*bs])
+			}
+		}
+		phases.XOR += time.Since(start)
+	case missing >= 0:
+		err = r.updateParity(ctx, stripeNum, dataDisk, parityDisk, missing, data, parity, &phases)
+	default:
+		err = r.computeParity(ctx, stripeNum, dataDisk, parityDisk, data, parity, stripe, &phases)
+	}
+	if err != nil {
+		return err
+	}
+	r.inject(ParityCompute, stripeNum, parity)
+
+	if !r.usable(parityDisk, stripeNum) {
+		r.markStale(parityDisk, stripeNum)
+	} else {
+		start := time.Now()
+		syncTime, err := r.array.writeMember(ctx, parityDisk, stripeNum, parity)
+		if err != nil {
+			r.uncacheStripe(stripeNum)
+			return &RAIDError{Op: "write parity", Disk: parityDisk, Block: stripeNum, Err: err}
+		}
+		phases.ParityWrite = time.Since(start) - syncTime
+		phases.Sync += syncTime
+	}
+
+	if !r.usable(dataDisk, stripeNum) {
+		r.markStale(dataDisk, stripeNum)
+	} else {
+		start := time.Now()
+		syncTime, err := r.array.writeMember(ctx, dataDisk, stripeNum, data)
+		if err != nil {
+			r.uncacheStripe(stripeNum)
+			return &RAIDError{Op: "write", Disk: dataDisk, Block: stripeNum, Err: err}
+		}
+		phases.DataWrite = time.Since(start) - syncTime
+		phases.Sync += syncTime
+	}
+	r.notePhases(logicalBlockID, phases)
+
+	// without the missing block stripe is incomplete, so it is not cached
+	if missing < 0 {
+		copy(stripe[dataDisk*bs:], data)
+		copy(stripe[parityDisk*bs:], parity)
+		r.cacheStripe(stripeNum, stripe)
+	}
+
+	return nil
+}
+
+// missingMember returns the member of the stripe that cannot be used, or -1
+// if there is none. Parity can cover only one, so with more than one
+// missing it fails with ErrMultipleFailures and the write must not go
+// ahead. Callers hold the stripe lock.
+func (r *raid5Impl) missingMember(op string, stripeNum int) (int, error) {
+	missing := -1
+	for diskIdx := 0; diskIdx < r.array.numDisks; diskIdx++ {
+		if r.usable(diskIdx, stripeNum) {
+			continue
+		}
+		if missing >= 0 {
+			return -1, &RAIDError{Op: op, Disk: missing, Block: stripeNum,
+				Err: fmt.Errorf("%w: disk %d is also unavailable", ErrMultipleFailures, diskIdx)}
+		}
+		missing = diskIdx
+	}
+	return missing, nil
+}
+
+// computeParity fills parity with the XOR of data and the stripe's other
+// data blocks, reading them into stripe.
+func (r *raid5Impl) computeParity(ctx context.Context, stripeNum, dataDisk, parityDisk int, data, parity, stripe []byte, phases *WritePhases) error {
+	bs := r.array.blockSize
+	copy(parity, data)
+
+	for diskIdx := 0; diskIdx < r.array.numDisks; diskIdx++ {
+		if diskIdx == dataDisk || diskIdx == parityDisk {
+			continue
+		}
+
+		blockData := stripe[diskIdx*bs : (diskIdx+1)*bs]
+		if r.array.disks[diskIdx].knownZero(stripeNum) {
+			clear(blockData) // XOR with zeros changes nothing, skip the pre-read
+			continue
+		}
+
+		start := time.Now()
+		err := r.array.readMember(ctx, diskIdx, stripeNum, blockData)
+		phases.PreRead += time.Since(start)
+		if err != nil {
+			return fmt.Errorf("cannot calculate parity: %w", &RAIDError{Op: "read", Disk: diskIdx, Block: stripeNum, Err: err})
+		}
+
+		start = time.Now()
+		xorBytes(parity, blockData)
+		phases.XOR += time.Since(start)
+	}
+	return nil
+}
+
+// updateParity fills parity with the old parity with the old block of
+// dataDisk replaced by data, for a stripe whose data disk missing cannot
+// be read. Both the old parity and the old block are needed; the caller
+// has checked that no other member is missing.
+func (r *raid5Impl) updateParity(ctx context.Context, stripeNum, dataDisk, parityDisk, missing int, data, parity []byte, phases *WritePhases) error {
+	old := r.array.buffers.get()
+	defer r.array.buffers.put(old)
+
+	for _, read := range []struct {
+		disk int
+		buf  []byte
+	}{{parityDisk, parity}, {dataDisk, old}} {
+		if read.disk == dataDisk && r.array.disks[dataDisk].knownZero(stripeNum) {
+			clear(old)
+			continue
+		}
+		start := time.Now()
+		err := r.array.readMember(ctx, read.disk, stripeNum, read.buf)
+		phases.PreRead += time.Since(start)
+		if err != nil {
+			return fmt.Errorf("cannot update parity: %w", &RAIDError{Op: "read", Disk: read.disk, Block: stripeNum, Err: err})
+		}
+	}
+
+	start := time.Now()
+	xorBytes(parity, old)
+	xorBytes(parity, data)
+	phases.XOR += time.Since(start)
+	return nil
+}
+
+// cachedStripe returns every member's block of the stripe, concatenated in
+// disk order, if the stripe cache holds it. Callers hold the stripe lock.
+func (r *raid5Impl) cachedStripe(stripeNum int) ([]byte, bool) {
+	if r.stripeCache == nil {
+		return nil, false
+	}
+	stripe, _, ok := r.stripeCache.get(stripeNum)
+	return stripe, ok
+}
+
+func (r *raid5Impl) cacheStripe(stripeNum int, stripe []byte) {
+	if r.stripeCache != nil {
+		r.stripeCache.set(stripeNum, stripe)
+	}
+}
+
+func (r *raid5Impl) uncacheStripe(stripeNum int) {
+	if r.stripeCache != nil {
+		r.stripeCache.invalidate(stripeNum)
+	}
+}
+
+// fullStripes finds the stripes a batch covers completely. For each it
+// returns the batch index of every data block in stripe order (the last
+// write to a block wins); all other batch indices are returned in rest.
+func (r *raid5Impl) fullStripes(blockIDs []int) (map[int][]int, []int) {
+	perStripe := r.array.numDisks - 1
+
+	latest := make(map[int]int, len(blockIDs))
+	for i, id := range blockIDs {
+		latest[id] = i
+	}
+
+	full := make(map[int][]int)
+	for id := range latest {
+		stripe := id / perStripe
+		if _, seen := full[stripe]; seen {
+			continue
+		}
+
+		idx := make([]int, perStripe)
+		complete := true
+		for off := 0; off < perStripe; off++ {
+			i, ok := latest[stripe*perStripe+off]
+			if !ok {
+				complete = false
+				break
+			}
+			idx[off] = i
+		}
+		if complete {
+			full[stripe] = idx
+		}
+	}
+
+	var rest []int
+	for i, id := range blockIDs {
+		if _, ok := full[id/perStripe]; !ok {
+			rest = append(rest, i)
+		}
+	}
+	return full, rest
+}
+
+// writeStripe replaces a whole stripe. Parity comes straight from the new
+// data, so no pre-reads are needed. The degraded rules are writeBlock's: a
+// single missing member is skipped, its block living on in parity (or, for
+// the parity disk, needing none), and with more than one nothing is written.
+func (r *raid5Impl) writeStripe(ctx context.Context, stripeNum int, blocks [][]byte) error {
+	defer r.lockStripe(stripeNum)()
+
+	missing, err := r.missingMember("write stripe", stripeNum)
+	if err != nil {
+		return err
+	}
+
+	parityDisk := r.parityDisk(stripeNum)
+	parity := make([]byte, r.array.blockSize)
+	for _, b := range blocks {
+		xorBytes(parity, b)
+	}
+	r.inject(ParityCompute, stripeNum, parity)
+
+	var wg sync.WaitGroup
+	resultChan := make(chan writeResult, r.array.numDisks)
+	for diskIdx := 0; diskIdx < r.array.numDisks; diskIdx++ {
+		if diskIdx == missing {
+			r.markStale(diskIdx, stripeNum)
+			continue
+		}
+
+		payload := parity
+		if diskIdx != parityDisk {
+			off := diskIdx
+			if off > parityDisk {
+				off--
+			}
+			payload = blocks[off]
+		}
+
+		wg.Add(1)
+		go func(diskIdx int, payload []byte) {
+			defer wg.Done()
+			_, err := r.array.writeMember(ctx, diskIdx, stripeNum, payload)
+			resultChan <- writeResult{diskIndex: diskIdx, err: err}
+		}(diskIdx, payload)
+	}
+
+	wg.Wait()
+	close(resultChan)
+
+	for result := range resultChan {
+		if result.err != nil {
+			r.uncacheStripe(stripeNum)
+			return &RAIDError{Op: "write stripe", Disk: result.diskIndex, Block: stripeNum, Err: result.err}
+		}
+	}
+
+	if r.stripeCache != nil {
+		bs := r.array.blockSize
+		stripe := make([]byte, r.array.numDisks*bs)
+		copy(stripe[parityDisk*bs:], parity)
+		for off, b := range blocks {
+			diskIdx := off
+			if diskIdx >= parityDisk {
+				diskIdx++
+			}
+			copy(stripe[diskIdx*bs:], b)
+		}
+		r.cacheStripe(stripeNum, stripe)
+	}
+	return nil
+}
+
+func (r *raid5Impl) readBlock(ctx context.Context, logicalBlockID int) ([]byte, error) {
+	data := make([]byte, r.array.blockSize)
+	if err := r.readBlockInto(ctx, logicalBlockID, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// readBlockInto reads a logical block into buf. Degraded reads reconstruct
+// straight into buf as well.
+func (r *raid5Impl) readBlockInto(ctx context.Context, logicalBlockID int, buf []byte) error {
+	stripeNum, dataDisk, parityDisk := r.locate(logicalBlockID)
+	defer r.lockStripe(stripeNum)()
+
+	if stripe, ok := r.cachedStripe(stripeNum); ok {
+		bs := r.array.blockSize
+		copy(buf, stripe[dataDisk*bs:(dataDisk+1)*bs])
+		return nil
+	}
+
+	var err error = &RAIDError{Op: "read", Disk: dataDisk, Block: stripeNum, Err: ErrDiskFailed}
+	if r.usable(dataDisk, stripeNum) {
+		if err = r.array.readMember(ctx, dataDisk, stripeNum, buf); err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return err
+		}
+		err = &RAIDError{Op: "read", Disk: dataDisk, Block: stripeNum, Err: err}
+	}
+
+	return r.recoverRead(ctx, logicalBlockID, stripeNum, dataDisk, parityDisk, buf, err)
+}
+
+// reconstructBlock rebuilds missingDisk's block of the stripe into dst,
+// giving up between member reads once ctx is done.
+func (r *raid5Impl) reconstructBlock(ctx context.Context, dst []byte, stripeNum, missingDisk, parityDisk int) error {
+	if !r.usable(parityDisk, stripeNum) {
+		return &RAIDError{Op: "reconstruct", Disk: parityDisk, Block: stripeNum, Err: fmt.Errorf("%w: parity disk is failed", ErrMultipleFailures)}
+	}
+
+	if err := r.array.readMember(ctx, parityDisk, stripeNum, dst); err != nil {
+		return &RAIDError{Op: "reconstruct", Disk: parityDisk, Block: stripeNum, Err: err}
+	}
+
+	blockData := r.array.buffers.get()
+	defer r.array.buffers.put(blockData)
+
+	for i := 0; i < r.array.numDisks; i++ {
+		if i == parityDisk || i == missingDisk {
+			continue
+		}
+
+		if !r.usable(i, stripeNum) {
+			return &RAIDError{Op: "reconstruct", Disk: i, Block: stripeNum, Err: ErrMultipleFailures}
+		}
+		if r.array.disks[i].knownZero(stripeNum) {
+			continue
+		}
+
+		if err := r.array.readMember(ctx, i, stripeNum, blockData); err != nil {
+			return &RAIDError{Op: "reconstruct", Disk: i, Block: stripeNum, Err: err}
+		}
+
+		xorBytes(dst, blockData)
+	}
+	r.inject(ParityReconstruct, stripeNum, dst)
+
+	return nil
+}
+
+func (r *raid5Impl) rebuildDisk(ctx context.Context, diskIndex int) error {
+	if diskIndex < 0 || diskIndex >= r.array.numDisks {
+		return fmt.Errorf("invalid disk index %d", diskIndex)
+	}
+
+	if !r.rebuildMu.TryLock() {
+		return fmt.Errorf("a rebuild is already running")
+	}
+	defer r.rebuildMu.Unlock()
+
+	disk := r.array.disks[diskIndex]
+
+	// no stripe operation may run while the disk comes back, or a write
+	// that skipped it could land behind the starting stripe
+	r.mu.Lock()
+	if !disk.IsFailed() {
+		r.mu.Unlock()
+		return fmt.Errorf("disk %d is not marked as failed", diskIndex)
+	}
+	start := max(disk.RebuildCheckpoint(), 0)
+
+	// mark the image incomplete before touching it, so a crash mid-rebuild
+	// does not leave it looking healthy
+	if err := disk.setRebuildCheckpoint(start); err != nil {
+		r.mu.Unlock()
+		return err
+	}
+	r.rebuild.next.Store(int64(start))
+	r.rebuild.from.Store(int64(start))
+	r.rebuild.started.Store(r.array.clock.Now().UnixNano())
+	r.rebuild.disk.Store(int64(diskIndex))
+	disk.SetFailed(false)
+	r.mu.Unlock()
+	defer r.rebuild.disk.Store(-1)
+
+	r.array.emit(Event{Type: EventRebuildStarted, Disk: diskIndex, Stripe: start})
+	if start > 0 {
+		r.array.log.Info("resuming rebuild", "disk", diskIndex, "stripe", start)
+	} else {
+		r.array.log.Info("starting rebuild", "disk", diskIndex)
+	}
+
+	fail := func(stripeNum int, err error) error {
+		disk.SetFailed(true)
+		if cpErr := disk.setRebuildCheckpoint(stripeNum); cpErr != nil {
+			err = fmt.Errorf("%w (checkpoint not saved: %v)", err, cpErr)
+		}
+		r.array.emit(Event{Type: EventRebuildFailed, Disk: diskIndex, Stripe: stripeNum, Err: err})
+		return err
+	}
+
+	maxStripes := disk.Capacity()
+
+	reconstructed := r.array.buffers.get()
+	defer r.array.buffers.put(reconstructed)
+
+	pace := newPacer(&r.array.rebuildRate, r.array.clock)
+	defer pace.stop()
+
+	rebuiltBlocks := 0
+	lastReport := r.array.clock.Now()
+	for stripeNum := start; stripeNum < maxStripes; stripeNum++ {
+		if err := pace.wait(ctx, r.array.blockSize, r.array.done); err != nil {
+			r.array.log.Warn("rebuild cancelled", "disk", diskIndex, "stripe", stripeNum)
+			return fail(stripeNum, fmt.Errorf("rebuild cancelled at stripe %d: %w", stripeNum, err))
+		}
+
+		if err := r.rebuildStripe(ctx, stripeNum, diskIndex, reconstructed); err != nil {
+			return fail(stripeNum, err)
+		}
+		rebuiltBlocks++
+
+		if (stripeNum+1)%rebuildCheckpointInterval == 0 {
+			if err := disk.setRebuildCheckpoint(stripeNum + 1); err != nil {
+				return fail(stripeNum+1, err)
+			}
+		}
+
+		if now := r.array.clock.Now(); now.Sub(lastReport) >= rebuildProgressInterval {
+			lastReport = now
+			if p := r.progress(); p != nil {
+				r.array.log.Info("rebuild progress", "disk", diskIndex, "stripe", p.Stripe, "stripes", maxStripes,
+					"blocks_per_sec", int(p.BlocksPerSec), "eta", p.ETA)
+				r.array.emit(Event{Type: EventRebuildProgress, Disk: diskIndex, Stripe: p.Stripe, Detail: p.String()})
+			}
+		}
+	}
+
+	if err := disk.setRebuildCheckpoint(-1); err != nil {
+		return err
+	}
+
+	r.array.log.Info("rebuild completed", "disk", diskIndex, "blocks", rebuiltBlocks)
+	r.array.emit(Event{Type: EventRebuildCompleted, Disk: diskIndex, Stripe: -1})
+	return nil
+}
+
+// rebuildStripe restores diskIndex's block of one stripe under the stripe
+// lock, then moves the rebuild cursor past it so foreground I/O uses the
+// disk for this stripe from now on.
+func (r *raid5Impl) rebuildStripe(ctx context.Context, stripeNum, diskIndex int, buf []byte) error {
+	defer r.lockStripe(stripeNum)()
+
+	parityDisk := r.parityDisk(stripeNum)
+	if diskIndex == parityDisk {
+		if err := r.rebuildParityBlock(ctx, stripeNum, diskIndex); err != nil {
+			return fmt.Errorf("rebuild failed at stripe %d: %w", stripeNum, err)
+		}
+	} else {
+		if err := r.reconstructBlock(ctx, buf, stripeNum, diskIndex, parityDisk); err != nil {
+			return fmt.Errorf("rebuild failed reconstructing stripe %d: %w", stripeNum, err)
+		}
+		if _, err := r.array.writeMember(ctx, diskIndex, stripeNum, buf); err != nil {
+			return fmt.Errorf("rebuild failed writing stripe %d: %w", stripeNum, err)
+		}
+	}
+
+	r.rebuild.next.Store(int64(stripeNum + 1))
+	return nil
+}
+
+// usable reports whether diskIndex holds valid data for the stripe: it has
+// not failed and, while it is being rebuilt, the rebuild has passed the
+// stripe. Callers hold the stripe lock, which keeps the answer stable.
+func (r *raid5Impl) usable(diskIndex, stripeNum int) bool {
+	if r.rebuild.disk.Load() == int64(diskIndex) && int64(stripeNum) >= r.rebuild.next.Load() {
+		return false
+	}
+	return !r.array.disks[diskIndex].IsFailed()
+}
+
+// markStale notes that a write skipped a failed member, so an interrupted
+// rebuild of that member must redo the stripe.
+func (r *raid5Impl) markStale(diskIndex, stripeNum int) {
+	if err := r.array.disks[diskIndex].lowerRebuildCheckpoint(stripeNum); err != nil {
+		r.array.log.Error("failed to update rebuild checkpoint", "disk", diskIndex, "err", err)
+	}
+}
+
+func (r *raid5Impl) rebuildParityBlock(ctx context.Context, stripeNum, parityDisk int) error {
+	parity, blockData := r.array.buffers.get(), r.array.buffers.get()
+	defer r.array.buffers.put(parity)
+	defer r.array.buffers.put(blockData)
+	clear(parity)
+
+	for i := 0; i < r.array.numDisks; i++ {
+		if i == parityDisk {
+			continue
+		}
+
+		if err := r.array.disks[i].ReadBlockInto(stripeNum, blockData); err != nil {
+			return fmt.Errorf("failed to read disk %d: %w", i, err)
+		}
+
+		xorBytes(parity, blockData)
+	}
+	r.inject(ParityCompute, stripeNum, parity)
+
+	_, err := r.array.writeMember(ctx, parityDisk, stripeNum, parity)
+	return err
+}
+
+func (r *raid5Impl) scrubStripe(ctx context.Context, stripeNum int, repair bool) (bool, bool, error) {
+	defer r.lockStripe(stripeNum)()
+
+	parityDisk := r.parityDisk(stripeNum)
+
+	for i := 0; i < r.array.numDisks; i++ {
+		if !r.usable(i, stripeNum) {
+			return false, true, nil
+		}
+	}
+
+	parity, stored, blockData := r.array.buffers.get(), r.array.buffers.get(), r.array.buffers.get()
+	defer r.array.buffers.put(parity)
+	defer r.array.buffers.put(stored)
+	defer r.array.buffers.put(blockData)
+	clear(parity)
+
+	for i := 0; i < r.array.numDisks; i++ {
+		dst := blockData
+		if i == parityDisk {
+			dst = stored
+		}
+		if err := r.array.disks[i].ReadBlockInto(stripeNum, dst); err != nil {
+			return false, false, fmt.Errorf("failed to read disk %d: %w", i, err)
+		}
+		if i != parityDisk {
+			xorBytes(parity, blockData)
+		}
+	}
+
+	if bytes.Equal(parity, stored) {
+		return false, false, nil
+	}
+
+	if repair {
+		r.uncacheStripe(stripeNum)
+		if _, err := r.array.writeMember(ctx, parityDisk, stripeNum, parity); err != nil {
+			return true, false, fmt.Errorf("failed to rewrite parity on disk %d: %w", parityDisk, err)
+		}
+	}
+	return true, false, nil
+}
+
+// xorBytes XORs src into dst over the shorter of the two. crypto/subtle
+// picks a vectorised implementation for the running CPU and falls back to
+// word-at-a-time XOR elsewhere.
+func xorBytes(dst, src []byte) {
+	n := len(dst)
+	if len(src) < n {
+		n = len(src)
+	}
+	subtle.XORBytes(dst[:n], dst[:n], src[:n])
+}
@@ -0,0 +1,96 @@
+package raid
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"syscall"
+)
+
+// ReadRecovery is one step RAID 5 tries, in order, after a member read
+// fails.
+type ReadRecovery int
+
+const (
+	RecoverRetry       ReadRecovery = iota // read the same member once more
+	RecoverReconstruct                     // rebuild the block from the other members and parity
+)
+
+var defaultReadRecovery = []ReadRecovery{RecoverRetry, RecoverReconstruct}
+
+type recoveryStats struct {
+	retries               atomic.Uint64
+	retryRecoveries       atomic.Uint64
+	reconstructions       atomic.Uint64
+	reconstructRecoveries atomic.Uint64
+	unrecoverable         atomic.Uint64
+	rewriteFailures       atomic.Uint64
+}
+
+func (s *recoveryStats) reset() {
+	s.retries.Store(0)
+	s.retryRecoveries.Store(0)
+	s.reconstructions.Store(0)
+	s.reconstructRecoveries.Store(0)
+	s.unrecoverable.Store(0)
+	s.rewriteFailures.Store(0)
+}
+
+func validateReadRecovery(steps []ReadRecovery) error {
+	for _, s := range steps {
+		if s < RecoverRetry || s > RecoverReconstruct {
+			return fmt.Errorf("unknown read recovery step %d", s)
+		}
+	}
+	return nil
+}
+
+// recoverRead walks the recovery chain after a failed read of dataDisk's
+// block into data. Callers hold the stripe lock.
+func (r *raid5Impl) recoverRead(ctx context.Context, logicalBlockID, stripeNum, dataDisk, parityDisk int, data []byte, err error) error {
+	for _, step := range r.readRecovery {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr // a cancelled read is not unrecoverable
+		}
+
+		switch step {
+		case RecoverRetry:
+			if !r.usable(dataDisk, stripeNum) {
+				continue
+			}
+			r.recovery.retries.Add(1)
+			if err = r.array.readMember(ctx, dataDisk, stripeNum, data); err == nil {
+				r.recovery.retryRecoveries.Add(1)
+				return nil
+			}
+			err = &RAIDError{Op: "read", Disk: dataDisk, Block: stripeNum, Err: err}
+
+		case RecoverReconstruct:
+			r.array.log.Debug("degraded read, reconstructing from parity", "block", logicalBlockID)
+			r.recovery.reconstructions.Add(1)
+			if err = r.reconstructBlock(ctx, data, stripeNum, dataDisk, parityDisk); err != nil {
+				continue
+			}
+			r.recovery.reconstructRecoveries.Add(1)
+
+			// a healthy disk that failed the read has remapped the block; rewrite
+			// it. The read has succeeded either way. A member that can neither
+			// read nor rewrite the block is failed, so a rebuild restores it;
+			// out of space, writes are paused array-wide instead.
+			if r.usable(dataDisk, stripeNum) {
+				if _, werr := r.array.writeMember(ctx, dataDisk, stripeNum, data); werr != nil {
+					r.recovery.rewriteFailures.Add(1)
+					r.array.log.Error("failed to rewrite reconstructed block", "disk", dataDisk, "stripe", stripeNum, "err", werr)
+					if !errors.Is(werr, syscall.ENOSPC) {
+						r.array.disks[dataDisk].SetFailed(true)
+					}
+				}
+			}
+			return nil
+		}
+	}
+
+	r.recovery.unrecoverable.Add(1)
+	return err
+}
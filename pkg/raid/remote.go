@@ -0,0 +1,306 @@
+package raid
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// Wire protocol between RemoteBackend and ServeBackend. A request is an
+// op byte, a big-endian uint64 block and a uint32 payload length followed
+// by the payload. A response is a status byte and a uint32 length followed
+// by the payload: the block for reads, the geometry for info, or the error
+// text when the status is not ok.
+const (
+	opRead byte = iota + 1
+	opWrite
+	opSync
+	opInfo
+	opPing
+)
+
+const (
+	statusOK byte = iota
+	statusErr
+)
+
+// maxRemotePayload bounds the length field so a corrupt frame cannot make
+// either side allocate without limit.
+const maxRemotePayload = 64 << 20
+
+type RemoteOptions struct {
+	DialTimeout time.Duration // per connection attempt, 5s by default
+	IOTimeout   time.Duration // per request, 10s by default
+	Retries     int           // reconnects before the connection counts as lost, 3 by default
+	RetryDelay  time.Duration // pause before each reconnect, 200ms by default
+}
+
+// RemoteBackend keeps a disk image on a ServeBackend server. A request that
+// fails in transit is retried over a new connection; once the retries are
+// used up the error wraps ErrDiskFailed and the disk is marked failed.
+type RemoteBackend struct {
+	addr string
+	opts RemoteOptions
+
+	mu   sync.Mutex // one request in flight on conn
+	conn net.Conn
+	rw   *bufio.ReadWriter
+
+	blockSize int
+	capacity  int
+}
+
+var _ Backend = (*RemoteBackend)(nil)
+
+// remoteError is an error reported by the server, as opposed to a transport
+// failure. It is returned as is, without reconnecting.
+type remoteError string
+
+func (e remoteError) Error() string { return string(e) }
+
+// DialBackend connects to the server at addr and reads its geometry.
+func DialBackend(addr string, opts RemoteOptions) (*RemoteBackend, error) {
+	if opts.DialTimeout <= 0 {
+		opts.DialTimeout = 5 * time.Second
+	}
+	if opts.IOTimeout <= 0 {
+		opts.IOTimeout = 10 * time.Second
+	}
+	if opts.Retries <= 0 {
+		opts.Retries = 3
+	}
+	if opts.RetryDelay <= 0 {
+		opts.RetryDelay = 200 * time.Millisecond
+	}
+
+	b := &RemoteBackend{addr: addr, opts: opts}
+	info, err := b.call(opInfo, 0, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(info) != 12 {
+		b.Close()
+		return nil, fmt.Errorf("bad info reply from %s", addr)
+	}
+	b.blockSize = int(binary.BigEndian.Uint32(info[0:4]))
+	b.capacity = int(binary.BigEndian.Uint64(info[4:12]))
+	return b, nil
+}
+
+func (b *RemoteBackend) ReadBlock(block int, buf []byte) error {
+	data, err := b.call(opRead, block, nil)
+	if err != nil {
+		return err
+	}
+	if len(data) != len(buf) {
+		return fmt.Errorf("%w: read from %s expected %d bytes, got %d", ErrShortIO, b.addr, len(buf), len(data))
+	}
+	copy(buf, data)
+	return nil
+}
+
+func (b *RemoteBackend) WriteBlock(block int, data []byte) error {
+	_, err := b.call(opWrite, block, data)
+	return err
+}
+
+func (b *RemoteBackend) Capacity() int {
+	return b.capacity
+}
+
+func (b *RemoteBackend) BlockSize() int {
+	return b.blockSize
+}
+
+func (b *RemoteBackend) Sync() error {
+	_, err := b.call(opSync, 0, nil)
+	return err
+}
+
+// Health pings the server, reconnecting if needed.
+func (b *RemoteBackend) Health() error {
+	_, err := b.call(opPing, 0, nil)
+	return err
+}
+
+func (b *RemoteBackend) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.conn == nil {
+		return nil
+	}
+	err := b.conn.Close()
+	b.conn = nil
+	return err
+}
+
+// call runs one request, reconnecting and retrying on transport errors.
+func (b *RemoteBackend) call(op byte, block int, payload []byte) ([]byte, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var err error
+	for attempt := 0; attempt <= b.opts.Retries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(b.opts.RetryDelay)
+		}
+		if b.conn == nil {
+			if err = b.connect(); err != nil {
+				continue
+			}
+		}
+
+		var reply []byte
+		reply, err = b.roundTrip(op, block, payload)
+		var remote remoteError
+		if err == nil || errors.As(err, &remote) {
+			return reply, err
+		}
+		b.conn.Close()
+		b.conn = nil
+	}
+	return nil, fmt.Errorf("%w: lost connection to %s: %w", ErrDiskFailed, b.addr, err)
+}
+
+func (b *RemoteBackend) connect() error {
+	conn, err := net.DialTimeout("tcp", b.addr, b.opts.DialTimeout)
+	if err != nil {
+		return err
+	}
+	b.conn = conn
+	b.rw = bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn))
+	return nil
+}
+
+func (b *RemoteBackend) roundTrip(op byte, block int, payload []byte) ([]byte, error) {
+	b.conn.SetDeadline(time.Now().Add(b.opts.IOTimeout))
+
+	if err := writeFrame(b.rw.Writer, op, uint64(block), payload); err != nil {
+		return nil, err
+	}
+	if err := b.rw.Flush(); err != nil {
+		return nil, err
+	}
+
+	var hdr [5]byte
+	if _, err := io.ReadFull(b.rw, hdr[:]); err != nil {
+		return nil, err
+	}
+	reply, err := readPayload(b.rw, binary.BigEndian.Uint32(hdr[1:]))
+	if err != nil {
+		return nil, err
+	}
+	if hdr[0] != statusOK {
+		return nil, remoteError(fmt.Sprintf("%s: %s", b.addr, reply))
+	}
+	return reply, nil
+}
+
+func writeFrame(w *bufio.Writer, op byte, block uint64, payload []byte) error {
+	var hdr [13]byte
+	hdr[0] = op
+	binary.BigEndian.PutUint64(hdr[1:9], block)
+	binary.BigEndian.PutUint32(hdr[9:13], uint32(len(payload)))
+	if _, err := w.Write(hdr[:]); err != nil {
+		return err
+	}
+	_, err := w.Write(payload)
+	return err
+}
+
+func readPayload(r io.Reader, n uint32) ([]byte, error) {
+	if n > maxRemotePayload {
+		return nil, fmt.Errorf("payload of %d bytes exceeds limit", n)
+	}
+	buf := make([]byte, n)
+	_, err := io.ReadFull(r, buf)
+	return buf, err
+}
+
+// ServeBackend answers RemoteBackend requests for b on connections accepted
+// from ln until ln is closed. Writes and syncs from different connections
+// are serialised.
+func ServeBackend(ln net.Listener, b Backend) error {
+	var mu sync.RWMutex
+	for {
+		conn, err := ln.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		go serveConn(conn, b, &mu)
+	}
+}
+
+func serveConn(conn net.Conn, b Backend, mu *sync.RWMutex) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+
+	for {
+		var hdr [13]byte
+		if _, err := io.ReadFull(r, hdr[:]); err != nil {
+			return
+		}
+		block := int(binary.BigEndian.Uint64(hdr[1:9]))
+		payload, err := readPayload(r, binary.BigEndian.Uint32(hdr[9:13]))
+		if err != nil {
+			return
+		}
+
+		reply, err := handleRequest(b, mu, hdr[0], block, payload)
+		status := statusOK
+		if err != nil {
+			status, reply = statusErr, []byte(err.Error())
+		}
+		var out [5]byte
+		out[0] = status
+		binary.BigEndian.PutUint32(out[1:], uint32(len(reply)))
+		w.Write(out[:])
+		w.Write(reply)
+		if err := w.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func handleRequest(b Backend, mu *sync.RWMutex, op byte, block int, payload []byte) ([]byte, error) {
+	if (op == opRead || op == opWrite) && (block < 0 || block >= b.Capacity()) {
+		return nil, outOfRange("block", block, b.Capacity())
+	}
+
+	switch op {
+	case opRead:
+		buf := make([]byte, b.BlockSize())
+		mu.RLock()
+		defer mu.RUnlock()
+		return buf, b.ReadBlock(block, buf)
+	case opWrite:
+		if len(payload) != b.BlockSize() {
+			return nil, fmt.Errorf("data size %d does not match block size %d", len(payload), b.BlockSize())
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		return nil, b.WriteBlock(block, payload)
+	case opSync:
+		mu.Lock()
+		defer mu.Unlock()
+		return nil, b.Sync()
+	case opInfo:
+		info := make([]byte, 12)
+		binary.BigEndian.PutUint32(info[0:4], uint32(b.BlockSize()))
+		binary.BigEndian.PutUint64(info[4:12], uint64(b.Capacity()))
+		return info, nil
+	case opPing:
+		return nil, b.Health()
+	default:
+		return nil, fmt.Errorf("unknown op %d", op)
+	}
+}
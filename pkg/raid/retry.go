@@ -0,0 +1,61 @@
+package raid
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy retries member transfers that failed with an error that may
+// go away, such as a timeout or a dropped connection, before the error
+// reaches the array. Failed disks, unreadable sectors and full storage are
+// never retried. The zero value does not retry.
+type RetryPolicy struct {
+	Attempts   int           `json:"attempts,omitempty"`    // retries after the first failure, 0 disables
+	Backoff    time.Duration `json:"backoff,omitempty"`     // wait before the first retry, doubled for each one after
+	MaxBackoff time.Duration `json:"max_backoff,omitempty"` // longest wait between retries, 0 for no limit
+}
+
+func (p RetryPolicy) validate() error {
+	if p.Attempts < 0 || p.Backoff < 0 || p.MaxBackoff < 0 {
+		return fmt.Errorf("invalid retry policy %+v", p)
+	}
+	return nil
+}
+
+// transient reports whether a transfer that failed with err may succeed
+// if tried again.
+func transient(err error) bool {
+	return !errors.Is(err, ErrDiskFailed) &&
+		!errors.Is(err, errMediaError) &&
+		!errors.Is(err, ErrBlockOutOfRange) &&
+		!errors.Is(err, syscall.ENOSPC)
+}
+
+// withRetries runs a transfer, retrying it under the disk's retry policy
+// and counting each retry in retries. Callers hold d.mu through held, which
+// is released while waiting out the backoff on the disk's clock, so other
+// transfers are not stalled behind it. A disk failed in the meantime is
+// not tried again.
+func (d *Disk) withRetries(held sync.Locker, retries *counter, transfer func() error) error {
+	err := transfer()
+	backoff := d.retry.Backoff
+	for attempt := 0; err != nil && attempt < d.retry.Attempts && transient(err); attempt++ {
+		held.Unlock()
+		sleep(d.clock, backoff)
+		held.Lock()
+		if d.failed {
+			return fmt.Errorf("%w: %s", ErrDiskFailed, d.path)
+		}
+
+		backoff *= 2
+		if d.retry.MaxBackoff > 0 {
+			backoff = min(backoff, d.retry.MaxBackoff)
+		}
+		retries.add(1)
+		err = transfer()
+	}
+	return err
+}
@@ -0,0 +1,260 @@
+package raid
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type S3Options struct {
+	Endpoint  string // base URL, such as https://s3.us-east-1.amazonaws.com or http://localhost:9000
+	Bucket    string // addressed path-style, under the endpoint
+	Prefix    string // prepended to every object key
+	Region    string // signing region, us-east-1 by default
+	AccessKey string
+	SecretKey string
+
+	BlockSize int
+	Blocks    int // physical blocks the backend offers, data plus remap reserve
+
+	Parallelism int          // requests in flight at once, 8 by default
+	Client      *http.Client // http.DefaultClient by default
+}
+
+// S3Backend keeps each block as an object in an S3-compatible bucket, keyed
+// by the prefix and the block number. Blocks never written read as zeros.
+// Concurrent reads of different blocks run in parallel up to Parallelism;
+// a write is durable once acknowledged, so Sync has nothing to do.
+type S3Backend struct {
+	opts S3Options
+	base *url.URL
+	sem  chan struct{}
+	now  func() time.Time
+}
+
+var _ Backend = (*S3Backend)(nil)
+
+func NewS3Backend(opts S3Options) (*S3Backend, error) {
+	if opts.Bucket == "" {
+		return nil, fmt.Errorf("bucket must be set")
+	}
+	if opts.BlockSize <= 0 || opts.Blocks <= 0 {
+		return nil, fmt.Errorf("block size and block count must be positive, got %d and %d", opts.BlockSize, opts.Blocks)
+	}
+	base, err := url.Parse(opts.Endpoint)
+	if err != nil || base.Scheme == "" || base.Host == "" {
+		return nil, fmt.Errorf("invalid endpoint %q", opts.Endpoint)
+	}
+	if opts.Region == "" {
+		opts.Region = "us-east-1"
+	}
+	if opts.Parallelism <= 0 {
+		opts.Parallelism = 8
+	}
+	if opts.Client == nil {
+		opts.Client = http.DefaultClient
+	}
+
+	return &S3Backend{
+		opts: opts,
+		base: base,
+		sem:  make(chan struct{}, opts.Parallelism),
+		now:  time.Now,
+	}, nil
+}
+
+func (b *S3Backend) ReadBlock(block int, buf []byte) error {
+	resp, err := b.do(http.MethodGet, b.key(block), nil)
+	if err != nil {
+		return err
+	}
+	defer b.release(resp)
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		clear(buf)
+		return nil
+	case resp.StatusCode != http.StatusOK:
+		return s3Error(resp, http.MethodGet, b.key(block))
+	}
+
+	n, err := io.ReadFull(resp.Body, buf)
+	if err == io.ErrUnexpectedEOF || err == io.EOF {
+		return fmt.Errorf("%w: object %s holds %d bytes, expected %d", ErrShortIO, b.key(block), n, len(buf))
+	}
+	return err
+}
+
+func (b *S3Backend) WriteBlock(block int, data []byte) error {
+	resp, err := b.do(http.MethodPut, b.key(block), data)
+	if err != nil {
+		return err
+	}
+	defer b.release(resp)
+
+	if resp.StatusCode != http.StatusOK {
+		return s3Error(resp, http.MethodPut, b.key(block))
+	}
+	return nil
+}
+
+func (b *S3Backend) Capacity() int {
+	return b.opts.Blocks
+}
+
+func (b *S3Backend) BlockSize() int {
+	return b.opts.BlockSize
+}
+
+func (b *S3Backend) Sync() error {
+	return nil
+}
+
+func (b *S3Backend) Close() error {
+	return nil
+}
+
+// Health checks that the bucket is reachable with the configured
+// credentials.
+func (b *S3Backend) Health() error {
+	resp, err := b.do(http.MethodHead, "", nil)
+	if err != nil {
+		return err
+	}
+	defer b.release(resp)
+
+	if resp.StatusCode != http.StatusOK {
+		return s3Error(resp, http.MethodHead, b.opts.Bucket)
+	}
+	return nil
+}
+
+func (b *S3Backend) key(block int) string {
+	return b.opts.Prefix + strconv.Itoa(block)
+}
+
+// do sends a signed request for key, or for the bucket itself when key is
+// empty. A slot is held until the response is released.
+func (b *S3Backend) do(method, key string, body []byte) (*http.Response, error) {
+	u := *b.base
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + b.opts.Bucket
+	if key != "" {
+		u.Path += "/" + key
+	}
+
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = int64(len(body))
+	if body == nil {
+		req.Body = http.NoBody
+	}
+	signS3(req, body, b.opts.Region, b.opts.AccessKey, b.opts.SecretKey, b.now())
+
+	b.sem <- struct{}{}
+	resp, err := b.opts.Client.Do(req)
+	if err != nil {
+		<-b.sem
+		return nil, fmt.Errorf("s3 %s %s: %w", method, u.Path, err)
+	}
+	return resp, nil
+}
+
+func (b *S3Backend) release(resp *http.Response) {
+	io.Copy(io.Discard, resp.Body) // lets the connection be reused
+	resp.Body.Close()
+	<-b.sem
+}
+
+func s3Error(resp *http.Response, method, what string) error {
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return fmt.Errorf("s3 %s %s: %s %s", method, what, resp.Status, bytes.TrimSpace(msg))
+}
+
+// signS3 adds AWS Signature Version 4 headers to req. Every header already
+// on the request is signed along with the host.
+func signS3(req *http.Request, body []byte, region, accessKey, secretKey string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		s3EscapePath(req.URL.Path),
+		req.URL.Query().Encode(),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := day + "/" + region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+secretKey), day)
+	for _, part := range []string{region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKey, scope, signedHeaders, signature))
+}
+
+// s3EscapePath URI-encodes every byte of path except unreserved characters
+// and the slashes between segments.
+func s3EscapePath(path string) string {
+	if path == "" {
+		return "/"
+	}
+	var sb strings.Builder
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || strings.IndexByte("-_.~/", c) >= 0 {
+			sb.WriteByte(c)
+		} else {
+			fmt.Fprintf(&sb, "%%%02X", c)
+		}
+	}
+	return sb.String()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	m := hmac.New(sha256.New, key)
+	m.Write([]byte(data))
+	return m.Sum(nil)
+}
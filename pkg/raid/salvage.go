@@ -0,0 +1,53 @@
+package raid
+
+import "context"
+
+type BlockRange struct {
+	Start int
+	Count int
+}
+
+type SalvageReport struct {
+	Recovered     int
+	Unrecoverable []BlockRange
+}
+
+// Salvage is a best-effort export for arrays beyond fault tolerance. Every
+// logical block that can still be read directly or reconstructed is passed
+// to fn; the rest are collected into contiguous unrecoverable ranges.
+func (r *RAIDArray) Salvage(fn func(logicalBlockID int, data []byte) error) (*SalvageReport, error) {
+	report := &SalvageReport{}
+
+	for lb := 0; lb < r.capacity; lb++ {
+		data, err := r.salvageRead(lb)
+		if err != nil {
+			n := len(report.Unrecoverable)
+			if n > 0 && report.Unrecoverable[n-1].Start+report.Unrecoverable[n-1].Count == lb {
+				report.Unrecoverable[n-1].Count++
+			} else {
+				report.Unrecoverable = append(report.Unrecoverable, BlockRange{Start: lb, Count: 1})
+			}
+			continue
+		}
+
+		if err := fn(lb, data); err != nil {
+			return report, err
+		}
+		report.Recovered++
+	}
+
+	return report, nil
+}
+
+// salvageRead reads a logical block for Salvage. A failed RAID 0 refuses
+// every read, so its surviving members are read directly.
+func (r *RAIDArray) salvageRead(lb int) ([]byte, error) {
+	if r.level != RAID0 {
+		return r.ReadBlock(lb)
+	}
+	data := make([]byte, r.blockSize)
+	if err := r.raid0.readBlockRaw(context.Background(), lb, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
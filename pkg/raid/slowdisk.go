@@ -0,0 +1,137 @@
+package raid
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// SlowDiskAction is what happens to a member found to be slow.
+type SlowDiskAction int
+
+const (
+	SlowDiskReport      SlowDiskAction = iota // flag it in Health and emit an event
+	SlowDiskWriteMostly                       // also stop reading from it while a mirror can serve the read (RAID 1)
+	SlowDiskFail                              // also fail it, if the array can spare it
+)
+
+var slowDiskActionNames = [...]string{
+	SlowDiskReport:      "report",
+	SlowDiskWriteMostly: "write_mostly",
+	SlowDiskFail:        "fail",
+}
+
+func (a SlowDiskAction) String() string {
+	if a >= 0 && int(a) < len(slowDiskActionNames) {
+		return slowDiskActionNames[a]
+	}
+	return "unknown"
+}
+
+func (a SlowDiskAction) MarshalText() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+func (a *SlowDiskAction) UnmarshalText(text []byte) error {
+	i := slices.Index(slowDiskActionNames[:], string(text))
+	if i < 0 {
+		return fmt.Errorf("unknown slow disk action %q", text)
+	}
+	*a = SlowDiskAction(i)
+	return nil
+}
+
+// SlowDiskPolicy decides when a member counts as slow, judged by the moving
+// average of its transfer times. The zero value never flags a member.
+type SlowDiskPolicy struct {
+	Threshold  time.Duration  `json:"threshold,omitempty"`   // slow when the average exceeds this, 0 disables
+	PeerFactor float64        `json:"peer_factor,omitempty"` // slow when this many times the median of the other members, 0 disables
+	Action     SlowDiskAction `json:"action"`
+}
+
+// slowDiskCheckInterval is how often member latencies are compared against
+// the policy.
+const slowDiskCheckInterval = 5 * time.Second
+
+func (p SlowDiskPolicy) validate() error {
+	if p.Threshold < 0 || p.PeerFactor < 0 || p.PeerFactor > 0 && p.PeerFactor <= 1 {
+		return fmt.Errorf("invalid slow disk policy %+v", p)
+	}
+	if p.Action < SlowDiskReport || p.Action > SlowDiskFail {
+		return fmt.Errorf("unknown slow disk action %d", p.Action)
+	}
+	return nil
+}
+
+func (r *RAIDArray) slowDiskLoop(policy SlowDiskPolicy) {
+	ticker := r.clock.NewTicker(slowDiskCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.done:
+			return
+		case <-ticker.C():
+		}
+		r.checkSlowDisks(policy)
+	}
+}
+
+// checkSlowDisks flags members that became slow under policy, or stopped
+// being slow, and applies the policy's action.
+func (r *RAIDArray) checkSlowDisks(policy SlowDiskPolicy) {
+	latencies := make([]time.Duration, r.numDisks)
+	failed := 0
+	for i, disk := range r.disks {
+		if disk.IsFailed() {
+			failed++
+			continue
+		}
+		latencies[i] = time.Duration(disk.opLatency.Load())
+	}
+
+	for i, disk := range r.disks {
+		var peers []time.Duration
+		for j, lat := range latencies {
+			if j != i && lat > 0 {
+				peers = append(peers, lat)
+			}
+		}
+		var median time.Duration
+		if len(peers) > 0 {
+			slices.Sort(peers)
+			median = peers[len(peers)/2]
+		}
+
+		lat := latencies[i]
+		slow := lat > 0 && (policy.Threshold > 0 && lat > policy.Threshold ||
+			policy.PeerFactor > 0 && median > 0 && float64(lat) > policy.PeerFactor*float64(median))
+		if slow == disk.slow.Load() {
+			continue
+		}
+		disk.slow.Store(slow)
+
+		if !slow {
+			r.log.Info("disk latency back to normal", "disk", i, "latency", lat, "peers", median)
+			if policy.Action == SlowDiskWriteMostly {
+				disk.writeMostly.Store(false)
+			}
+			r.emit(Event{Type: EventDiskRecovered, Disk: i, Stripe: -1})
+			continue
+		}
+
+		r.log.Warn("disk is slow", "disk", i, "latency", lat, "peers", median, "action", policy.Action)
+		r.emit(Event{Type: EventDiskSlow, Disk: i, Stripe: -1, Detail: fmt.Sprintf("average transfer %v, peers %v", lat, median)})
+		switch policy.Action {
+		case SlowDiskWriteMostly:
+			disk.writeMostly.Store(true)
+		case SlowDiskFail:
+			if failed >= r.tolerated() {
+				r.log.Warn("not failing slow disk: the array has no redundancy to spare", "disk", i)
+				continue
+			}
+			disk.SetFailed(true)
+			failed++
+		}
+	}
+}
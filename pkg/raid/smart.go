@@ -0,0 +1,175 @@
+package raid
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SMARTAttributes are simulated drive health attributes in the spirit of
+// SMART, derived from what the disk has been through: remapped and
+// unreadable blocks, I/O errors, timeouts and load.
+type SMARTAttributes struct {
+	ReallocatedSectors int     `json:"reallocated_sectors"` // blocks moved to the remap reserve (SMART 5)
+	PendingSectors     int     `json:"pending_sectors"`     // unreadable blocks waiting to be rewritten (SMART 197)
+	SpareSectors       int     `json:"spare_sectors"`       // remap reserve left
+	ReadErrors         uint64  `json:"read_errors"`
+	WriteErrors        uint64  `json:"write_errors"`
+	CommandTimeouts    uint64  `json:"command_timeouts"` // SMART 188
+	Temperature        float64 `json:"temperature_celsius"`
+	PowerOnHours       float64 `json:"power_on_hours"` // since the disk was opened
+}
+
+// Limits past which a member is predicted to fail.
+const (
+	smartReallocatedLimit = 8
+	smartErrorLimit       = 10
+	smartTemperatureLimit = 60
+)
+
+// The thermal model: the drive settles smartMaxRise above ambient at
+// smartFullLoad transfers per second, approaching it with time constant
+// smartThermalTau.
+const (
+	defaultAmbient  = 30.0
+	smartMaxRise    = 25.0
+	smartFullLoad   = 1000.0
+	smartThermalTau = 5 * time.Minute
+)
+
+type thermalState struct {
+	mu          sync.Mutex
+	ambient     float64
+	temperature float64
+	transfers   uint64    // read and write count at the last update
+	at          time.Time // time of the last update
+}
+
+// FailureRisks lists the attributes suggesting the drive is about to fail,
+// none for a healthy drive.
+func (a SMARTAttributes) FailureRisks() []string {
+	var risks []string
+	if a.PendingSectors > 0 {
+		risks = append(risks, fmt.Sprintf("%d pending sectors", a.PendingSectors))
+	}
+	if a.ReallocatedSectors >= smartReallocatedLimit || a.ReallocatedSectors > 0 && a.SpareSectors == 0 {
+		risks = append(risks, fmt.Sprintf("%d reallocated sectors, %d spare", a.ReallocatedSectors, a.SpareSectors))
+	}
+	if a.CommandTimeouts > 0 {
+		risks = append(risks, fmt.Sprintf("%d command timeouts", a.CommandTimeouts))
+	}
+	if n := a.ReadErrors + a.WriteErrors; n >= smartErrorLimit {
+		risks = append(risks, fmt.Sprintf("%d I/O errors", n))
+	}
+	if a.Temperature >= smartTemperatureLimit {
+		risks = append(risks, fmt.Sprintf("temperature %.0f°C", a.Temperature))
+	}
+	return risks
+}
+
+// SMART returns the disk's simulated health attributes.
+func (d *Disk) SMART() SMARTAttributes {
+	d.mu.RLock()
+	a := SMARTAttributes{
+		SpareSectors:    d.remapReserve - len(d.badBlocks),
+		ReadErrors:      d.metrics.readErrors.lifetime(),
+		WriteErrors:     d.metrics.writeErrors.lifetime(),
+		CommandTimeouts: d.timeouts.Load(),
+		PowerOnHours:    d.clock.Now().Sub(d.poweredOn).Hours(),
+	}
+	for _, e := range d.badBlocks {
+		if e.Pending {
+			a.PendingSectors++
+		} else {
+			a.ReallocatedSectors++
+		}
+	}
+	d.mu.RUnlock()
+
+	a.Temperature = d.thermal.update(d.clock.Now(), d.transfers())
+	return a
+}
+
+// update moves the temperature towards where the load since the last
+// update would settle it.
+func (t *thermalState) update(now time.Time, transfers uint64) float64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.at.IsZero() {
+		t.temperature, t.transfers, t.at = t.ambient, transfers, now
+	}
+	dt := now.Sub(t.at)
+	if dt <= 0 {
+		return t.temperature
+	}
+	rate := float64(transfers-t.transfers) / dt.Seconds()
+	target := t.ambient + smartMaxRise*min(rate/smartFullLoad, 1)
+	t.temperature = target + (t.temperature-target)*math.Exp(-float64(dt)/float64(smartThermalTau))
+	t.transfers, t.at = transfers, now
+	return t.temperature
+}
+
+// SetAmbient sets the temperature the disk cools towards, to simulate a
+// failed fan or a hot room.
+func (r *RAIDArray) SetAmbient(diskIndex int, celsius float64) error {
+	if diskIndex < 0 || diskIndex >= r.numDisks {
+		return fmt.Errorf("invalid disk index %d", diskIndex)
+	}
+	disk := r.disks[diskIndex]
+	disk.thermal.update(disk.clock.Now(), disk.transfers())
+	disk.thermal.mu.Lock()
+	disk.thermal.ambient = celsius
+	disk.thermal.mu.Unlock()
+	return nil
+}
+
+func (d *Disk) transfers() uint64 {
+	return d.metrics.transfers()
+}
+
+// SMART returns the simulated health attributes of every member.
+func (r *RAIDArray) SMART() []SMARTAttributes {
+	attrs := make([]SMARTAttributes, r.numDisks)
+	for i, disk := range r.disks {
+		attrs[i] = disk.SMART()
+	}
+	return attrs
+}
+
+type FailurePrediction struct {
+	Disk  int      `json:"disk"`
+	Risks []string `json:"risks"`
+}
+
+// PredictFailures lists the active members whose SMART attributes suggest
+// they will fail soon, so they can be replaced while the array is still
+// redundant.
+func (r *RAIDArray) PredictFailures() []FailurePrediction {
+	predictions := []FailurePrediction{}
+	for i, disk := range r.disks {
+		if disk.IsFailed() {
+			continue
+		}
+		if risks := disk.SMART().FailureRisks(); len(risks) > 0 {
+			predictions = append(predictions, FailurePrediction{Disk: i, Risks: risks})
+		}
+	}
+	return predictions
+}
+
+// WhenFailurePredicted reports status while a member is predicted to fail.
+func WhenFailurePredicted(status HealthStatus) HealthRule {
+	return func(s HealthState) (HealthStatus, string) {
+		if len(s.Predictions) == 0 {
+			return HealthPass, ""
+		}
+		var reasons []string
+		for _, p := range s.Predictions {
+			reasons = append(reasons, fmt.Sprintf("disk %d likely to fail (%s)", p.Disk, strings.Join(p.Risks, ", ")))
+		}
+		return status, strings.Join(reasons, "; ")
+	}
+}
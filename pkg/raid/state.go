@@ -0,0 +1,112 @@
+package raid
+
+type State int
+
+const (
+	StateOptimal    State = iota // every member healthy
+	StateDegraded                // members failed, but no more than the level tolerates
+	StateRebuilding              // a failed member is being rebuilt
+	StateFailed                  // more members failed than the level tolerates, data is lost
+	StateReadOnly                // writes paused because backing storage is full
+)
+
+var stateNames = [...]string{
+	StateOptimal:    "optimal",
+	StateDegraded:   "degraded",
+	StateRebuilding: "rebuilding",
+	StateFailed:     "failed",
+	StateReadOnly:   "read_only",
+}
+
+func (s State) String() string {
+	if s >= 0 && int(s) < len(stateNames) {
+		return stateNames[s]
+	}
+	return "unknown"
+}
+
+func (s State) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+type DiskRole int
+
+const (
+	RoleActive     DiskRole = iota // in sync and serving I/O
+	RoleRebuilding                 // being rebuilt, in sync below the rebuild cursor
+	RoleFailed                     // out of the array until rebuilt
+)
+
+var roleNames = [...]string{
+	RoleActive:     "active",
+	RoleRebuilding: "rebuilding",
+	RoleFailed:     "failed",
+}
+
+func (r DiskRole) String() string {
+	if r >= 0 && int(r) < len(roleNames) {
+		return roleNames[r]
+	}
+	return "unknown"
+}
+
+func (r DiskRole) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+type ArrayState struct {
+	State   State            `json:"state"`
+	Roles   []DiskRole       `json:"roles"`             // indexed by disk
+	Rebuild *RebuildProgress `json:"rebuild,omitempty"` // nil when no rebuild is running
+}
+
+// State reports the condition of the array and the role of each member.
+// When several states apply the most severe wins: failed, then read-only,
+// then rebuilding, then degraded. A member being rebuilt is not yet in
+// sync, so it counts towards the failures the level tolerates.
+func (r *RAIDArray) State() ArrayState {
+	s := ArrayState{Roles: make([]DiskRole, r.numDisks)}
+
+	rebuilding := -1
+	if r.raid5 != nil {
+		if s.Rebuild = r.raid5.progress(); s.Rebuild != nil {
+			rebuilding = s.Rebuild.Disk
+		}
+	}
+
+	failed := 0
+	for i, disk := range r.disks {
+		switch {
+		case i == rebuilding:
+			s.Roles[i] = RoleRebuilding
+			failed++
+		case disk.IsFailed():
+			s.Roles[i] = RoleFailed
+			failed++
+		}
+	}
+
+	switch {
+	case failed > r.tolerated():
+		s.State = StateFailed
+	case r.WritesPaused():
+		s.State = StateReadOnly
+	case rebuilding >= 0:
+		s.State = StateRebuilding
+	case failed > 0:
+		s.State = StateDegraded
+	}
+	return s
+}
+
+// tolerated is how many members can fail without losing data.
+func (r *RAIDArray) tolerated() int {
+	switch r.level {
+	case RAID1:
+		return r.numDisks - 1
+	case RAID5:
+		return 1
+	default:
+		return 0
+	}
+}
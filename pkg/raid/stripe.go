@@ -0,0 +1,31 @@
+package raid
+
+// BlocksPerStripe is the number of logical blocks that make up one full
+// stripe. Writes covering whole stripes skip the RAID 5 read-modify-write.
+func (r *RAIDArray) BlocksPerStripe() int {
+	switch r.level {
+	case RAID0:
+		return r.numDisks
+	case RAID5:
+		return r.numDisks - 1
+	default:
+		return 1
+	}
+}
+
+// StripeSize is the size of a full stripe's data in bytes.
+func (r *RAIDArray) StripeSize() int {
+	return r.BlocksPerStripe() * r.blockSize
+}
+
+// AlignForStripes widens the range [start, start+count) to stripe
+// boundaries, so upper layers can allocate in full stripes.
+func (r *RAIDArray) AlignForStripes(start, count int) (int, int) {
+	bps := r.BlocksPerStripe()
+	end := start + count
+	start -= start % bps
+	if rem := end % bps; rem != 0 {
+		end += bps - rem
+	}
+	return start, end - start
+}
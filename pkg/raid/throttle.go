@@ -0,0 +1,71 @@
+package raid
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+const throttleTick = 10 * time.Millisecond
+
+// pacer limits background work to a byte rate that can change while the
+// work is running. Budget accrues on every tick of the array clock, with
+// at most one second's worth banked.
+type pacer struct {
+	rate   *atomic.Int64 // bytes per second, 0 = unlimited
+	clock  Clock
+	ticker Ticker
+	credit int64
+}
+
+func newPacer(rate *atomic.Int64, clock Clock) *pacer {
+	return &pacer{rate: rate, clock: clock}
+}
+
+// wait blocks until n bytes may be processed. It fails with ctx's error
+// when ctx is cancelled and with ErrClosed once done is closed, so the work
+// stops rather than running on unthrottled.
+func (p *pacer) wait(ctx context.Context, n int, done <-chan struct{}) error {
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		select {
+		case <-done:
+			return ErrClosed
+		default:
+		}
+		rate := p.rate.Load()
+		if rate <= 0 || p.credit >= int64(n) {
+			p.credit = max(p.credit-int64(n), 0)
+			return nil
+		}
+
+		if p.ticker == nil {
+			p.ticker = p.clock.NewTicker(throttleTick)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-done:
+			return ErrClosed
+		case <-p.ticker.C():
+		}
+		p.credit = min(p.credit+max(rate*int64(throttleTick)/int64(time.Second), 1), max(rate, int64(n)))
+	}
+}
+
+func (p *pacer) stop() {
+	if p.ticker != nil {
+		p.ticker.Stop()
+	}
+}
+
+// SetRebuildRate limits rebuild throughput in bytes per second, counted as
+// data written to the disk being rebuilt. Zero removes the limit. It takes
+// effect immediately, including for a rebuild already in progress.
+func (r *RAIDArray) SetRebuildRate(bytesPerSecond int64) {
+	r.rebuildRate.Store(max(bytesPerSecond, 0))
+	r.emit(Event{Type: EventConfigChanged, Disk: -1, Stripe: -1, Detail: fmt.Sprintf("rebuild_rate %d", r.rebuildRate.Load())})
+}
@@ -0,0 +1,58 @@
+package raid
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// storeRead reads a block from the backend, giving up after the disk's
+// I/O timeout. A transfer that timed out is left to finish in the
+// background into a buffer of its own.
+func (d *Disk) storeRead(block int, buf []byte) error {
+	if d.timeout <= 0 {
+		return d.store.ReadBlock(block, buf)
+	}
+	tmp := make([]byte, len(buf))
+	err := d.timed(func() error { return d.store.ReadBlock(block, tmp) })
+	if err == nil {
+		copy(buf, tmp)
+	}
+	return err
+}
+
+// storeWrite is storeRead for writes. A write that timed out may still
+// reach the image later.
+func (d *Disk) storeWrite(block int, data []byte) error {
+	if d.timeout <= 0 {
+		return d.store.WriteBlock(block, data)
+	}
+	tmp := bytes.Clone(data)
+	return d.timed(func() error { return d.store.WriteBlock(block, tmp) })
+}
+
+func (d *Disk) timed(op func() error) error {
+	done := make(chan error, 1)
+	go func() { done <- op() }()
+
+	// the deadline is measured on the array clock, like the rest of the
+	// disk's timekeeping
+	deadline := d.clock.NewTicker(d.timeout)
+	defer deadline.Stop()
+	select {
+	case err := <-done:
+		return err
+	case <-deadline.C():
+		return d.timedOut()
+	}
+}
+
+// timedOut counts a transfer that missed its deadline. Once the disk's
+// limit is reached the error wraps ErrDiskFailed, so the disk is failed
+// and the array degrades instead of waiting on it again.
+func (d *Disk) timedOut() error {
+	n := d.timeouts.Add(1)
+	if d.timeoutLimit > 0 && n >= uint64(d.timeoutLimit) {
+		return fmt.Errorf("%w: %d transfers timed out: %w", ErrDiskFailed, n, ErrTimeout)
+	}
+	return fmt.Errorf("%w after %v", ErrTimeout, d.timeout)
+}
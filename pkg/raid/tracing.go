@@ -0,0 +1,67 @@
+package raid
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// Tracer receives a span for every array read, write and rebuild, with a
+// child span for each member transfer it fans out to, so the time spent in
+// a stripe update can be followed in a tracing system. It has the shape of
+// an OpenTelemetry tracer; the README shows the adapter.
+type Tracer interface {
+	// Start begins a span named name as a child of any span in ctx and
+	// returns a context carrying the new one.
+	Start(ctx context.Context, name string, attrs ...slog.Attr) (context.Context, Span)
+}
+
+// Span is an operation in progress.
+type Span interface {
+	End(err error) // err is nil when the operation succeeded
+}
+
+// Span attribute keys.
+const (
+	TraceKeyDisk  = "raid.disk"  // member index
+	TraceKeyBlock = "raid.block" // logical block for array spans, member block for disk spans
+)
+
+// startSpan starts a span if the array has a tracer, recording disk and
+// block unless they are negative. The span is nil without a tracer.
+func (r *RAIDArray) startSpan(ctx context.Context, name string, disk, block int) (context.Context, Span) {
+	if r.tracer == nil {
+		return ctx, nil
+	}
+	attrs := make([]slog.Attr, 0, 2)
+	if disk >= 0 {
+		attrs = append(attrs, slog.Int(TraceKeyDisk, disk))
+	}
+	if block >= 0 {
+		attrs = append(attrs, slog.Int(TraceKeyBlock, block))
+	}
+	return r.tracer.Start(ctx, name, attrs...)
+}
+
+func endSpan(span Span, err error) {
+	if span != nil {
+		span.End(err)
+	}
+}
+
+// readMember reads a member block under a "disk.read" span.
+func (r *RAIDArray) readMember(ctx context.Context, disk, block int, buf []byte) error {
+	ctx, span := r.startSpan(ctx, "disk.read", disk, block)
+	err := r.disks[disk].ReadBlockContext(ctx, block, buf)
+	endSpan(span, err)
+	return err
+}
+
+// writeMember writes a member block under a "disk.write" span, reporting
+// how long its fsync took.
+func (r *RAIDArray) writeMember(ctx context.Context, disk, block int, data []byte) (time.Duration, error) {
+	_, span := r.startSpan(ctx, "disk.write", disk, block)
+	syncTime, err := r.disks[disk].writeBlock(block, data)
+	endSpan(span, err)
+	return syncTime, err
+}
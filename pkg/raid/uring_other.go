@@ -0,0 +1,18 @@
+//go:build !linux || !(amd64 || arm64)
+
+package raid
+
+import (
+	"errors"
+	"os"
+)
+
+type ioRing struct{}
+
+func newIORing() (*ioRing, error) {
+	return nil, errors.New("io_uring is only supported on linux/amd64 and linux/arm64")
+}
+
+func (r *ioRing) ReadAt(f *os.File, buf []byte, off int64) (int, error)  { return f.ReadAt(buf, off) }
+func (r *ioRing) WriteAt(f *os.File, buf []byte, off int64) (int, error) { return f.WriteAt(buf, off) }
+func (r *ioRing) Close() error                                           { return nil }
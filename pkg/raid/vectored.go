@@ -0,0 +1,153 @@
+package raid
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// IOVec is one extent of a vectored transfer: len(Data) bytes at byte
+// offset Offset of the logical address space.
+type IOVec struct {
+	Offset int64
+	Data   []byte
+}
+
+// piece is the part of an extent that falls in one block.
+type piece struct {
+	at   int // byte offset in the block
+	data []byte
+}
+
+// splitVecs checks every extent lies inside the array and splits them into
+// per-block pieces, in extent order.
+func (r *RAIDArray) splitVecs(op string, iov []IOVec) (map[int][]piece, error) {
+	bs := int64(r.blockSize)
+	blocks := make(map[int][]piece)
+	for i, v := range iov {
+		if v.Offset < 0 || v.Offset+int64(len(v.Data)) > r.Size() {
+			return nil, fmt.Errorf("%w: %s extent %d of %d bytes at %d exceeds array size %d",
+				ErrBlockOutOfRange, op, i, len(v.Data), v.Offset, r.Size())
+		}
+		for off, p := v.Offset, v.Data; len(p) > 0; {
+			at := int(off % bs)
+			n := min(r.blockSize-at, len(p))
+			id := int(off / bs)
+			blocks[id] = append(blocks[id], piece{at, p[:n]})
+			off, p = off+int64(n), p[n:]
+		}
+	}
+	return blocks, nil
+}
+
+// ReadV fills every extent in iov in one call. Each block any extent
+// touches is read once, however many extents share it, with one goroutine
+// per member disk. Extents reaching past the end are an error and nothing
+// is read.
+func (r *RAIDArray) ReadV(iov []IOVec) error {
+	blocks, err := r.splitVecs("read", iov)
+	if err != nil {
+		return err
+	}
+
+	ids := make([]int, 0, len(blocks))
+	for id := range blocks {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+
+	buf := make([]byte, len(ids)*r.blockSize)
+	return r.forEachDiskGroup(ids, func(i int) error {
+		b := buf[i*r.blockSize : (i+1)*r.blockSize]
+		if err := r.ReadBlockInto(ids[i], b); err != nil {
+			return err
+		}
+		for _, p := range blocks[ids[i]] {
+			copy(p.data, b[p.at:])
+		}
+		return nil
+	})
+}
+
+// WriteV writes every extent in iov in one call. Pieces landing in the same
+// block are merged first, later extents winning where they overlap, so each
+// block is written once. Blocks the extents cover completely go out as one
+// batch, which lets RAID 5 skip the parity pre-reads for full stripes; the
+// rest are read, patched and written back alongside it. Extents reaching
+// past the end are an error and nothing is written; after any other error
+// some extents may have been written.
+func (r *RAIDArray) WriteV(iov []IOVec) error {
+	blocks, err := r.splitVecs("write", iov)
+	if err != nil {
+		return err
+	}
+
+	var fullIDs, partialIDs []int
+	for id, pieces := range blocks {
+		if covers(pieces, r.blockSize) {
+			fullIDs = append(fullIDs, id)
+		} else {
+			partialIDs = append(partialIDs, id)
+		}
+	}
+	slices.Sort(fullIDs)
+	slices.Sort(partialIDs)
+
+	var wg sync.WaitGroup
+	var partialErr error
+	if len(partialIDs) > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			partialErr = r.forEachDiskGroup(partialIDs, func(i int) error {
+				return r.patchPieces(partialIDs[i], blocks[partialIDs[i]])
+			})
+		}()
+	}
+
+	data := make([][]byte, len(fullIDs))
+	buf := make([]byte, len(fullIDs)*r.blockSize)
+	for i, id := range fullIDs {
+		data[i] = buf[i*r.blockSize : (i+1)*r.blockSize]
+		for _, p := range blocks[id] {
+			copy(data[i][p.at:], p.data)
+		}
+	}
+	fullErr := r.WriteBlocks(fullIDs, data)
+	wg.Wait()
+
+	if fullErr != nil {
+		return fullErr
+	}
+	return partialErr
+}
+
+// covers reports whether pieces cover every byte of a block.
+func covers(pieces []piece, blockSize int) bool {
+	sorted := slices.Clone(pieces)
+	slices.SortFunc(sorted, func(a, b piece) int { return a.at - b.at })
+	end := 0
+	for _, p := range sorted {
+		if p.at > end {
+			return false
+		}
+		end = max(end, p.at+len(p.data))
+	}
+	return end == blockSize
+}
+
+// patchPieces applies pieces to a block with one read-modify-write.
+func (r *RAIDArray) patchPieces(blockID int, pieces []piece) error {
+	defer r.partial.lock(blockID)()
+
+	buf := r.buffers.get()
+	defer r.buffers.put(buf)
+
+	if err := r.ReadBlockInto(blockID, buf); err != nil {
+		return fmt.Errorf("failed to read block %d for partial write: %w", blockID, err)
+	}
+	for _, p := range pieces {
+		copy(buf[p.at:], p.data)
+	}
+	return r.WriteBlock(blockID, buf)
+}
@@ -0,0 +1,60 @@
+package raid
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ImageDiff is the result of comparing a raw image, the logical blocks
+// written back to back, with the current array contents.
+type ImageDiff struct {
+	Blocks     int   `json:"blocks"` // blocks compared
+	Mismatches []int `json:"mismatched_blocks"`
+	Missing    int   `json:"missing_blocks"` // array blocks past the end of the image
+	Trailing   bool  `json:"trailing_data"`  // image continues past the end of the array
+	Match      bool  `json:"match"`
+}
+
+// VerifyImage streams img and compares it block by block with the array.
+// A partial final block counts as a mismatch.
+func (r *RAIDArray) VerifyImage(img io.Reader) (*ImageDiff, error) {
+	diff := &ImageDiff{Mismatches: []int{}}
+	want := make([]byte, r.blockSize)
+	got := make([]byte, r.blockSize)
+
+	for id := 0; id < r.capacity; id++ {
+		n, err := io.ReadFull(img, want)
+		if err == io.EOF {
+			diff.Missing = r.capacity - id
+			break
+		}
+		if err != nil && err != io.ErrUnexpectedEOF {
+			return nil, fmt.Errorf("failed to read image at block %d: %w", id, err)
+		}
+
+		if err := r.ReadBlockInto(id, got); err != nil {
+			return nil, fmt.Errorf("failed to read block %d: %w", id, err)
+		}
+		diff.Blocks++
+		if n < r.blockSize || !bytes.Equal(want, got) {
+			diff.Mismatches = append(diff.Mismatches, id)
+		}
+		if n < r.blockSize {
+			diff.Missing = r.capacity - id - 1
+			break
+		}
+	}
+
+	if diff.Missing == 0 {
+		n, err := img.Read(want[:1])
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("failed to read image: %w", err)
+		}
+		diff.Trailing = n > 0
+	}
+
+	diff.Match = len(diff.Mismatches) == 0 && diff.Missing == 0 && !diff.Trailing
+	return diff, nil
+}
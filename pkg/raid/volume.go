@@ -0,0 +1,486 @@
+package raid
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+)
+
+var (
+	ErrVolumeExists   = errors.New("volume already exists")
+	ErrVolumeNotFound = errors.New("no such volume")
+	ErrVolumeNoSpace  = errors.New("not enough free blocks for volume")
+)
+
+var (
+	_ io.ReaderAt = (*Volume)(nil)
+	_ io.WriterAt = (*Volume)(nil)
+)
+
+const maxVolumeName = 64
+
+// VolumeExtent is a run of contiguous logical blocks of the array.
+type VolumeExtent struct {
+	Start int `json:"start"`
+	Count int `json:"count"`
+}
+
+// VolumeMeta describes a logical volume and the array blocks backing it, in
+// volume order.
+type VolumeMeta struct {
+	Name    string         `json:"name"`
+	Blocks  int            `json:"blocks"`
+	Extents []VolumeExtent `json:"extents"`
+}
+
+// volumeTable maps volume names to their extents. Volume I/O holds mu
+// shared, so creating, resizing or deleting a volume waits for transfers
+// to blocks it may hand to another volume.
+//
+// Changes to the table also hold admin throughout, so they may read it
+// without mu. Only they allocate, so blocks picked for a volume stay
+// reserved for it while they are zeroed without mu, and volume I/O carries
+// on meanwhile.
+type volumeTable struct {
+	admin  sync.Mutex
+	mu     sync.RWMutex
+	byName map[string]*volume
+}
+
+// volume is a table entry. Handles point at it, so a handle to a deleted
+// volume does not reach a new one of the same name.
+type volume struct {
+	extents []VolumeExtent
+}
+
+func (t *volumeTable) add(name string, extents []VolumeExtent) {
+	if t.byName == nil {
+		t.byName = make(map[string]*volume)
+	}
+	t.byName[name] = &volume{extents: extents}
+}
+
+func blocksIn(extents []VolumeExtent) int {
+	n := 0
+	for _, e := range extents {
+		n += e.Count
+	}
+	return n
+}
+
+// appendExtent adds e to the end of a volume, merging it with the last
+// extent when they are adjacent.
+func appendExtent(extents []VolumeExtent, e VolumeExtent) []VolumeExtent {
+	if n := len(extents); n > 0 && extents[n-1].Start+extents[n-1].Count == e.Start {
+		extents[n-1].Count += e.Count
+		return extents
+	}
+	return append(extents, e)
+}
+
+// free returns the runs of blocks no volume uses, in block order.
+func (t *volumeTable) free(capacity int) []VolumeExtent {
+	var used []VolumeExtent
+	for _, v := range t.byName {
+		used = append(used, v.extents...)
+	}
+	slices.SortFunc(used, func(a, b VolumeExtent) int { return a.Start - b.Start })
+
+	var free []VolumeExtent
+	next := 0
+	for _, e := range used {
+		if e.Start > next {
+			free = append(free, VolumeExtent{next, e.Start - next})
+		}
+		next = e.Start + e.Count
+	}
+	if next < capacity {
+		free = append(free, VolumeExtent{next, capacity - next})
+	}
+	return free
+}
+
+// allocate picks n free blocks to extend a volume currently made of
+// extents. The run right after the volume's end is used first, so a volume
+// grown into free space stays contiguous; after that the lowest runs are
+// taken.
+func (t *volumeTable) allocate(extents []VolumeExtent, n, capacity int) ([]VolumeExtent, error) {
+	free := t.free(capacity)
+	if avail := blocksIn(free); avail < n {
+		return nil, fmt.Errorf("%w: need %d blocks, %d free", ErrVolumeNoSpace, n, avail)
+	}
+	if len(extents) > 0 {
+		last := extents[len(extents)-1]
+		i := slices.IndexFunc(free, func(e VolumeExtent) bool { return e.Start == last.Start+last.Count })
+		if i > 0 {
+			free = append(append([]VolumeExtent{free[i]}, free[:i]...), free[i+1:]...)
+		}
+	}
+
+	var picked []VolumeExtent
+	for _, e := range free {
+		if n == 0 {
+			break
+		}
+		e.Count = min(e.Count, n)
+		picked = append(picked, e)
+		n -= e.Count
+	}
+	return picked, nil
+}
+
+// restore loads volumes recorded by ExportMeta, checking they fit the
+// array and do not share blocks.
+func (t *volumeTable) restore(volumes []VolumeMeta, capacity int) error {
+	t.admin.Lock()
+	defer t.admin.Unlock()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	owner := make(map[int]string)
+	t.byName = nil
+	for _, v := range volumes {
+		if err := validVolumeName(v.Name); err != nil {
+			return err
+		}
+		if _, ok := t.byName[v.Name]; ok {
+			return fmt.Errorf("%w: %s recorded twice", ErrVolumeExists, v.Name)
+		}
+		if blocksIn(v.Extents) != v.Blocks {
+			return fmt.Errorf("volume %s: extents hold %d blocks, recorded size is %d", v.Name, blocksIn(v.Extents), v.Blocks)
+		}
+		for _, e := range v.Extents {
+			if e.Start < 0 || e.Count <= 0 || e.Start+e.Count > capacity {
+				return fmt.Errorf("volume %s: extent of %d blocks at %d not in [0, %d)", v.Name, e.Count, e.Start, capacity)
+			}
+			for b := e.Start; b < e.Start+e.Count; b++ {
+				if other, ok := owner[b]; ok {
+					return fmt.Errorf("volume %s: block %d also belongs to %s", v.Name, b, other)
+				}
+				owner[b] = v.Name
+			}
+		}
+		t.add(v.Name, slices.Clone(v.Extents))
+	}
+	return nil
+}
+
+func validVolumeName(name string) error {
+	if name == "" || len(name) > maxVolumeName {
+		return fmt.Errorf("volume name must be 1 to %d characters", maxVolumeName)
+	}
+	for _, c := range name {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.') {
+			return fmt.Errorf("invalid volume name %q: use letters, digits, '-', '_' and '.'", name)
+		}
+	}
+	return nil
+}
+
+// zeroExtents discards the blocks a volume is given, so it never reads
+// back another volume's old data.
+func (r *RAIDArray) zeroExtents(extents []VolumeExtent) error {
+	for _, e := range extents {
+		if err := r.Discard(e.Start, e.Count); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CreateVolume carves a volume of blocks logical blocks out of the array's
+// free space. Its blocks read as zeros until written.
+//
+// Volume changes are made in memory only. The array does not store its
+// own metadata, so callers persist them by saving ExportMeta again, for
+// example on EventVolumeChanged.
+func (r *RAIDArray) CreateVolume(name string, blocks int) (*Volume, error) {
+	if err := validVolumeName(name); err != nil {
+		return nil, err
+	}
+	if blocks <= 0 {
+		return nil, fmt.Errorf("volume size must be positive, got %d blocks", blocks)
+	}
+
+	t := &r.volumes
+	t.admin.Lock()
+	defer t.admin.Unlock()
+
+	if _, ok := t.byName[name]; ok {
+		return nil, fmt.Errorf("%w: %s", ErrVolumeExists, name)
+	}
+	extents, err := t.allocate(nil, blocks, r.capacity)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.zeroExtents(extents); err != nil {
+		return nil, fmt.Errorf("failed to clear volume %s: %w", name, err)
+	}
+
+	t.mu.Lock()
+	t.add(name, extents)
+	v := t.byName[name]
+	t.mu.Unlock()
+
+	r.emit(Event{Type: EventVolumeChanged, Disk: -1, Stripe: -1, Detail: fmt.Sprintf("created %s, %d blocks", name, blocks)})
+	return &Volume{array: r, name: name, v: v}, nil
+}
+
+// ResizeVolume grows or shrinks a volume to blocks logical blocks. Growing
+// adds zeroed blocks at the end, from wherever free space is; shrinking
+// drops blocks from the end and their data with them. Like CreateVolume,
+// it changes the volume table in memory only.
+func (r *RAIDArray) ResizeVolume(name string, blocks int) error {
+	if blocks <= 0 {
+		return fmt.Errorf("volume size must be positive, got %d blocks", blocks)
+	}
+
+	t := &r.volumes
+	t.admin.Lock()
+	defer t.admin.Unlock()
+
+	v, ok := t.byName[name]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrVolumeNotFound, name)
+	}
+	old := blocksIn(v.extents)
+	resized := slices.Clone(v.extents)
+
+	switch {
+	case blocks > old:
+		added, err := t.allocate(v.extents, blocks-old, r.capacity)
+		if err != nil {
+			return err
+		}
+		if err := r.zeroExtents(added); err != nil {
+			return fmt.Errorf("failed to clear blocks for volume %s: %w", name, err)
+		}
+		for _, e := range added {
+			resized = appendExtent(resized, e)
+		}
+	case blocks < old:
+		for drop := old - blocks; drop > 0; {
+			last := &resized[len(resized)-1]
+			if last.Count > drop {
+				last.Count -= drop
+				break
+			}
+			drop -= last.Count
+			resized = resized[:len(resized)-1]
+		}
+	default:
+		return nil
+	}
+
+	t.mu.Lock()
+	v.extents = resized
+	t.mu.Unlock()
+
+	r.emit(Event{Type: EventVolumeChanged, Disk: -1, Stripe: -1, Detail: fmt.Sprintf("resized %s from %d to %d blocks", name, old, blocks)})
+	return nil
+}
+
+// DeleteVolume removes a volume and returns its blocks to the free space.
+// Handles to it fail with ErrVolumeNotFound from then on, even if a volume
+// of the same name is created again. Like CreateVolume, it changes the
+// volume table in memory only.
+func (r *RAIDArray) DeleteVolume(name string) error {
+	t := &r.volumes
+	t.admin.Lock()
+	defer t.admin.Unlock()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, ok := t.byName[name]; !ok {
+		return fmt.Errorf("%w: %s", ErrVolumeNotFound, name)
+	}
+	delete(t.byName, name)
+
+	r.emit(Event{Type: EventVolumeChanged, Disk: -1, Stripe: -1, Detail: "deleted " + name})
+	return nil
+}
+
+// Volumes lists the array's volumes by name.
+func (r *RAIDArray) Volumes() []VolumeMeta {
+	t := &r.volumes
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	vols := make([]VolumeMeta, 0, len(t.byName))
+	for name, v := range t.byName {
+		vols = append(vols, VolumeMeta{Name: name, Blocks: blocksIn(v.extents), Extents: slices.Clone(v.extents)})
+	}
+	slices.SortFunc(vols, func(a, b VolumeMeta) int { return cmp.Compare(a.Name, b.Name) })
+	return vols
+}
+
+// FreeBlocks returns how many logical blocks no volume uses.
+func (r *RAIDArray) FreeBlocks() int {
+	t := &r.volumes
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return blocksIn(t.free(r.capacity))
+}
+
+// Volume returns a handle to the named volume.
+func (r *RAIDArray) Volume(name string) (*Volume, error) {
+	t := &r.volumes
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	v, ok := t.byName[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrVolumeNotFound, name)
+	}
+	return &Volume{array: r, name: name, v: v}, nil
+}
+
+// Volume is a handle to a logical volume. It offers the array's block and
+// byte APIs over the volume's own address space, and follows resizes.
+type Volume struct {
+	array *RAIDArray
+	name  string
+	v     *volume
+}
+
+func (v *Volume) Name() string { return v.name }
+
+func (v *Volume) BlockSize() int { return v.array.blockSize }
+
+// extents returns the volume's current extents, failing once it has been
+// deleted. Caller holds the table's read lock.
+func (v *Volume) extents() ([]VolumeExtent, error) {
+	if v.array.volumes.byName[v.name] != v.v {
+		return nil, fmt.Errorf("%w: %s", ErrVolumeNotFound, v.name)
+	}
+	return v.v.extents, nil
+}
+
+// Capacity is the volume's size in blocks, 0 once it has been deleted.
+func (v *Volume) Capacity() int {
+	t := &v.array.volumes
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	extents, err := v.extents()
+	if err != nil {
+		return 0
+	}
+	return blocksIn(extents)
+}
+
+// Size is the volume's size in bytes.
+func (v *Volume) Size() int64 {
+	return int64(v.Capacity()) * int64(v.array.blockSize)
+}
+
+// do runs fn with the array block backing volume block blockID.
+func (v *Volume) do(blockID int, fn func(arrayBlock int) error) error {
+	t := &v.array.volumes
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	extents, err := v.extents()
+	if err != nil {
+		return err
+	}
+	if blockID >= 0 {
+		for _, e := range extents {
+			if blockID < e.Count {
+				return fn(e.Start + blockID)
+			}
+			blockID -= e.Count
+		}
+	}
+	return outOfRange("volume block", blockID, blocksIn(extents))
+}
+
+func (v *Volume) ReadBlock(blockID int) ([]byte, error) {
+	var data []byte
+	err := v.do(blockID, func(b int) (err error) {
+		data, err = v.array.ReadBlock(b)
+		return err
+	})
+	return data, err
+}
+
+func (v *Volume) ReadBlockInto(blockID int, buf []byte) error {
+	return v.do(blockID, func(b int) error { return v.array.ReadBlockInto(b, buf) })
+}
+
+func (v *Volume) WriteBlock(blockID int, data []byte) error {
+	return v.do(blockID, func(b int) error { return v.array.WriteBlock(b, data) })
+}
+
+// vecs maps len(p) bytes at volume offset off onto array extents.
+func (v *Volume) vecs(extents []VolumeExtent, p []byte, off int64) []IOVec {
+	bs := int64(v.array.blockSize)
+	var iov []IOVec
+	for _, e := range extents {
+		if len(p) == 0 {
+			break
+		}
+		size := int64(e.Count) * bs
+		if off >= size {
+			off -= size
+			continue
+		}
+		n := min(int64(len(p)), size-off)
+		iov = append(iov, IOVec{int64(e.Start)*bs + off, p[:n]})
+		p, off = p[n:], 0
+	}
+	return iov
+}
+
+// ReadAt reads len(p) bytes at byte offset off of the volume. Reads reaching
+// past the end return io.EOF with the bytes available.
+func (v *Volume) ReadAt(p []byte, off int64) (int, error) {
+	t := &v.array.volumes
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	extents, err := v.extents()
+	if err != nil {
+		return 0, err
+	}
+	if off < 0 {
+		return 0, fmt.Errorf("negative offset %d", off)
+	}
+	size := int64(blocksIn(extents)) * int64(v.array.blockSize)
+	if off >= size {
+		return 0, io.EOF
+	}
+	n := int(min(int64(len(p)), size-off))
+	if err := v.array.ReadV(v.vecs(extents, p[:n], off)); err != nil {
+		return 0, err
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// WriteAt writes p at byte offset off of the volume. Writes past the end
+// fail without writing anything.
+func (v *Volume) WriteAt(p []byte, off int64) (int, error) {
+	t := &v.array.volumes
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	extents, err := v.extents()
+	if err != nil {
+		return 0, err
+	}
+	if off < 0 {
+		return 0, fmt.Errorf("negative offset %d", off)
+	}
+	if size := int64(blocksIn(extents)) * int64(v.array.blockSize); off+int64(len(p)) > size {
+		return 0, fmt.Errorf("%w: write of %d bytes at %d exceeds volume size %d", ErrBlockOutOfRange, len(p), off, size)
+	}
+	if err := v.array.WriteV(v.vecs(extents, p, off)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}